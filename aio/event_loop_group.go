@@ -0,0 +1,105 @@
+package aio
+
+import (
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/amsalt/nginet/core"
+)
+
+// EventLoopChooser chooses the index of the event loop for a channel among n loops.
+type EventLoopChooser func(channel core.Channel, n int) int
+
+// RoundRobinChooser returns a chooser assigns the channels to the loops in turn.
+func RoundRobinChooser() EventLoopChooser {
+	var next uint32
+	return func(channel core.Channel, n int) int {
+		return int((atomic.AddUint32(&next, 1) - 1) % uint32(n))
+	}
+}
+
+// HashChooser assigns the channel to a loop by the hash of its ID,
+// so the channels with the same ID always run on the same loop.
+func HashChooser(channel core.Channel, n int) int {
+	if channel == nil || channel.ID() == nil {
+		return 0
+	}
+	h := fnv.New32a()
+	fmt.Fprint(h, channel.ID())
+	return int(h.Sum32() % uint32(n))
+}
+
+// EventLoopGroup consists of a number of EventLoops and implements core.ExecutorGroup.
+// Each channel is pinned to one loop, all the handlers of the channel added with the
+// group run on that loop, which keeps the events of one channel in order and scales
+// the channels across cores.
+// for example:
+//
+//	group := aio.NewEventLoopGroup(0)
+//	group.Start()
+//	channel.Pipeline().AddLast(group, "processor", handler.NewDefaultMessageHandler(processMgr))
+type EventLoopGroup struct {
+	loops   []*EventLoop
+	chooser EventLoopChooser
+	next    uint32 // for the tasks executed by the group directly.
+}
+
+// NewEventLoopGroup creates a group of n event loops, n <= 0 means runtime.NumCPU().
+// The loops are assigned to channels by RoundRobinChooser by default.
+func NewEventLoopGroup(n int) *EventLoopGroup {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+
+	group := new(EventLoopGroup)
+	group.loops = make([]*EventLoop, n)
+	for i := range group.loops {
+		group.loops[i] = NewEventLoop()
+	}
+	group.chooser = RoundRobinChooser()
+
+	return group
+}
+
+// SetChooser sets the strategy of assigning loops to channels, should be called before used.
+func (elg *EventLoopGroup) SetChooser(chooser EventLoopChooser) *EventLoopGroup {
+	elg.chooser = chooser
+	return elg
+}
+
+// Start starts all event loops.
+func (elg *EventLoopGroup) Start() {
+	for _, loop := range elg.loops {
+		loop.Start()
+	}
+}
+
+// Stop stops all event loops.
+func (elg *EventLoopGroup) Stop() {
+	for _, loop := range elg.loops {
+		loop.Stop()
+	}
+}
+
+// Size returns the number of event loops.
+func (elg *EventLoopGroup) Size() int {
+	return len(elg.loops)
+}
+
+// Loop returns the ith event loop.
+func (elg *EventLoopGroup) Loop(i int) *EventLoop {
+	return elg.loops[i]
+}
+
+// Execute executes the task not bound to any channel on the loops in turn.
+func (elg *EventLoopGroup) Execute(task func()) {
+	i := (atomic.AddUint32(&elg.next, 1) - 1) % uint32(len(elg.loops))
+	elg.loops[i].Execute(task)
+}
+
+// Next chooses the event loop for the channel, implements core.ExecutorGroup.
+func (elg *EventLoopGroup) Next(channel core.Channel) core.Executor {
+	return elg.loops[elg.chooser(channel, len(elg.loops))]
+}
@@ -86,36 +86,37 @@ func (b *readOnlyBuffer) Seek(n int) ([]byte, error) {
 	return b.buf[b.off : b.off+n], nil
 }
 
-func (b *readOnlyBuffer) ensureSpace() int {
-	m, ok := b.tryGrowByReslice(b.Len())
-	if !ok {
-		m = b.grow()
+// ensureSpace makes free space after the unread bytes. The empty buffer is rewound,
+// otherwise the consumed region is compacted, the buffer grows only if it's full of unread bytes.
+func (b *readOnlyBuffer) ensureSpace() {
+	if b.Len() == 0 {
+		b.off = 0
+		b.end = 0
 	}
-	return m
+	b.buf = b.buf[:cap(b.buf)]
+	if b.end < len(b.buf) {
+		return
+	}
+
+	if b.off > 0 {
+		copy(b.buf, b.buf[b.off:b.end])
+		b.end -= b.off
+		b.off = 0
+		return
+	}
+	b.grow()
 }
 
-func (b *readOnlyBuffer) grow() int {
-	m := b.Len()
-	c := cap(b.buf)
-	buf := makeSlice(2 * c)
+func (b *readOnlyBuffer) grow() {
+	c := 2 * cap(b.buf)
+	if c == 0 {
+		c = 1024
+	}
+	buf := makeSlice(c)
 	copy(buf, b.buf[b.off:b.end])
 	b.buf = buf
 	b.end -= b.off
 	b.off = 0
-	return m
-}
-
-func (b *readOnlyBuffer) tryGrowByReslice(n int) (int, bool) {
-	if n == 0 {
-		b.buf = b.buf[:cap(b.buf)]
-		return 0, true
-	}
-
-	if l := len(b.buf); n <= cap(b.buf)-l {
-		b.buf = b.buf[:l+n]
-		return l, true
-	}
-	return 0, false
 }
 
 func (b *readOnlyBuffer) FreeBytes() []byte {
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"sync"
 
 	"github.com/amsalt/log"
@@ -12,12 +13,19 @@ type Acceptor struct {
 	*BaseChannel
 
 	// handle new subchannel.
-	initCb InitChannelCb
+	initCb    InitChannelCb
+	factories []handlerFactory
 
 	subChannels []SubChannel
 	channels    map[interface{}]SubChannel
+
+	shutdown bool
 }
 
+// ShutdownEvent is fired through every SubChannel's pipeline when the
+// AcceptorChannel shuts down, handlers can send the last messages on it.
+type ShutdownEvent struct{}
+
 // NewAcceptor create a Acceptor instance which can accept new connection from client.
 func NewAcceptor() *Acceptor {
 	acceptor := new(Acceptor)
@@ -35,8 +43,18 @@ func (acceptor *Acceptor) SubChannelInitializer() InitChannelCb {
 	return acceptor.initCb
 }
 
+// AddLastFactory adds the handler built by factory at the last of each new SubChannel,
+// after the ones added by InitSubChannel. The factory is called once per SubChannel,
+// so that the handlers not Sharable are never shared.
+func (acceptor *Acceptor) AddLastFactory(name string, factory func() interface{}) {
+	acceptor.factories = append(acceptor.factories, handlerFactory{name, factory})
+}
+
 func (acceptor *Acceptor) initChannel(c SubChannel) {
-	acceptor.initCb(c)
+	if acceptor.initCb != nil {
+		acceptor.initCb(c)
+	}
+	addFactories(c.Pipeline(), acceptor.factories)
 }
 
 // FireConnect fires a Connect event.
@@ -49,10 +67,18 @@ func (acceptor *Acceptor) FireConnect(channel Channel) InboundInvoker {
 	acceptor.initChannel(subChannel)
 
 	acceptor.Lock()
+	if acceptor.shutdown {
+		acceptor.Unlock()
+		log.Infof("Acceptor is shutting down, close the new channel: %+v", subChannel.RemoteAddr())
+		subChannel.Close()
+		return nil
+	}
 	acceptor.subChannels = append(acceptor.subChannels, subChannel)
 	acceptor.channels[subChannel.ID()] = subChannel
 	acceptor.Unlock()
-	return subChannel.Pipeline().FireConnect(subChannel)
+	invoker := subChannel.Pipeline().FireConnect(subChannel)
+	markInitialized(subChannel)
+	return invoker
 }
 
 // FireDisconnect fires a Disconnect event.
@@ -76,9 +102,28 @@ func (acceptor *Acceptor) FireError(err error) InboundInvoker {
 
 // ------------------- Channel Manager methods -------------------
 
+// RemoveSubChannel removes a closed SubChannel from this AcceptorChannel.
+func (acceptor *Acceptor) RemoveSubChannel(channel SubChannel) {
+	acceptor.Lock()
+	defer acceptor.Unlock()
+
+	delete(acceptor.channels, channel.ID())
+	for i, c := range acceptor.subChannels {
+		if c == channel {
+			acceptor.subChannels = append(acceptor.subChannels[:i], acceptor.subChannels[i+1:]...)
+			break
+		}
+	}
+}
+
 // SubChannels returns all SubChannels belong to this AcceptorChannel.
 func (acceptor *Acceptor) SubChannels() []SubChannel {
-	return acceptor.subChannels
+	acceptor.RLock()
+	defer acceptor.RUnlock()
+
+	channels := make([]SubChannel, len(acceptor.subChannels))
+	copy(channels, acceptor.subChannels)
+	return channels
 }
 
 // Broadcast broadcasts message to all client channels.
@@ -86,8 +131,9 @@ func (acceptor *Acceptor) SubChannels() []SubChannel {
 // For better performance, should only do FilterPipeline once for all Channel
 // and call RawConn().Write(msg) for less filter operations and less memory cost.
 func (acceptor *Acceptor) Broadcast(msg interface{}) error {
-	log.Debugf("acceptor subchannels: %+v", acceptor.subChannels)
-	for _, channel := range acceptor.subChannels {
+	channels := acceptor.SubChannels()
+	log.Debugf("acceptor subchannels: %+v", channels)
+	for _, channel := range channels {
 		err := channel.Write(msg)
 		if err != nil {
 			log.Errorf("Acceptor.Broadcast failed: %+v", err)
@@ -106,3 +152,40 @@ func (acceptor *Acceptor) Multicast(msg interface{}, channelIDs []interface{}) e
 
 	return nil
 }
+
+// IsShutdown returns whether the acceptor is shutting down.
+func (acceptor *Acceptor) IsShutdown() bool {
+	acceptor.RLock()
+	defer acceptor.RUnlock()
+	return acceptor.shutdown
+}
+
+// Shutdown fires a ShutdownEvent through every SubChannel's pipeline and closes
+// them gracefully, the new SubChannels will be closed immediately.
+// It returns when all SubChannels closed, or closes the rest forcibly and
+// returns ctx.Err() when the ctx done.
+// The transport should stop accepting before calling it.
+func (acceptor *Acceptor) Shutdown(ctx context.Context) error {
+	acceptor.Lock()
+	acceptor.shutdown = true
+	acceptor.Unlock()
+
+	channels := acceptor.SubChannels()
+	for _, channel := range channels {
+		channel.FireEvent(ShutdownEvent{})
+		channel.GracefullyClose()
+	}
+
+	for i, channel := range channels {
+		select {
+		case <-channel.CloseNotify():
+		case <-ctx.Done():
+			log.Warningf("Acceptor shutdown: %+v, close %d channels forcibly", ctx.Err(), len(channels)-i)
+			for _, c := range channels[i:] {
+				c.Close()
+			}
+			return ctx.Err()
+		}
+	}
+	return nil
+}
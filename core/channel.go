@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"net"
 
 	"github.com/amsalt/log"
@@ -48,11 +49,20 @@ type AcceptorChannel interface {
 
 	SubChannelInitializer() InitChannelCb
 
+	// AddLastFactory adds the handler built by factory at the last of each new SubChannel.
+	AddLastFactory(name string, factory func() interface{})
+
 	// Listen announces on the local network address.
 	Listen(addr net.Addr)
 
 	// Accept accepts the next incoming call
 	Accept()
+
+	// Shutdown stops accepting, fires a ShutdownEvent through every SubChannel's
+	// pipeline and closes them gracefully after the pending messages sent.
+	// It returns when all SubChannels closed, or closes the rest forcibly and
+	// returns ctx.Err() when the ctx done.
+	Shutdown(ctx context.Context) error
 }
 
 // ChannelMgr represents a manager of Channel.
@@ -77,8 +87,23 @@ type ConnectorChannel interface {
 
 	SubChannelInitializer() InitChannelCb
 
+	// AddLastFactory adds the handler built by factory at the last of the SubChannel connected.
+	AddLastFactory(name string, factory func() interface{})
+
 	// Connect connects to the special address.
 	Connect(addr interface{}) (SubChannel, error)
+
+	// WriteAsync writes message by the connected SubChannel, see SubChannel.WriteAsync.
+	WriteAsync(msg interface{}, extra ...interface{}) WriteFuture
+
+	// WriteAndFlush writes message by the connected SubChannel, see SubChannel.WriteAndFlush.
+	WriteAndFlush(msg interface{}, extra ...interface{}) error
+
+	// WriteContext writes message by the connected SubChannel, see SubChannel.WriteContext.
+	WriteContext(ctx context.Context, msg interface{}, extra ...interface{}) error
+
+	// IsWritable returns the writability of the connected SubChannel.
+	IsWritable() bool
 }
 
 // SubChannel represents a server-side connection connected by a client.
@@ -86,8 +111,27 @@ type SubChannel interface {
 	Channel
 	InboundInvoker
 
+	// WriteAsync writes message to opposite side, returns a WriteFuture which is completed
+	// after the message written to the connection, or failed with the error.
+	WriteAsync(msg interface{}, extra ...interface{}) WriteFuture
+
+	// WriteAndFlush writes message to opposite side and flushes it without waiting for
+	// coalescing with the messages queued after it, Write may coalesce them into one write.
+	WriteAndFlush(msg interface{}, extra ...interface{}) error
+
+	// WriteContext writes message to opposite side, blocks until there is room
+	// in the write queue or the ctx done.
+	WriteContext(ctx context.Context, msg interface{}, extra ...interface{}) error
+
+	// IsWritable returns false if the pending outbound bytes exceeded the high watermark,
+	// a WritabilityChangedEvent is fired when it changed.
+	IsWritable() bool
+
 	// GracefullyClose closes gracefully with all message sent before close.
 	GracefullyClose()
+
+	// CloseNotify returns a channel which is closed when the SubChannel closed.
+	CloseNotify() <-chan byte
 }
 
 type defaultIDGenerator struct {
@@ -5,6 +5,9 @@ import "strings"
 var (
 	// m is a map from name to channel builder.
 	m = make(map[string]Builder)
+
+	// cm is a map from name to connector builder.
+	cm = make(map[string]ConnectorBuilder)
 )
 
 const (
@@ -16,6 +19,33 @@ const (
 
 	// UDPServBuilder represents udp server builder
 	UDPServBuilder = "s_udp"
+
+	// RUDPServBuilder represents reliable udp server builder
+	RUDPServBuilder = "s_rudp"
+
+	// UnixServBuilder represents unix domain socket server builder
+	UnixServBuilder = "s_unix"
+
+	// LocalServBuilder represents in-process local server builder
+	LocalServBuilder = "s_local"
+
+	// TCPCliBuilder represents tcp client builder
+	TCPCliBuilder = "c_tcp"
+
+	// WebsocketCliBuilder represents websocket client builder
+	WebsocketCliBuilder = "c_websocket"
+
+	// UDPCliBuilder represents udp client builder
+	UDPCliBuilder = "c_udp"
+
+	// RUDPCliBuilder represents reliable udp client builder
+	RUDPCliBuilder = "c_rudp"
+
+	// UnixCliBuilder represents unix domain socket client builder
+	UnixCliBuilder = "c_unix"
+
+	// LocalCliBuilder represents in-process local client builder
+	LocalCliBuilder = "c_local"
 )
 
 // BuildOption represents transport builder option.
@@ -41,3 +71,24 @@ type Builder interface {
 func GetAcceptorBuilder(n string) Builder {
 	return m[strings.ToLower(n)]
 }
+
+// RegisterConnector registers the connector(e.g. TcpConnector) builder to the connector map.
+// b.Name (lowercased) will be used as the name registered with this builder.
+//
+// NOTE: this function must only be called during initialization time (i.e. in
+// an init() function), and is not thread-safe. If multiple Connector are
+// registered with the same name, the one registered last will take effect.
+func RegisterConnector(b ConnectorBuilder) {
+	cm[strings.ToLower(b.Name())] = b
+}
+
+// ConnectorBuilder builds client transport.
+type ConnectorBuilder interface {
+	Build(opts ...BuildOption) ConnectorChannel
+	Name() string
+}
+
+// GetConnectorBuilder returns connector builder by name, ignore case.
+func GetConnectorBuilder(n string) ConnectorBuilder {
+	return cm[strings.ToLower(n)]
+}
@@ -1,6 +1,8 @@
 package core
 
 import (
+	"sync"
+
 	"github.com/amsalt/log"
 	"github.com/amsalt/nginet/bytes"
 )
@@ -19,12 +21,21 @@ type ChannelContext struct {
 	// to reduce type assertion when invoke.
 	inHandler  InboundHandler
 	outHandler OutboundHandler
+	handler    interface{}
+
+	// mutex of the pipeline guards prev and next.
+	mutex *sync.RWMutex
 
 	inbound  bool
 	outbound bool
 
 	name     string
 	executor Executor
+
+	// origin is the context of the pipeline if this one is passed to OnWrite
+	// with the promise of a message, see withPromise.
+	origin  *ChannelContext
+	promise WritePromise
 }
 
 // newChannelContext creates a new ChannelContext
@@ -42,6 +53,10 @@ func newChannelContext(executor Executor, name string, pipeline ChannelPipeline,
 }
 func (ctx *ChannelContext) init(pipeline ChannelPipeline, handler interface{}) {
 	ctx.pipeline = pipeline
+	ctx.handler = handler
+	if cp, ok := pipeline.(*channelPipeline); ok {
+		ctx.mutex = &cp.mutex
+	}
 
 	in, ok := handler.(InboundHandler)
 	ctx.inbound = ok
@@ -57,8 +72,13 @@ func (ctx *ChannelContext) init(pipeline ChannelPipeline, handler interface{}) {
 }
 
 func (ctx *ChannelContext) findNextInboundContext() *ChannelContext {
+	if ctx.mutex != nil {
+		ctx.mutex.RLock()
+		defer ctx.mutex.RUnlock()
+	}
+
 	var next *ChannelContext
-	next = ctx.next
+	next = ctx.node().next
 	for !next.inbound { // the last one is TailContext.
 		next = next.next
 	}
@@ -67,8 +87,13 @@ func (ctx *ChannelContext) findNextInboundContext() *ChannelContext {
 }
 
 func (ctx *ChannelContext) findNextOutboundContext() *ChannelContext {
+	if ctx.mutex != nil {
+		ctx.mutex.RLock()
+		defer ctx.mutex.RUnlock()
+	}
+
 	var prev *ChannelContext
-	prev = ctx.prev
+	prev = ctx.node().prev
 	for !prev.outbound { // the last one is HeadContext.
 		prev = prev.prev
 	}
@@ -76,6 +101,31 @@ func (ctx *ChannelContext) findNextOutboundContext() *ChannelContext {
 	return prev
 }
 
+// node returns the context linked in the pipeline.
+func (ctx *ChannelContext) node() *ChannelContext {
+	if ctx.origin != nil {
+		return ctx.origin
+	}
+	return ctx
+}
+
+// withPromise returns the context passed to OnWrite, which carries the promise of the
+// message, so that the message forwarded later or from another goroutine by it still
+// completes the right promise.
+func (ctx *ChannelContext) withPromise(promise WritePromise) *ChannelContext {
+	if promise == nil {
+		return ctx
+	}
+	if ctx.mutex != nil {
+		ctx.mutex.RLock()
+		defer ctx.mutex.RUnlock()
+	}
+	wctx := *ctx
+	wctx.origin = ctx
+	wctx.promise = promise
+	return &wctx
+}
+
 func (ctx *ChannelContext) Channel() Channel {
 	return ctx.pipeline.Channel()
 }
@@ -92,6 +142,26 @@ func (ctx *ChannelContext) Executor() Executor {
 	return ctx.executor
 }
 
+// Handler returns the handler of this context.
+func (ctx *ChannelContext) Handler() interface{} {
+	return ctx.handler
+}
+
+// WritePromise returns the promise of the message being written, nil if none. The ctx passed
+// to OnWrite is bound to the message, the promise follows the message forwarded by its FireWrite,
+// even if called after OnWrite returned or from another goroutine. A handler which consumes
+// the message without forwarding should complete it, or call FailWrite.
+func (ctx *ChannelContext) WritePromise() WritePromise {
+	return ctx.promise
+}
+
+// FailWrite fails the promise of the message being written with err and fires err,
+// call it in OnWrite instead of FireError when the message is dropped.
+func (ctx *ChannelContext) FailWrite(err error) {
+	completePromise(ctx.promise, err)
+	ctx.FireError(err)
+}
+
 func (ctx *ChannelContext) FireConnect(channel Channel) InboundInvoker {
 	invokeConnect0(ctx.findNextInboundContext(), channel)
 	return ctx
@@ -195,24 +265,31 @@ func (ctx *ChannelContext) Close() OutboundInvoker {
 }
 
 func (ctx *ChannelContext) FireWrite(msg interface{}) OutboundInvoker {
-	invokeWrite0(ctx.findNextOutboundContext(), msg)
+	invokeWrite0(ctx.findNextOutboundContext(), msg, ctx.promise)
+	return ctx
+}
+
+// FireWriteWithPromise fires a write event with the promise of msg, which is used by the
+// handler forwards a message held with its promise, e.g. the messages queued until ready.
+func (ctx *ChannelContext) FireWriteWithPromise(msg interface{}, promise WritePromise) OutboundInvoker {
+	invokeWrite0(ctx.findNextOutboundContext(), msg, promise)
 	return ctx
 }
 
-func invokeWrite0(next *ChannelContext, msg interface{}) {
+func invokeWrite0(next *ChannelContext, msg interface{}, promise WritePromise) {
 	log.Debugf("invokeWrite0 execute handler: %+v", next.Name())
 	if next.Executor() != nil {
 		// run in the special executor all that called after this handler, include this one.
 		next.Executor().Execute(func() {
-			next.doWrite(msg)
+			next.doWrite(msg, promise)
 		})
 	} else {
-		next.doWrite(msg)
+		next.doWrite(msg, promise)
 	}
 }
 
-func (ctx *ChannelContext) doWrite(msg interface{}) {
-	ctx.outHandler.OnWrite(ctx, msg)
+func (ctx *ChannelContext) doWrite(msg interface{}, promise WritePromise) {
+	ctx.outHandler.OnWrite(ctx.withPromise(promise), msg)
 }
 
 type DefaultChannelContext struct {
@@ -269,16 +346,37 @@ func (hctx *HeadContext) OnEvent(ctx *ChannelContext, event interface{}) {
 // OnWrite processes a write event.
 func (hctx *HeadContext) OnWrite(ctx *ChannelContext, msg interface{}) {
 	if msg == nil {
+		completePromise(ctx.promise, nil)
 		return
 	}
 
 	data, ok := msg.([]byte)
-	if ok {
-		ctx.Channel().RawConn().Write(data)
-	} else if data, ok := msg.(bytes.WriteOnlyBuffer); ok {
-		ctx.Channel().RawConn().Write(data.Bytes())
-	} else {
-		log.Errorf("HeadContext.OnWrite write with unsupported type: %T", msg)
+	if !ok {
+		if buf, ok := msg.(bytes.WriteOnlyBuffer); ok {
+			data = buf.Bytes()
+		} else {
+			log.Errorf("HeadContext.OnWrite write with unsupported type: %T", msg)
+			completePromise(ctx.promise, ErrUnsupportedMessage)
+			return
+		}
+	}
+
+	conn := ctx.Channel().RawConn()
+	if ob, ok := ctx.Channel().(outboundBatcher); ok && ob.appendOutbound(conn, data, ctx.promise) {
+		// flushed by the writeloop at the end of batch.
+		return
+	}
+
+	err := conn.Write(data)
+	completePromise(ctx.promise, err)
+	if err != nil {
+		log.Errorf("HeadContext.OnWrite write err: %+v", err)
+		if wf, ok := ctx.Channel().(writeFailer); ok {
+			wf.writeFailed(conn, err)
+		} else {
+			ctx.Channel().Pipeline().FireError(err)
+			ctx.Channel().Close()
+		}
 	}
 }
 
@@ -1,6 +1,10 @@
 package core
 
-import "github.com/amsalt/log"
+import (
+	"sync"
+
+	"github.com/amsalt/log"
+)
 
 // ChannelPipeline is a list of ChannelHandler which handles or intercepts inbound events and outbound operations of a
 // ChannelPipeline implements an advanced form of the Intercepting Filter pattern
@@ -11,18 +15,57 @@ type ChannelPipeline interface {
 	InboundInvoker
 	OutboundInvoker
 
+	// AddFirst, AddLast, AddAfter and AddBefore add the handler with name. Adding a non-Sharable
+	// handler attached to another pipeline fires the error and closes the channel, see Sharable.
 	AddFirst(executor Executor, name string, h interface{})
 	AddLast(executor Executor, name string, h interface{})
 	AddAfter(afterName string, executor Executor, name string, handler interface{})
 	AddBefore(beforeName string, executor Executor, name string, handler interface{})
+
+	// Remove removes the handler with name, returns the removed handler or nil if not found.
+	Remove(name string) interface{}
+
+	// Replace replaces the handler with oldName by a new handler,
+	// returns the replaced handler or nil if not found.
+	Replace(oldName string, executor Executor, newName string, handler interface{}) interface{}
+
+	// Get returns the handler with name, nil if not found.
+	Get(name string) interface{}
+
+	// Context returns the ChannelContext of the handler with name, nil if not found.
+	Context(name string) *ChannelContext
+
+	// Names returns the names of all handlers from head to tail.
+	Names() []string
+
+	// First returns the first handler, nil if the pipeline is empty.
+	First() interface{}
+
+	// Last returns the last handler, nil if the pipeline is empty.
+	Last() interface{}
+
 	Channel() Channel
+
+	// FireWriteWithPromise fires a write event, the promise is completed when
+	// the message written to the RawConn.
+	FireWriteWithPromise(msg interface{}, promise WritePromise) OutboundInvoker
 }
 
+// channelPipeline is safe to be modified while events are flowing,
+// mutex guards the links of contexts. A removed context keeps its links,
+// so that the event passing through it goes on to the rest.
 type channelPipeline struct {
 	channel Channel
+	mutex   sync.RWMutex
+
+	// executors caches the executor chosen by each ExecutorGroup for the channel.
+	executors map[ExecutorGroup]Executor
 
 	head *HeadContext
 	tail *TailContext
+
+	// destroyed is set when the channel closed, no more handlers are added.
+	destroyed bool
 }
 
 // NewChannelPipeline creates a new ChannelPipeline instance.
@@ -74,36 +117,273 @@ func (cp *channelPipeline) FireWrite(msg interface{}) OutboundInvoker {
 	return cp
 }
 
+func (cp *channelPipeline) FireWriteWithPromise(msg interface{}, promise WritePromise) OutboundInvoker {
+	invokeWrite0(cp.tail.findNextOutboundContext(), msg, promise)
+	return cp
+}
+
 func (cp *channelPipeline) AddFirst(executor Executor, name string, handler interface{}) {
-	newCtx := NewDefaultChannelContext(executor, name, cp, handler)
-	cp.addFirst0(newCtx.ChannelContext)
+	if !cp.attach(name, handler) {
+		return
+	}
+	newCtx := NewDefaultChannelContext(cp.childExecutor(executor), name, cp, handler)
+	cp.mutex.Lock()
+	added := !cp.destroyed
+	if added {
+		cp.addFirst0(newCtx.ChannelContext)
+	}
+	cp.mutex.Unlock()
+
+	if added {
+		callHandlerAdded(newCtx.ChannelContext)
+	} else {
+		detachHandler(handler)
+	}
 }
 
 func (cp *channelPipeline) AddLast(executor Executor, name string, handler interface{}) {
-	newCtx := NewDefaultChannelContext(executor, name, cp, handler)
-	cp.addLast0(newCtx.ChannelContext)
+	if !cp.attach(name, handler) {
+		return
+	}
+	newCtx := NewDefaultChannelContext(cp.childExecutor(executor), name, cp, handler)
+	cp.mutex.Lock()
+	added := !cp.destroyed
+	if added {
+		cp.addLast0(newCtx.ChannelContext)
+	}
+	cp.mutex.Unlock()
+
+	if added {
+		callHandlerAdded(newCtx.ChannelContext)
+	} else {
+		detachHandler(handler)
+	}
 }
 
 func (cp *channelPipeline) AddAfter(afterName string, executor Executor, name string, handler interface{}) {
-	newCtx := NewDefaultChannelContext(executor, name, cp, handler)
-	cp.addAfter0(afterName, newCtx.ChannelContext)
+	if !cp.attach(name, handler) {
+		return
+	}
+	newCtx := NewDefaultChannelContext(cp.childExecutor(executor), name, cp, handler)
+	cp.mutex.Lock()
+	added := !cp.destroyed && cp.addAfter0(afterName, newCtx.ChannelContext)
+	cp.mutex.Unlock()
+
+	if added {
+		callHandlerAdded(newCtx.ChannelContext)
+	} else {
+		detachHandler(handler)
+	}
+	cp.printHandlers()
+}
 
-	ctx := cp.head.ChannelContext
-	for ctx != nil {
-		if ctx.Name() == "HeadContext" {
-			log.Debugf("+--------------------ChannelHandler list------------------------+")
-		} else if ctx.Name() == "TailContext" {
-			log.Debugf("+---------------------------------------------------------------+")
-		} else {
-			log.Debugf("+------ name:%-22s isInbound: %-4v isOutbound: %-4v", ctx.Name(), ctx.inbound, ctx.outbound)
+func (cp *channelPipeline) AddBefore(beforeName string, executor Executor, name string, handler interface{}) {
+	if !cp.attach(name, handler) {
+		return
+	}
+	newCtx := NewDefaultChannelContext(cp.childExecutor(executor), name, cp, handler)
+	cp.mutex.Lock()
+	added := !cp.destroyed && cp.addBefore0(beforeName, newCtx.ChannelContext)
+	cp.mutex.Unlock()
+
+	if added {
+		callHandlerAdded(newCtx.ChannelContext)
+	} else {
+		detachHandler(handler)
+	}
+	cp.printHandlers()
+}
+
+func (cp *channelPipeline) Remove(name string) interface{} {
+	cp.mutex.Lock()
+	ctx := cp.context0(name)
+	if ctx != nil {
+		ctx.prev.next = ctx.next
+		ctx.next.prev = ctx.prev
+	}
+	cp.mutex.Unlock()
+
+	if ctx == nil {
+		log.Warningf("ChannelPipline remove failed not found name: %+v", name)
+		return nil
+	}
+	detachHandler(ctx.handler)
+	callHandlerRemoved(ctx)
+	cp.printHandlers()
+	return ctx.handler
+}
+
+func (cp *channelPipeline) Replace(oldName string, executor Executor, newName string, handler interface{}) interface{} {
+	if !cp.attach(newName, handler) {
+		return nil
+	}
+	newCtx := NewDefaultChannelContext(cp.childExecutor(executor), newName, cp, handler).ChannelContext
+
+	cp.mutex.Lock()
+	var oldCtx *ChannelContext
+	if !cp.destroyed {
+		oldCtx = cp.context0(oldName)
+	}
+	if oldCtx != nil {
+		newCtx.prev = oldCtx.prev
+		newCtx.next = oldCtx.next
+		oldCtx.prev.next = newCtx
+		oldCtx.next.prev = newCtx
+	}
+	cp.mutex.Unlock()
+
+	if oldCtx == nil {
+		log.Warningf("ChannelPipline replace failed not found name: %+v", oldName)
+		detachHandler(handler)
+		return nil
+	}
+	detachHandler(oldCtx.handler)
+	callHandlerAdded(newCtx)
+	callHandlerRemoved(oldCtx)
+	cp.printHandlers()
+	return oldCtx.handler
+}
+
+func (cp *channelPipeline) Get(name string) interface{} {
+	if ctx := cp.Context(name); ctx != nil {
+		return ctx.handler
+	}
+	return nil
+}
+
+func (cp *channelPipeline) Context(name string) *ChannelContext {
+	cp.mutex.RLock()
+	defer cp.mutex.RUnlock()
+	return cp.context0(name)
+}
+
+func (cp *channelPipeline) Names() []string {
+	cp.mutex.RLock()
+	defer cp.mutex.RUnlock()
+
+	var names []string
+	for ctx := cp.head.next; ctx != cp.tail.ChannelContext; ctx = ctx.next {
+		names = append(names, ctx.name)
+	}
+	return names
+}
+
+func (cp *channelPipeline) First() interface{} {
+	cp.mutex.RLock()
+	defer cp.mutex.RUnlock()
+
+	if ctx := cp.head.next; ctx != cp.tail.ChannelContext {
+		return ctx.handler
+	}
+	return nil
+}
+
+func (cp *channelPipeline) Last() interface{} {
+	cp.mutex.RLock()
+	defer cp.mutex.RUnlock()
+
+	if ctx := cp.tail.prev; ctx != cp.head.ChannelContext {
+		return ctx.handler
+	}
+	return nil
+}
+
+// destroy removes all handlers from tail to head when the channel closed.
+func (cp *channelPipeline) destroy() {
+	cp.mutex.Lock()
+	cp.destroyed = true
+	var removed []*ChannelContext
+	for ctx := cp.tail.prev; ctx != cp.head.ChannelContext; ctx = ctx.prev {
+		removed = append(removed, ctx)
+	}
+	cp.head.next = cp.tail.ChannelContext
+	cp.tail.prev = cp.head.ChannelContext
+	cp.mutex.Unlock()
+
+	for _, ctx := range removed {
+		detachHandler(ctx.handler)
+		callHandlerRemoved(ctx)
+	}
+}
+
+// attach attaches the handler to the pipeline. The handler refused fails the channel
+// with the error fired and closed, it never runs with an incomplete pipeline.
+func (cp *channelPipeline) attach(name string, handler interface{}) bool {
+	err := attachHandler(handler)
+	if err == nil {
+		return true
+	}
+
+	log.Errorf("ChannelPipeline add %+v failed: %+v", name, err)
+	cp.FireError(err)
+	if cp.channel != nil {
+		cp.channel.Close()
+	}
+	return false
+}
+
+// childExecutor returns the executor chosen for the channel if executor is an ExecutorGroup.
+func (cp *channelPipeline) childExecutor(executor Executor) Executor {
+	group, ok := executor.(ExecutorGroup)
+	if !ok {
+		return executor
+	}
+
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+	if child, ok := cp.executors[group]; ok {
+		return child
+	}
+	if cp.executors == nil {
+		cp.executors = make(map[ExecutorGroup]Executor)
+	}
+	child := group.Next(cp.channel)
+	cp.executors[group] = child
+	return child
+}
+
+// pipelineDestroyer is implemented by the pipeline which removes all handlers when the channel closed.
+type pipelineDestroyer interface {
+	destroy()
+}
+
+func callHandlerAdded(ctx *ChannelContext) {
+	lh, ok := ctx.handler.(LifecycleHandler)
+	if !ok {
+		return
+	}
+	if ctx.executor != nil {
+		ctx.executor.Execute(func() { lh.HandlerAdded(ctx) })
+	} else {
+		lh.HandlerAdded(ctx)
+	}
+}
+
+func callHandlerRemoved(ctx *ChannelContext) {
+	lh, ok := ctx.handler.(LifecycleHandler)
+	if !ok {
+		return
+	}
+	if ctx.executor != nil {
+		ctx.executor.Execute(func() { lh.HandlerRemoved(ctx) })
+	} else {
+		lh.HandlerRemoved(ctx)
+	}
+}
+
+// context0 returns the first context with name except head and tail, called with mutex locked.
+func (cp *channelPipeline) context0(name string) *ChannelContext {
+	for ctx := cp.head.next; ctx != cp.tail.ChannelContext; ctx = ctx.next {
+		if ctx.name == name {
+			return ctx
 		}
-		ctx = ctx.next
 	}
+	return nil
 }
 
-func (cp *channelPipeline) AddBefore(beforeName string, executor Executor, name string, handler interface{}) {
-	newCtx := NewDefaultChannelContext(executor, name, cp, handler)
-	cp.addBefore0(beforeName, newCtx.ChannelContext)
+func (cp *channelPipeline) printHandlers() {
+	cp.mutex.RLock()
+	defer cp.mutex.RUnlock()
 
 	ctx := cp.head.ChannelContext
 	for ctx != nil {
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"net"
 
 	"github.com/amsalt/log"
@@ -9,6 +10,7 @@ import (
 type Connector struct {
 	*BaseChannel
 	initCb     InitChannelCb
+	factories  []handlerFactory
 	subChannel SubChannel
 }
 
@@ -26,8 +28,17 @@ func (c *Connector) SubChannelInitializer() InitChannelCb {
 	return c.initCb
 }
 
+// AddLastFactory adds the handler built by factory at the last of the SubChannel
+// connected, after the ones added by InitSubChannel.
+func (c *Connector) AddLastFactory(name string, factory func() interface{}) {
+	c.factories = append(c.factories, handlerFactory{name, factory})
+}
+
 func (c *Connector) initChannel(channel SubChannel) {
-	c.initCb(channel)
+	if c.initCb != nil {
+		c.initCb(channel)
+	}
+	addFactories(channel.Pipeline(), c.factories)
 	c.subChannel = channel
 }
 
@@ -39,7 +50,9 @@ func (c *Connector) FireConnect(channel Channel) InboundInvoker {
 	}
 	c.initChannel(subChannel)
 
-	return channel.Pipeline().FireConnect(channel)
+	invoker := channel.Pipeline().FireConnect(channel)
+	markInitialized(subChannel)
+	return invoker
 }
 func (c *Connector) FireDisconnect() InboundInvoker {
 	log.Errorf("Connector connection disconnected.")
@@ -64,6 +77,26 @@ func (c *Connector) Write(msg interface{}, extra ...interface{}) error {
 	return c.subChannel.Write(msg, extra...)
 }
 
+// WriteAsync writes message by the connected SubChannel.
+func (c *Connector) WriteAsync(msg interface{}, extra ...interface{}) WriteFuture {
+	return c.subChannel.WriteAsync(msg, extra...)
+}
+
+// WriteAndFlush writes message by the connected SubChannel and flushes it.
+func (c *Connector) WriteAndFlush(msg interface{}, extra ...interface{}) error {
+	return c.subChannel.WriteAndFlush(msg, extra...)
+}
+
+// WriteContext writes message by the connected SubChannel, blocks until there is room.
+func (c *Connector) WriteContext(ctx context.Context, msg interface{}, extra ...interface{}) error {
+	return c.subChannel.WriteContext(ctx, msg, extra...)
+}
+
+// IsWritable returns the writability of the connected SubChannel.
+func (c *Connector) IsWritable() bool {
+	return c.subChannel.IsWritable()
+}
+
 // LocalAddr returns the local addr.
 func (c *Connector) LocalAddr() net.Addr {
 	return c.subChannel.LocalAddr()
@@ -77,3 +110,8 @@ func (c *Connector) RemoteAddr() net.Addr {
 func (c *Connector) Close() {
 	c.subChannel.Close()
 }
+
+// SubChannel returns the connected SubChannel, nil if not connected.
+func (c *Connector) SubChannel() SubChannel {
+	return c.subChannel
+}
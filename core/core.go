@@ -23,7 +23,9 @@ import (
 
 type RawConn interface {
 	Read(buf bytes.ReadOnlyBuffer) error
-	Write(data []byte)
+
+	// Write writes data to the connection, returns the error of writing.
+	Write(data []byte) error
 
 	// Close closes the connection.
 	// Any blocked Read or Write operations will be unblocked and return errors.
@@ -34,8 +36,6 @@ type RawConn interface {
 
 	// RemoteAddr returns the remote network address.
 	RemoteAddr() net.Addr
-
-	SetConn(conn net.Conn)
 }
 
 type ChannelIDGenerator interface {
@@ -46,6 +46,17 @@ type Executor interface {
 	Execute(task func())
 }
 
+// ExecutorGroup is an Executor consists of multiple executors, such as aio.EventLoopGroup.
+// The handlers added to a pipeline with an ExecutorGroup run in the executor chosen
+// for the channel, all of them share the same one during the lifetime of the channel,
+// which keeps the events of one channel in order.
+type ExecutorGroup interface {
+	Executor
+
+	// Next chooses an executor for the channel.
+	Next(channel Channel) Executor
+}
+
 // InboundInvoker invokes inbound event handler.
 type InboundInvoker interface {
 	// FireConnect fire a connect event when new channel created.
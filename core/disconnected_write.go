@@ -0,0 +1,185 @@
+package core
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/amsalt/log"
+)
+
+var (
+	// ErrReconnecting represents the message is refused for the channel is reconnecting.
+	ErrReconnecting = errors.New("channel is reconnecting")
+
+	// ErrReconnectBufferFull represents the buffer for messages written while reconnecting is full.
+	ErrReconnectBufferFull = errors.New("reconnect buffer is full")
+)
+
+// DisconnectedWritePolicy decides how to handle the messages written while reconnecting.
+type DisconnectedWritePolicy int
+
+const (
+	// BufferWrites buffers the messages and replays them after reconnected.
+	BufferWrites DisconnectedWritePolicy = iota
+
+	// DropWrites drops the messages silently.
+	DropWrites
+
+	// FailFastWrites refuses the messages with ErrReconnecting.
+	FailFastWrites
+)
+
+const (
+	defaultMaxBufferedMessages = 1024
+
+	// unknownMessageSize is the estimated size of message with unknown type.
+	unknownMessageSize = 8
+)
+
+// MessageSizeEstimator estimates the size in bytes of a message.
+type MessageSizeEstimator func(msg interface{}) int
+
+// ReplayFilter decides whether a buffered message should be replayed after reconnected,
+// bufferedAt is the time when it was buffered. Returns false to drop the stale message.
+type ReplayFilter func(msg interface{}, bufferedAt time.Time) bool
+
+// DisconnectedWriteOpts represents the options of handling the messages written while reconnecting.
+type DisconnectedWriteOpts struct {
+	Policy DisconnectedWritePolicy
+
+	// MaxMessages limits the number of buffered messages, defaults to 1024.
+	MaxMessages int
+
+	// MaxBytes limits the estimated bytes of buffered messages, 0 means no limit.
+	MaxBytes int
+
+	// SizeEstimator estimates the message size for MaxBytes, defaults to DefaultMessageSizeEstimator.
+	SizeEstimator MessageSizeEstimator
+
+	// ReplayFilter filters out the stale messages before replaying, replays all if nil.
+	ReplayFilter ReplayFilter
+
+	// ManualReplay delays replaying until Replayer.Replay called, so that handlers can
+	// finish the login handshake on ReconnectedEvent first. The handshake messages
+	// should be sent by ChannelContext.FireWrite, which bypasses the write queue.
+	ManualReplay bool
+}
+
+// Replayer is implemented by the SubChannel which replays the messages buffered while reconnecting.
+type Replayer interface {
+	// Replay replays the buffered messages and resumes writing,
+	// only need to be called when DisconnectedWriteOpts.ManualReplay is set.
+	Replay()
+}
+
+// DefaultMessageSizeEstimator estimates the size of []byte, string, types with Len() method
+// and the combined messages, other types are counted as 8 bytes.
+func DefaultMessageSizeEstimator(msg interface{}) int {
+	switch m := msg.(type) {
+	case []byte:
+		return len(m)
+	case string:
+		return len(m)
+	case interface{ Len() int }:
+		return m.Len()
+	case []interface{}:
+		size := 0
+		for _, v := range m {
+			size += DefaultMessageSizeEstimator(v)
+		}
+		return size
+	}
+	return unknownMessageSize
+}
+
+type bufferedMessage struct {
+	pendingWrite
+	size int
+	at   time.Time
+}
+
+// reconnectBuffer holds the messages written while reconnecting,
+// only accessed in writeloop except isFull.
+type reconnectBuffer struct {
+	opts DisconnectedWriteOpts
+
+	messages []bufferedMessage
+	bytes    int
+	full     int32
+}
+
+func newReconnectBuffer(opts *DisconnectedWriteOpts) *reconnectBuffer {
+	rb := &reconnectBuffer{}
+	if opts != nil {
+		rb.opts = *opts
+	}
+	if rb.opts.MaxMessages <= 0 {
+		rb.opts.MaxMessages = defaultMaxBufferedMessages
+	}
+	if rb.opts.SizeEstimator == nil {
+		rb.opts.SizeEstimator = DefaultMessageSizeEstimator
+	}
+	return rb
+}
+
+// add handles a message written while reconnecting by the policy,
+// the promise is failed if the message dropped.
+func (rb *reconnectBuffer) add(msg interface{}, promise WritePromise) {
+	switch rb.opts.Policy {
+	case DropWrites:
+		log.Debugf("drop message while reconnecting: %T", msg)
+		completePromise(promise, ErrMessageDropped)
+		return
+	case FailFastWrites:
+		// the message queued before disconnected.
+		log.Warningf("drop message queued before disconnected: %T", msg)
+		completePromise(promise, ErrReconnecting)
+		return
+	}
+
+	size := rb.opts.SizeEstimator(msg)
+	if len(rb.messages) >= rb.opts.MaxMessages || (rb.opts.MaxBytes > 0 && rb.bytes+size > rb.opts.MaxBytes) {
+		log.Warningf("reconnect buffer is full, drop message: %T", msg)
+		completePromise(promise, ErrReconnectBufferFull)
+		return
+	}
+	rb.messages = append(rb.messages, bufferedMessage{pendingWrite: pendingWrite{msg: msg, promise: promise}, size: size, at: time.Now()})
+	rb.bytes += size
+	if len(rb.messages) >= rb.opts.MaxMessages || (rb.opts.MaxBytes > 0 && rb.bytes >= rb.opts.MaxBytes) {
+		atomic.StoreInt32(&rb.full, 1)
+	}
+}
+
+func (rb *reconnectBuffer) isFull() bool {
+	return atomic.LoadInt32(&rb.full) == 1
+}
+
+// take returns the messages to be replayed and empties the buffer.
+func (rb *reconnectBuffer) take() []pendingWrite {
+	msgs := make([]pendingWrite, 0, len(rb.messages))
+	for _, m := range rb.messages {
+		if rb.opts.ReplayFilter != nil && !rb.opts.ReplayFilter(m.msg, m.at) {
+			log.Debugf("drop stale message: %T", m.msg)
+			completePromise(m.promise, ErrMessageDropped)
+			continue
+		}
+		msgs = append(msgs, m.pendingWrite)
+	}
+	rb.reset()
+	return msgs
+}
+
+// fail fails the promises of all buffered messages and empties the buffer.
+func (rb *reconnectBuffer) fail(err error) {
+	for _, m := range rb.messages {
+		completePromise(m.promise, err)
+	}
+	rb.reset()
+}
+
+func (rb *reconnectBuffer) reset() {
+	rb.messages = nil
+	rb.bytes = 0
+	atomic.StoreInt32(&rb.full, 0)
+}
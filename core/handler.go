@@ -27,6 +27,17 @@ type OutboundHandler interface {
 	OnWrite(ctx *ChannelContext, msg interface{})
 }
 
+// LifecycleHandler is implemented optionally by handlers which need to be notified
+// when added to or removed from a ChannelPipeline. The handlers are removed when
+// the channel closed. Called in the executor of the handler if any.
+type LifecycleHandler interface {
+	// HandlerAdded called after the handler added to the pipeline.
+	HandlerAdded(ctx *ChannelContext)
+
+	// HandlerRemoved called after the handler removed from the pipeline.
+	HandlerRemoved(ctx *ChannelContext)
+}
+
 type DefaultInboundHandler struct{}
 
 func NewDefaultInboundHandler() *DefaultInboundHandler {
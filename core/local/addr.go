@@ -0,0 +1,71 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Network is the network name of local address.
+const Network = "local"
+
+var (
+	// ErrAddrInUse represents the address is listened by another acceptor.
+	ErrAddrInUse = errors.New("local: address already in use")
+
+	// ErrConnRefused represents no acceptor listens on the address or the backlog is full.
+	ErrConnRefused = errors.New("local: connection refused")
+)
+
+// Addr represents a named in-memory address, implements net.Addr.
+type Addr string
+
+// Network returns "local".
+func (a Addr) Network() string {
+	return Network
+}
+
+func (a Addr) String() string {
+	return string(a)
+}
+
+var ephemeralID uint64
+
+// ephemeralAddr returns a unique address for the connecting side.
+func ephemeralAddr() Addr {
+	return Addr(fmt.Sprintf("local:E%d", atomic.AddUint64(&ephemeralID, 1)))
+}
+
+// registry holds the listening acceptors by address name.
+var registry = struct {
+	sync.RWMutex
+	servers map[string]*server
+}{servers: make(map[string]*server)}
+
+func bind(name string, s *server) error {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.servers[name]; ok {
+		return ErrAddrInUse
+	}
+	registry.servers[name] = s
+	return nil
+}
+
+func unbind(name string, s *server) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if registry.servers[name] == s {
+		delete(registry.servers, name)
+	}
+}
+
+func lookup(name string) *server {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	return registry.servers[name]
+}
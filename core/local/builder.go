@@ -0,0 +1,132 @@
+package local
+
+import (
+	"github.com/amsalt/nginet/core"
+)
+
+func init() {
+	core.Register(&localServBuilder{})
+	core.RegisterConnector(&localCliBuilder{})
+}
+
+// WithWriteBufSize sets max size of pending wirte.
+func WithWriteBufSize(s int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).WriteBufSize = s
+	}
+}
+
+// WithReadBufSize sets max size of pending read.
+func WithReadBufSize(s int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).ReadBufSize = s
+	}
+}
+
+// WithWriteBufferWaterMark sets the watermarks of pending outbound bytes,
+// see core.WriteBufferWaterMark.
+func WithWriteBufferWaterMark(low, high int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).WaterMark = &core.WriteBufferWaterMark{Low: low, High: high}
+	}
+}
+
+// WithMaxConnNum sets max connection number.
+func WithMaxConnNum(mcn int) core.BuildOption {
+	return func(o interface{}) {
+		o.(*serverOptions).maxConnNum = mcn
+	}
+}
+
+// WithBacklog sets max number of connections waiting to be accepted,
+// Connect fails with ErrConnRefused if the backlog is full.
+func WithBacklog(n int) core.BuildOption {
+	return func(o interface{}) {
+		o.(*serverOptions).backlog = n
+	}
+}
+
+// options returns the Options shared by server and client.
+func options(o interface{}) *Options {
+	switch opts := o.(type) {
+	case *serverOptions:
+		return opts.Options
+	case *clientOptions:
+		return opts.Options
+	}
+	panic("local: unknown options type")
+}
+
+type localServBuilder struct {
+}
+
+func (lb *localServBuilder) Name() string {
+	return core.LocalServBuilder
+}
+
+func (lb *localServBuilder) Build(opt ...core.BuildOption) core.AcceptorChannel {
+	opts := defaultServeroptions
+	cliOpts := *defaultServeroptions.Options
+	opts.Options = &cliOpts
+	for _, o := range opt {
+		o(&opts)
+	}
+
+	return newServerChannel(&opts)
+}
+
+type localCliBuilder struct {
+}
+
+func (cb *localCliBuilder) Name() string {
+	return core.LocalCliBuilder
+}
+
+func (cb *localCliBuilder) Build(opt ...core.BuildOption) core.ConnectorChannel {
+	cliOpts := defaultCliOptions
+	opts := clientOptions{Options: &cliOpts}
+	for _, o := range opt {
+		o(&opts)
+	}
+
+	return newClientChannel(&opts)
+}
+
+var defaultCliOptions = Options{
+	WriteBufSize: 1024,
+	ReadBufSize:  1024,
+}
+
+var defaultServeroptions = serverOptions{
+	Options: &defaultCliOptions,
+
+	maxConnNum: 1000 * 10000,
+	backlog:    128,
+}
+
+type Options struct {
+	WriteBufSize int
+	ReadBufSize  int
+	WaterMark    *core.WriteBufferWaterMark
+}
+
+// subChannelOpts returns the options of creating SubChannel.
+func (o *Options) subChannelOpts(reconnect *core.ReconnectOpts) *core.SubChannelOpts {
+	return &core.SubChannelOpts{
+		ReadBufSize:  o.ReadBufSize,
+		WriteBufSize: o.WriteBufSize,
+		WaterMark:    o.WaterMark,
+		Reconnect:    reconnect,
+	}
+}
+
+type serverOptions struct {
+	*Options
+
+	maxConnNum int
+	backlog    int
+}
+
+type clientOptions struct {
+	*Options
+}
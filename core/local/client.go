@@ -0,0 +1,65 @@
+package local
+
+import (
+	"net"
+
+	"github.com/amsalt/nginet/core"
+)
+
+type client struct {
+	opts *clientOptions
+	*core.Connector
+	addr net.Addr
+}
+
+func NewClientChannel(opts ...*Options) core.ConnectorChannel {
+	if len(opts) == 0 {
+		return newClientChannel(&clientOptions{Options: &defaultCliOptions})
+	}
+	return newClientChannel(&clientOptions{Options: opts[0]})
+}
+
+func newClientChannel(opts *clientOptions) core.ConnectorChannel {
+	c := &client{opts: opts}
+	c.Connector = core.NewConnector()
+
+	return c
+}
+
+// Connect connects to the acceptor listening on the address name, addr can
+// be a net.Addr or string.
+func (c *client) Connect(addr interface{}) (core.SubChannel, error) {
+	var name string
+	switch a := addr.(type) {
+	case net.Addr:
+		name = a.String()
+	case string:
+		name = a
+	default:
+		panic("local.client connect option must be net.Addr or string type")
+	}
+	c.addr = Addr(name)
+
+	s := lookup(name)
+	if s == nil {
+		return nil, ErrConnRefused
+	}
+
+	localAddr := ephemeralAddr()
+	serverSide, clientSide := net.Pipe()
+	if err := s.connect(&pendingConn{conn: serverSide, remoteAddr: localAddr}); err != nil {
+		serverSide.Close()
+		clientSide.Close()
+		return nil, err
+	}
+
+	subChannel := core.NewSubChannel(newRawConn(clientSide, localAddr, c.addr), c.opts.subChannelOpts(nil))
+	c.FireConnect(subChannel)
+	return subChannel, nil
+}
+
+func (c *client) Close() {
+	if subChannel := c.SubChannel(); subChannel != nil {
+		subChannel.Close()
+	}
+}
@@ -0,0 +1,55 @@
+package local
+
+import (
+	"errors"
+	"net"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+)
+
+// rawConn wraps one end of the in-memory pipe.
+type rawConn struct {
+	conn       net.Conn
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+func newRawConn(conn net.Conn, localAddr, remoteAddr net.Addr) core.RawConn {
+	r := &rawConn{conn: conn, localAddr: localAddr, remoteAddr: remoteAddr}
+	return r
+}
+
+// Write writes message to opposite side.
+func (r *rawConn) Write(msg []byte) error {
+	if r.conn == nil {
+		return errors.New("local.rawConn Write() failed for conn is nil")
+	}
+	_, err := r.conn.Write(msg)
+	return err
+}
+
+func (r *rawConn) Read(buf bytes.ReadOnlyBuffer) error {
+	if buf.Len() == 0 {
+		buf.Reset()
+	}
+	_, err := buf.ReadFrom(r.conn)
+	return err
+}
+
+// LocalAddr returns the local addr.
+func (r *rawConn) LocalAddr() net.Addr {
+	return r.localAddr
+}
+
+// RemoteAddr return the opposite side addr.
+func (r *rawConn) RemoteAddr() net.Addr {
+	return r.remoteAddr
+}
+
+func (r *rawConn) Close() error {
+	if r.conn != nil {
+		return r.conn.Close()
+	}
+	return errors.New("local.rawConn Close() failed for conn is nil")
+}
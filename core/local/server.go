@@ -0,0 +1,126 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/amsalt/log"
+	"github.com/amsalt/nginet/core"
+)
+
+// pendingConn is the acceptor side of a connecting pipe.
+type pendingConn struct {
+	conn       net.Conn
+	remoteAddr net.Addr
+}
+
+// server represents a local acceptor listens on a named in-memory address.
+// implements the AcceptorChannel interface.
+type server struct {
+	opts *serverOptions
+	*core.AttrMap
+	*core.Acceptor
+
+	localAddr net.Addr
+
+	mu        sync.RWMutex
+	pending   chan *pendingConn
+	closeFlag bool
+}
+
+func newServerChannel(opts *serverOptions) core.AcceptorChannel {
+	s := new(server)
+	s.opts = opts
+	s.Acceptor = core.NewAcceptor()
+	s.pending = make(chan *pendingConn, opts.backlog)
+
+	return s
+}
+
+// Write writes message to opposite side.
+func (s *server) Write(msg interface{}, extra ...interface{}) error {
+	// nothing to do.
+	return nil
+}
+
+// LocalAddr returns the local addr.
+func (s *server) LocalAddr() net.Addr {
+	return s.localAddr
+}
+
+// RemoteAddr return the opposite side addr.
+func (s *server) RemoteAddr() net.Addr {
+	panic("not implementation")
+}
+
+// Listen binds the acceptor to the address name.
+func (s *server) Listen(addr net.Addr) {
+	if err := bind(addr.String(), s); err != nil {
+		panic(fmt.Errorf("Local server init error: %+v", err))
+	}
+	s.localAddr = Addr(addr.String())
+}
+
+// Accept serves the incoming connections until Close called.
+func (s *server) Accept() {
+	for pc := range s.pending {
+		if !s.validate() {
+			pc.conn.Close()
+			continue
+		}
+		s.processNewConn(pc)
+	}
+}
+
+func (s *server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closeFlag {
+		return
+	}
+	s.closeFlag = true
+
+	if s.localAddr != nil {
+		unbind(s.localAddr.String(), s)
+	}
+	close(s.pending)
+	for pc := range s.pending {
+		pc.conn.Close()
+	}
+}
+
+// Shutdown stops accepting and closes all SubChannels gracefully.
+func (s *server) Shutdown(ctx context.Context) error {
+	s.Close()
+	return s.Acceptor.Shutdown(ctx)
+}
+
+// connect queues the acceptor side of a pipe, fails if closed or backlog full.
+func (s *server) connect(pc *pendingConn) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closeFlag {
+		return ErrConnRefused
+	}
+
+	select {
+	case s.pending <- pc:
+		return nil
+	default:
+		return ErrConnRefused
+	}
+}
+
+func (s *server) validate() bool {
+	if len(s.SubChannels()) >= s.opts.maxConnNum {
+		return false
+	}
+	return true
+}
+
+func (s *server) processNewConn(pc *pendingConn) {
+	log.Debugf("new local connection from: %+v", pc.remoteAddr)
+	s.FireConnect(core.NewSubChannel(newRawConn(pc.conn, s.localAddr, pc.remoteAddr), s.opts.subChannelOpts(nil)))
+}
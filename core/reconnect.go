@@ -0,0 +1,107 @@
+package core
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// DialFunc dials the remote side again with the same transport and options,
+// and returns a new RawConn which replaces the lost one.
+type DialFunc func() (RawConn, error)
+
+// ReconnectPolicy decides whether and when to retry reconnecting.
+type ReconnectPolicy interface {
+	// NextBackOff returns the duration to wait before the attempt-th (starting from 1)
+	// reconnecting, elapsed is the duration since disconnected.
+	// Returns false to stop reconnecting.
+	NextBackOff(attempt int, elapsed time.Duration) (time.Duration, bool)
+}
+
+// ExponentialBackOff represents a ReconnectPolicy which increases the back off
+// exponentially with a random jitter.
+//
+// The back off of the attempt-th reconnecting is:
+//
+//	InitialInterval * Multiplier^(attempt-1) * (1 ± RandomizationFactor)
+//
+// and never bigger than MaxInterval.
+type ExponentialBackOff struct {
+	InitialInterval     time.Duration
+	MaxInterval         time.Duration
+	Multiplier          float64
+	RandomizationFactor float64
+
+	// MaxElapsedTime stops reconnecting after the duration since disconnected, 0 means no limit.
+	MaxElapsedTime time.Duration
+
+	// MaxRetries stops reconnecting after the number of attempts, 0 means no limit.
+	MaxRetries int
+}
+
+// NewExponentialBackOff returns an ExponentialBackOff with default settings.
+func NewExponentialBackOff() *ExponentialBackOff {
+	return &ExponentialBackOff{
+		InitialInterval:     500 * time.Millisecond,
+		MaxInterval:         RetryMaxWaitSec * time.Second,
+		Multiplier:          2,
+		RandomizationFactor: 0.5,
+		MaxElapsedTime:      15 * time.Minute,
+	}
+}
+
+var (
+	jitterMutex sync.Mutex
+	jitterRand  = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// NextBackOff implements ReconnectPolicy.
+func (b *ExponentialBackOff) NextBackOff(attempt int, elapsed time.Duration) (time.Duration, bool) {
+	if b.MaxRetries > 0 && attempt > b.MaxRetries {
+		return 0, false
+	}
+	if b.MaxElapsedTime > 0 && elapsed >= b.MaxElapsedTime {
+		return 0, false
+	}
+
+	interval := float64(b.InitialInterval)
+	for i := 1; i < attempt && interval < float64(b.MaxInterval); i++ {
+		interval *= b.Multiplier
+	}
+	if b.MaxInterval > 0 && interval > float64(b.MaxInterval) {
+		interval = float64(b.MaxInterval)
+	}
+
+	if b.RandomizationFactor > 0 {
+		jitterMutex.Lock()
+		r := jitterRand.Float64()
+		jitterMutex.Unlock()
+
+		delta := b.RandomizationFactor * interval
+		interval = interval - delta + r*2*delta
+	}
+	return time.Duration(interval), true
+}
+
+// ReconnectingEvent is fired into the pipeline before each reconnecting attempt.
+type ReconnectingEvent struct {
+	// Attempt is the number of current attempt, starting from 1.
+	Attempt int
+
+	// Err is the error which causes disconnection or the last failed attempt.
+	Err error
+}
+
+// ReconnectedEvent is fired into the pipeline after reconnected successfully,
+// handlers can redo the login handshakes on it.
+type ReconnectedEvent struct {
+	// Attempts is the number of attempts used.
+	Attempts int
+}
+
+// ReconnectFailedEvent is fired into the pipeline when the ReconnectPolicy gives up,
+// the channel will be closed after it.
+type ReconnectFailedEvent struct {
+	Attempts int
+	Err      error
+}
@@ -0,0 +1,769 @@
+// The arq is derived from KCP and kcp-go, both released under the MIT License:
+//
+//	KCP    https://github.com/skywind3000/kcp    Copyright (c) 2017 Lin Wei (skywind3000 at gmail.com)
+//	kcp-go https://github.com/xtaci/kcp-go      Copyright (c) 2015 xtaci
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rudp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// arq implements a KCP-style automatic repeat request protocol on top of
+// an unreliable datagram transport.
+//
+// Each segment starts with a fixed 24 bytes header in little endian:
+//
+// 	|conv(4)|cmd(1)|frg(1)|wnd(2)|ts(4)|sn(4)|una(4)|len(4)|data(len)|
+//
+// 	conv: conversation id, identifies a session.
+// 	cmd:  segment command, push data, ack, window probe or window tell.
+// 	frg:  fragment index counted down, 0 means the last fragment of a message.
+// 	wnd:  free receive window of the sender.
+// 	ts:   timestamp of the sender, echoed back in ack for rtt calculation.
+// 	sn:   sequence number of the segment.
+// 	una:  all segments before una have been received by the sender.
+//
+// Messages are fragmented by mss and reassembled by the receiver, so that
+// message boundaries are kept.
+// arq is not safe for concurrent use, the caller should lock it.
+
+const (
+	cmdPush = 81 // push data
+	cmdAck  = 82 // ack
+	cmdWask = 83 // window probe (ask)
+	cmdWins = 84 // window size (tell)
+
+	askSend = 1 // need to send cmdWask
+	askTell = 2 // need to send cmdWins
+
+	defaultSndWnd   = 32
+	defaultRcvWnd   = 128
+	defaultMtu      = 1400
+	defaultInterval = 100
+
+	rtoNoDelay = 30
+	rtoMin     = 100
+	rtoDefault = 200
+	rtoMax     = 60000
+
+	overhead = 24
+
+	threshInit = 2
+	threshMin  = 2
+
+	probeInit  = 7000   // 7 secs to probe window size
+	probeLimit = 120000 // up to 120 secs to probe window
+
+	deadLinkXmit = 20
+
+	stateDead = 0xffffffff
+)
+
+var (
+	// ErrMessageTooLarge represents the message needs too many fragments.
+	ErrMessageTooLarge = errors.New("rudp: message too large")
+
+	// ErrEmptyMessage represents an empty message to send.
+	ErrEmptyMessage = errors.New("rudp: empty message")
+
+	// ErrInvalidSegment represents a segment can not be decoded.
+	ErrInvalidSegment = errors.New("rudp: invalid segment")
+
+	// ErrConvMismatch represents a segment belongs to another conversation.
+	ErrConvMismatch = errors.New("rudp: conversation mismatch")
+)
+
+type segment struct {
+	conv     uint32
+	cmd      uint8
+	frg      uint8
+	wnd      uint16
+	ts       uint32
+	sn       uint32
+	una      uint32
+	rto      uint32
+	xmit     uint32
+	resendts uint32
+	fastack  uint32
+	data     []byte
+}
+
+func (seg *segment) encode(ptr []byte) []byte {
+	binary.LittleEndian.PutUint32(ptr, seg.conv)
+	ptr[4] = seg.cmd
+	ptr[5] = seg.frg
+	binary.LittleEndian.PutUint16(ptr[6:], seg.wnd)
+	binary.LittleEndian.PutUint32(ptr[8:], seg.ts)
+	binary.LittleEndian.PutUint32(ptr[12:], seg.sn)
+	binary.LittleEndian.PutUint32(ptr[16:], seg.una)
+	binary.LittleEndian.PutUint32(ptr[20:], uint32(len(seg.data)))
+	return ptr[overhead:]
+}
+
+type ackItem struct {
+	sn uint32
+	ts uint32
+}
+
+type arq struct {
+	conv, mtu, mss, state  uint32
+	sndUna, sndNxt, rcvNxt uint32
+	ssthresh               uint32
+	rxRttval, rxSrtt       int32
+	rxRto, rxMinrto        uint32
+	sndWnd, rcvWnd, rmtWnd uint32
+	cwnd, probe, incr      uint32
+	current, interval      uint32
+	tsFlush                uint32
+	tsProbe, probeWait     uint32
+	nodelay                bool
+	updated                bool
+	nocwnd                 bool
+	fastresend             int32
+
+	sndQueue []segment
+	rcvQueue []segment
+	sndBuf   []segment
+	rcvBuf   []segment
+	ackList  []ackItem
+
+	buffer []byte
+	output func(data []byte)
+}
+
+// newARQ creates a new arq with conversation id, output is called to send
+// a datagram which contains one or more segments.
+func newARQ(conv uint32, output func(data []byte)) *arq {
+	a := new(arq)
+	a.conv = conv
+	a.sndWnd = defaultSndWnd
+	a.rcvWnd = defaultRcvWnd
+	a.rmtWnd = defaultRcvWnd
+	a.mtu = defaultMtu
+	a.mss = a.mtu - overhead
+	a.buffer = make([]byte, (a.mtu+overhead)*3)
+	a.rxRto = rtoDefault
+	a.rxMinrto = rtoMin
+	a.interval = defaultInterval
+	a.tsFlush = defaultInterval
+	a.ssthresh = threshInit
+	a.cwnd = 1
+	a.incr = a.mss
+	a.output = output
+	return a
+}
+
+func timediff(later, earlier uint32) int32 {
+	return int32(later - earlier)
+}
+
+// setNoDelay configures the arq:
+// 	nodelay: use smaller min rto and slower rto backoff.
+// 	interval: internal update interval in millisecond.
+// 	resend: fast resend after a segment is skipped by resend acks, 0 disables fast resend.
+// 	nc: disable congestion control.
+func (a *arq) setNoDelay(nodelay bool, interval, resend int, nc bool) {
+	a.nodelay = nodelay
+	if nodelay {
+		a.rxMinrto = rtoNoDelay
+	} else {
+		a.rxMinrto = rtoMin
+	}
+
+	if interval > 0 {
+		if interval > 5000 {
+			interval = 5000
+		} else if interval < 10 {
+			interval = 10
+		}
+		a.interval = uint32(interval)
+	}
+
+	if resend >= 0 {
+		a.fastresend = int32(resend)
+	}
+	a.nocwnd = nc
+}
+
+// setWndSize sets the max send window and receive window.
+func (a *arq) setWndSize(sndWnd, rcvWnd int) {
+	if sndWnd > 0 {
+		a.sndWnd = uint32(sndWnd)
+	}
+	if rcvWnd > 0 {
+		a.rcvWnd = uint32(rcvWnd)
+	}
+}
+
+// setMtu sets the max size of a datagram.
+func (a *arq) setMtu(mtu int) bool {
+	if mtu < 50 || mtu < overhead {
+		return false
+	}
+	a.mtu = uint32(mtu)
+	a.mss = a.mtu - overhead
+	a.buffer = make([]byte, (a.mtu+overhead)*3)
+	return true
+}
+
+// peekSize returns the size of next message, -1 if no complete message.
+func (a *arq) peekSize() int {
+	if len(a.rcvQueue) == 0 {
+		return -1
+	}
+
+	seg := &a.rcvQueue[0]
+	if seg.frg == 0 {
+		return len(seg.data)
+	}
+
+	if len(a.rcvQueue) < int(seg.frg+1) {
+		return -1
+	}
+
+	length := 0
+	for k := range a.rcvQueue {
+		seg := &a.rcvQueue[k]
+		length += len(seg.data)
+		if seg.frg == 0 {
+			break
+		}
+	}
+	return length
+}
+
+// recv returns the next complete message, nil if no message is available.
+func (a *arq) recv() []byte {
+	size := a.peekSize()
+	if size < 0 {
+		return nil
+	}
+
+	fastRecover := len(a.rcvQueue) >= int(a.rcvWnd)
+
+	msg := make([]byte, 0, size)
+	count := 0
+	for k := range a.rcvQueue {
+		seg := &a.rcvQueue[k]
+		msg = append(msg, seg.data...)
+		count++
+		if seg.frg == 0 {
+			break
+		}
+	}
+	a.rcvQueue = a.rcvQueue[count:]
+
+	a.moveRcvBuf()
+
+	// fast recover, tell remote the window is open again.
+	if len(a.rcvQueue) < int(a.rcvWnd) && fastRecover {
+		a.probe |= askTell
+	}
+	return msg
+}
+
+// moveRcvBuf moves continuous segments from rcvBuf to rcvQueue.
+func (a *arq) moveRcvBuf() {
+	count := 0
+	for k := range a.rcvBuf {
+		seg := &a.rcvBuf[k]
+		if seg.sn == a.rcvNxt && len(a.rcvQueue)+count < int(a.rcvWnd) {
+			a.rcvNxt++
+			count++
+		} else {
+			break
+		}
+	}
+
+	if count > 0 {
+		a.rcvQueue = append(a.rcvQueue, a.rcvBuf[:count]...)
+		a.rcvBuf = a.rcvBuf[count:]
+	}
+}
+
+// send splits the message to fragments and queues them.
+func (a *arq) send(msg []byte) error {
+	if len(msg) == 0 {
+		return ErrEmptyMessage
+	}
+
+	count := (len(msg) + int(a.mss) - 1) / int(a.mss)
+	if count > 255 || count >= int(a.rcvWnd) {
+		return ErrMessageTooLarge
+	}
+
+	for i := 0; i < count; i++ {
+		size := len(msg)
+		if size > int(a.mss) {
+			size = int(a.mss)
+		}
+		seg := segment{data: make([]byte, size)}
+		copy(seg.data, msg[:size])
+		seg.frg = uint8(count - i - 1)
+		a.sndQueue = append(a.sndQueue, seg)
+		msg = msg[size:]
+	}
+	return nil
+}
+
+func (a *arq) updateAck(rtt int32) {
+	if a.rxSrtt == 0 {
+		a.rxSrtt = rtt
+		a.rxRttval = rtt / 2
+	} else {
+		delta := rtt - a.rxSrtt
+		if delta < 0 {
+			delta = -delta
+		}
+		a.rxRttval = (3*a.rxRttval + delta) / 4
+		a.rxSrtt = (7*a.rxSrtt + rtt) / 8
+		if a.rxSrtt < 1 {
+			a.rxSrtt = 1
+		}
+	}
+
+	rto := uint32(a.rxSrtt) + maxu32(a.interval, uint32(4*a.rxRttval))
+	a.rxRto = boundu32(a.rxMinrto, rto, rtoMax)
+}
+
+func (a *arq) shrinkBuf() {
+	if len(a.sndBuf) > 0 {
+		a.sndUna = a.sndBuf[0].sn
+	} else {
+		a.sndUna = a.sndNxt
+	}
+}
+
+func (a *arq) parseAck(sn uint32) {
+	if timediff(sn, a.sndUna) < 0 || timediff(sn, a.sndNxt) >= 0 {
+		return
+	}
+
+	for k := range a.sndBuf {
+		seg := &a.sndBuf[k]
+		if sn == seg.sn {
+			a.sndBuf = append(a.sndBuf[:k], a.sndBuf[k+1:]...)
+			break
+		}
+		if timediff(sn, seg.sn) < 0 {
+			break
+		}
+	}
+}
+
+// parseFastack counts the acks which skip a segment, selective acks make
+// it possible to resend the lost segment before rto.
+func (a *arq) parseFastack(sn, ts uint32) {
+	if timediff(sn, a.sndUna) < 0 || timediff(sn, a.sndNxt) >= 0 {
+		return
+	}
+
+	for k := range a.sndBuf {
+		seg := &a.sndBuf[k]
+		if timediff(sn, seg.sn) < 0 {
+			break
+		} else if sn != seg.sn && timediff(seg.ts, ts) <= 0 {
+			seg.fastack++
+		}
+	}
+}
+
+func (a *arq) parseUna(una uint32) {
+	count := 0
+	for k := range a.sndBuf {
+		seg := &a.sndBuf[k]
+		if timediff(una, seg.sn) > 0 {
+			count++
+		} else {
+			break
+		}
+	}
+	if count > 0 {
+		a.sndBuf = a.sndBuf[count:]
+	}
+}
+
+func (a *arq) ackPush(sn, ts uint32) {
+	a.ackList = append(a.ackList, ackItem{sn, ts})
+}
+
+func (a *arq) parseData(newseg segment) {
+	sn := newseg.sn
+	if timediff(sn, a.rcvNxt+a.rcvWnd) >= 0 || timediff(sn, a.rcvNxt) < 0 {
+		return
+	}
+
+	n := len(a.rcvBuf) - 1
+	insertIdx := 0
+	repeat := false
+	for i := n; i >= 0; i-- {
+		seg := &a.rcvBuf[i]
+		if seg.sn == sn {
+			repeat = true
+			break
+		}
+		if timediff(sn, seg.sn) > 0 {
+			insertIdx = i + 1
+			break
+		}
+	}
+
+	if !repeat {
+		a.rcvBuf = append(a.rcvBuf, segment{})
+		copy(a.rcvBuf[insertIdx+1:], a.rcvBuf[insertIdx:])
+		a.rcvBuf[insertIdx] = newseg
+	}
+
+	a.moveRcvBuf()
+}
+
+// input processes a datagram received from the remote side.
+func (a *arq) input(data []byte) error {
+	prevUna := a.sndUna
+	var maxack, latestTs uint32
+	flag := false
+
+	if len(data) < overhead {
+		return ErrInvalidSegment
+	}
+
+	for len(data) >= overhead {
+		conv := binary.LittleEndian.Uint32(data)
+		cmd := data[4]
+		frg := data[5]
+		wnd := binary.LittleEndian.Uint16(data[6:])
+		ts := binary.LittleEndian.Uint32(data[8:])
+		sn := binary.LittleEndian.Uint32(data[12:])
+		una := binary.LittleEndian.Uint32(data[16:])
+		length := binary.LittleEndian.Uint32(data[20:])
+		data = data[overhead:]
+
+		if conv != a.conv {
+			return ErrConvMismatch
+		}
+
+		if uint32(len(data)) < length {
+			return ErrInvalidSegment
+		}
+
+		if cmd != cmdPush && cmd != cmdAck && cmd != cmdWask && cmd != cmdWins {
+			return ErrInvalidSegment
+		}
+
+		a.rmtWnd = uint32(wnd)
+		a.parseUna(una)
+		a.shrinkBuf()
+
+		switch cmd {
+		case cmdAck:
+			if rtt := timediff(a.current, ts); rtt >= 0 {
+				a.updateAck(rtt)
+			}
+			a.parseAck(sn)
+			a.shrinkBuf()
+			if !flag {
+				flag = true
+				maxack = sn
+				latestTs = ts
+			} else if timediff(sn, maxack) > 0 {
+				maxack = sn
+				latestTs = ts
+			}
+		case cmdPush:
+			if timediff(sn, a.rcvNxt+a.rcvWnd) < 0 {
+				a.ackPush(sn, ts)
+				if timediff(sn, a.rcvNxt) >= 0 {
+					seg := segment{conv: conv, cmd: cmd, frg: frg, wnd: wnd, ts: ts, sn: sn, una: una}
+					seg.data = make([]byte, length)
+					copy(seg.data, data[:length])
+					a.parseData(seg)
+				}
+			}
+		case cmdWask:
+			// ready to send back cmdWins in flush.
+			a.probe |= askTell
+		case cmdWins:
+			// do nothing
+		}
+
+		data = data[length:]
+	}
+
+	if flag {
+		a.parseFastack(maxack, latestTs)
+	}
+
+	// congestion window grows when new data is acked.
+	if timediff(a.sndUna, prevUna) > 0 && a.cwnd < a.rmtWnd {
+		mss := a.mss
+		if a.cwnd < a.ssthresh {
+			a.cwnd++
+			a.incr += mss
+		} else {
+			if a.incr < mss {
+				a.incr = mss
+			}
+			a.incr += (mss*mss)/a.incr + (mss / 16)
+			if (a.cwnd+1)*mss <= a.incr {
+				a.cwnd = (a.incr + mss - 1) / mss
+			}
+		}
+		if a.cwnd > a.rmtWnd {
+			a.cwnd = a.rmtWnd
+			a.incr = a.rmtWnd * mss
+		}
+	}
+
+	return nil
+}
+
+func (a *arq) wndUnused() uint16 {
+	if len(a.rcvQueue) < int(a.rcvWnd) {
+		return uint16(int(a.rcvWnd) - len(a.rcvQueue))
+	}
+	return 0
+}
+
+// flush sends acks, window probes and data segments which need to be sent or resent.
+func (a *arq) flush() {
+	if !a.updated {
+		return
+	}
+
+	current := a.current
+	buffer := a.buffer
+	ptr := buffer
+
+	makeSpace := func(space int) {
+		size := len(buffer) - len(ptr)
+		if size+space > int(a.mtu) {
+			a.output(buffer[:size])
+			ptr = buffer
+		}
+	}
+
+	seg := segment{conv: a.conv, cmd: cmdAck, wnd: a.wndUnused(), una: a.rcvNxt}
+
+	// flush acknowledges
+	for _, ack := range a.ackList {
+		makeSpace(overhead)
+		seg.sn, seg.ts = ack.sn, ack.ts
+		ptr = seg.encode(ptr)
+	}
+	a.ackList = a.ackList[:0]
+
+	// probe window size if remote window size equals zero
+	if a.rmtWnd == 0 {
+		if a.probeWait == 0 {
+			a.probeWait = probeInit
+			a.tsProbe = current + a.probeWait
+		} else if timediff(current, a.tsProbe) >= 0 {
+			if a.probeWait < probeInit {
+				a.probeWait = probeInit
+			}
+			a.probeWait += a.probeWait / 2
+			if a.probeWait > probeLimit {
+				a.probeWait = probeLimit
+			}
+			a.tsProbe = current + a.probeWait
+			a.probe |= askSend
+		}
+	} else {
+		a.tsProbe = 0
+		a.probeWait = 0
+	}
+
+	if a.probe&askSend != 0 {
+		seg.cmd = cmdWask
+		makeSpace(overhead)
+		ptr = seg.encode(ptr)
+	}
+
+	if a.probe&askTell != 0 {
+		seg.cmd = cmdWins
+		makeSpace(overhead)
+		ptr = seg.encode(ptr)
+	}
+	a.probe = 0
+
+	// calculate window size
+	cwnd := minu32(a.sndWnd, a.rmtWnd)
+	if !a.nocwnd {
+		cwnd = minu32(a.cwnd, cwnd)
+	}
+
+	// move data from sndQueue to sndBuf
+	count := 0
+	for k := range a.sndQueue {
+		if timediff(a.sndNxt, a.sndUna+cwnd) >= 0 {
+			break
+		}
+		newseg := a.sndQueue[k]
+		newseg.conv = a.conv
+		newseg.cmd = cmdPush
+		newseg.sn = a.sndNxt
+		a.sndBuf = append(a.sndBuf, newseg)
+		a.sndNxt++
+		count++
+	}
+	if count > 0 {
+		a.sndQueue = a.sndQueue[count:]
+	}
+
+	// calculate resent
+	resent := uint32(a.fastresend)
+	if a.fastresend <= 0 {
+		resent = 0xffffffff
+	}
+
+	rtomin := a.rxRto >> 3
+	if a.nodelay {
+		rtomin = 0
+	}
+
+	change := false
+	lost := false
+
+	// flush data segments
+	for k := range a.sndBuf {
+		segment := &a.sndBuf[k]
+		needsend := false
+		if segment.xmit == 0 {
+			needsend = true
+			segment.rto = a.rxRto
+			segment.resendts = current + segment.rto + rtomin
+		} else if timediff(current, segment.resendts) >= 0 {
+			needsend = true
+			if !a.nodelay {
+				segment.rto += maxu32(segment.rto, a.rxRto)
+			} else {
+				segment.rto += segment.rto / 2
+			}
+			segment.resendts = current + segment.rto
+			lost = true
+		} else if segment.fastack >= resent {
+			needsend = true
+			segment.fastack = 0
+			segment.resendts = current + segment.rto
+			change = true
+		}
+
+		if needsend {
+			segment.xmit++
+			segment.ts = current
+			segment.wnd = seg.wnd
+			segment.una = a.rcvNxt
+
+			need := overhead + len(segment.data)
+			makeSpace(need)
+			ptr = segment.encode(ptr)
+			ptr = ptr[copy(ptr, segment.data):]
+
+			if segment.xmit >= deadLinkXmit {
+				a.state = stateDead
+			}
+		}
+	}
+
+	// flush remain segments
+	if size := len(buffer) - len(ptr); size > 0 {
+		a.output(buffer[:size])
+	}
+
+	// update ssthresh
+	if change {
+		inflight := a.sndNxt - a.sndUna
+		a.ssthresh = inflight / 2
+		if a.ssthresh < threshMin {
+			a.ssthresh = threshMin
+		}
+		a.cwnd = a.ssthresh + resent
+		a.incr = a.cwnd * a.mss
+	}
+
+	if lost {
+		a.ssthresh = cwnd / 2
+		if a.ssthresh < threshMin {
+			a.ssthresh = threshMin
+		}
+		a.cwnd = 1
+		a.incr = a.mss
+	}
+
+	if a.cwnd < 1 {
+		a.cwnd = 1
+		a.incr = a.mss
+	}
+}
+
+// update updates the arq state with current time in millisecond,
+// flush will be called every interval.
+func (a *arq) update(current uint32) {
+	a.current = current
+	if !a.updated {
+		a.updated = true
+		a.tsFlush = current
+	}
+
+	slap := timediff(current, a.tsFlush)
+	if slap >= 10000 || slap < -10000 {
+		a.tsFlush = current
+		slap = 0
+	}
+
+	if slap >= 0 {
+		a.tsFlush += a.interval
+		if timediff(current, a.tsFlush) >= 0 {
+			a.tsFlush = current + a.interval
+		}
+		a.flush()
+	}
+}
+
+// waitSnd returns the number of segments waiting to be sent or acked.
+func (a *arq) waitSnd() int {
+	return len(a.sndBuf) + len(a.sndQueue)
+}
+
+// dead returns true if a segment was resent too many times.
+func (a *arq) dead() bool {
+	return a.state == stateDead
+}
+
+func minu32(a, b uint32) uint32 {
+	if a <= b {
+		return a
+	}
+	return b
+}
+
+func maxu32(a, b uint32) uint32 {
+	if a >= b {
+		return a
+	}
+	return b
+}
+
+func boundu32(lower, middle, upper uint32) uint32 {
+	return minu32(maxu32(lower, middle), upper)
+}
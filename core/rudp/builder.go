@@ -0,0 +1,285 @@
+package rudp
+
+import (
+	"net"
+	"time"
+
+	"github.com/amsalt/nginet/core"
+)
+
+// maxDatagramSize is the largest payload a UDP datagram can carry.
+const maxDatagramSize = 65535
+
+func init() {
+	core.Register(&rudpServBuilder{})
+	core.RegisterConnector(&rudpCliBuilder{})
+}
+
+// WithWriteBufSize sets max size of pending wirte.
+func WithWriteBufSize(s int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).WriteBufSize = s
+	}
+}
+
+// WithReadBufSize sets max size of pending read.
+func WithReadBufSize(s int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).ReadBufSize = s
+	}
+}
+
+// WithWriteBufferWaterMark sets the watermarks of pending outbound bytes,
+// see core.WriteBufferWaterMark.
+func WithWriteBufferWaterMark(low, high int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).WaterMark = &core.WriteBufferWaterMark{Low: low, High: high}
+	}
+}
+
+// WithMaxConnNum sets max number of sessions served at the same time.
+func WithMaxConnNum(mcn int) core.BuildOption {
+	return func(o interface{}) {
+		o.(*serverOptions).maxConnNum = mcn
+	}
+}
+
+// WithIdleTimeout sets the duration after which a session without
+// any inbound datagram will be expired and its SubChannel closed.
+// Zero disables expiry.
+func WithIdleTimeout(t time.Duration) core.BuildOption {
+	return func(o interface{}) {
+		o.(*serverOptions).idleTimeout = t
+	}
+}
+
+// WithNoDelay enables nodelay mode, which uses smaller min rto and slower rto backoff.
+func WithNoDelay(b bool) core.BuildOption {
+	return func(o interface{}) {
+		options(o).NoDelay = b
+	}
+}
+
+// WithInterval sets the internal update interval, between 10ms and 5s.
+func WithInterval(t time.Duration) core.BuildOption {
+	return func(o interface{}) {
+		options(o).Interval = t
+	}
+}
+
+// WithFastResend sets the number of skipping acks which triggers a resend
+// before retransmission timeout, 0 disables fast resend.
+func WithFastResend(n int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).FastResend = n
+	}
+}
+
+// WithNoCongestionWindow disables the congestion control, sending rate is
+// only limited by send window and remote receive window.
+func WithNoCongestionWindow(b bool) core.BuildOption {
+	return func(o interface{}) {
+		options(o).NoCongestionWindow = b
+	}
+}
+
+// WithWindowSize sets the max send window and receive window in segments.
+func WithWindowSize(sndWnd, rcvWnd int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).SndWnd = sndWnd
+		options(o).RcvWnd = rcvWnd
+	}
+}
+
+// WithMTU sets the max size of a datagram.
+func WithMTU(mtu int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).MTU = mtu
+	}
+}
+
+// WithAckNoDelay sends acks as soon as datagrams are received,
+// instead of waiting for next update interval.
+func WithAckNoDelay(b bool) core.BuildOption {
+	return func(o interface{}) {
+		options(o).AckNoDelay = b
+	}
+}
+
+// WithFastMode is a shortcut for latency sensitive scenarios:
+// nodelay, 10ms interval, fast resend after 2 skipping acks,
+// no congestion window and ack without delay.
+func WithFastMode() core.BuildOption {
+	return func(o interface{}) {
+		options(o).fastMode()
+	}
+}
+
+// WithUDPWriteBufSize sets the size of the operating system's
+// transmit buffer associated with the connection.
+func WithUDPWriteBufSize(s int) core.BuildOption {
+	return func(o interface{}) {
+		sockOpts(o).udpWriteBufSize = s
+	}
+}
+
+// WithUDPReadBufSize sets the size of the operating system's
+// receive buffer associated with the connection.
+func WithUDPReadBufSize(s int) core.BuildOption {
+	return func(o interface{}) {
+		sockOpts(o).udpReadBufSize = s
+	}
+}
+
+// WithLocalAddr sets the local address to bind when client connecting.
+func WithLocalAddr(addr net.Addr) core.BuildOption {
+	return func(o interface{}) {
+		o.(*clientOptions).localAddr = addr
+	}
+}
+
+// options returns the Options shared by server and client.
+func options(o interface{}) *Options {
+	switch opts := o.(type) {
+	case *serverOptions:
+		return opts.Options
+	case *clientOptions:
+		return opts.Options
+	}
+	panic("rudp: unknown options type")
+}
+
+// sockOpts returns the socket options shared by server and client.
+func sockOpts(o interface{}) *sockOptions {
+	switch opts := o.(type) {
+	case *serverOptions:
+		return &opts.sockOptions
+	case *clientOptions:
+		return &opts.sockOptions
+	}
+	panic("rudp: unknown options type")
+}
+
+type rudpServBuilder struct {
+}
+
+func (rb *rudpServBuilder) Name() string {
+	return core.RUDPServBuilder
+}
+
+func (rb *rudpServBuilder) Build(opt ...core.BuildOption) core.AcceptorChannel {
+	opts := defaultServeroptions
+	cliOpts := *defaultServeroptions.Options
+	opts.Options = &cliOpts
+	for _, o := range opt {
+		o(&opts)
+	}
+
+	return newServerChannel(&opts)
+}
+
+type rudpCliBuilder struct {
+}
+
+func (cb *rudpCliBuilder) Name() string {
+	return core.RUDPCliBuilder
+}
+
+func (cb *rudpCliBuilder) Build(opt ...core.BuildOption) core.ConnectorChannel {
+	cliOpts := defaultCliOptions
+	opts := clientOptions{Options: &cliOpts}
+	for _, o := range opt {
+		o(&opts)
+	}
+
+	return newClientChannel(&opts)
+}
+
+var defaultCliOptions = Options{
+	WriteBufSize:      1024,
+	ReadBufSize:       1024,
+	AutoReconnect:     false,
+	MaxReconnectTimes: 20,
+
+	Interval:   defaultInterval * time.Millisecond,
+	FastResend: 0,
+	SndWnd:     defaultSndWnd,
+	RcvWnd:     defaultRcvWnd,
+	MTU:        defaultMtu,
+}
+
+var defaultServeroptions = serverOptions{
+	Options: &defaultCliOptions,
+
+	maxConnNum:  1000 * 10000,
+	idleTimeout: time.Minute,
+}
+
+// Options represents the options of a reliable udp session.
+type Options struct {
+	WriteBufSize      int
+	ReadBufSize       int
+	AutoReconnect     bool
+	MaxReconnectTimes int
+	ReconnectPolicy   core.ReconnectPolicy
+	DisconnectedWrite *core.DisconnectedWriteOpts
+	WaterMark         *core.WriteBufferWaterMark
+
+	NoDelay            bool
+	Interval           time.Duration
+	FastResend         int
+	NoCongestionWindow bool
+	AckNoDelay         bool
+	SndWnd             int
+	RcvWnd             int
+	MTU                int
+}
+
+// subChannelOpts returns the options of creating SubChannel.
+func (o *Options) subChannelOpts(reconnect *core.ReconnectOpts) *core.SubChannelOpts {
+	return &core.SubChannelOpts{
+		ReadBufSize:  o.ReadBufSize,
+		WriteBufSize: o.WriteBufSize,
+		WaterMark:    o.WaterMark,
+		Reconnect:    reconnect,
+	}
+}
+
+func (o *Options) fastMode() {
+	o.NoDelay = true
+	o.Interval = 10 * time.Millisecond
+	o.FastResend = 2
+	o.NoCongestionWindow = true
+	o.AckNoDelay = true
+}
+
+type serverOptions struct {
+	*Options
+
+	maxConnNum  int
+	idleTimeout time.Duration
+	sockOptions
+}
+
+// sockOptions represents the socket options applied to the connection.
+type sockOptions struct {
+	udpWriteBufSize int
+	udpReadBufSize  int
+}
+
+func (so *sockOptions) apply(conn *net.UDPConn) {
+	if so.udpReadBufSize > 0 {
+		conn.SetReadBuffer(so.udpReadBufSize)
+	}
+
+	if so.udpWriteBufSize > 0 {
+		conn.SetWriteBuffer(so.udpWriteBufSize)
+	}
+}
+
+type clientOptions struct {
+	*Options
+	sockOptions
+
+	localAddr net.Addr
+}
@@ -0,0 +1,132 @@
+package rudp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+
+	"github.com/amsalt/log"
+	"github.com/amsalt/nginet/core"
+)
+
+// newConv returns a random conversation id.
+func newConv() uint32 {
+	var b [4]byte
+	rand.Read(b[:])
+	return binary.LittleEndian.Uint32(b[:])
+}
+
+type client struct {
+	opts *clientOptions
+	*core.Connector
+	addr net.Addr
+}
+
+// clientSession is a session over a connected *net.UDPConn.
+type clientSession struct {
+	*session
+	conn *net.UDPConn
+}
+
+func newClientSession(conn *net.UDPConn, opts *Options) *clientSession {
+	cs := &clientSession{conn: conn}
+	cs.session = newSession(newConv(), opts, cs.send)
+	cs.localAddr = conn.LocalAddr()
+	cs.remoteAddr = conn.RemoteAddr()
+	cs.onClose = func(*session) { cs.conn.Close() }
+
+	go cs.readloop(conn)
+	return cs
+}
+
+// send is called with session locked.
+func (cs *clientSession) send(data []byte) {
+	cs.conn.Write(data)
+}
+
+func (cs *clientSession) readloop(conn *net.UDPConn) {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			log.Debugf("rudp client read err: %+v", err)
+			cs.fail(err)
+			return
+		}
+		cs.input(buf[:n])
+	}
+}
+
+func NewClientChannel(opts ...*Options) core.ConnectorChannel {
+	if len(opts) == 0 {
+		return newClientChannel(&clientOptions{Options: &defaultCliOptions})
+	}
+	return newClientChannel(&clientOptions{Options: opts[0]})
+}
+
+func newClientChannel(opts *clientOptions) core.ConnectorChannel {
+	c := &client{opts: opts}
+	c.Connector = core.NewConnector()
+
+	return c
+}
+
+func (c *client) Connect(addr interface{}) (core.SubChannel, error) {
+	netaddr, ok := addr.(net.Addr)
+	if !ok {
+		panic("rudp.client connect option must be net.Addr type")
+	}
+	c.addr = netaddr
+
+	conn, err := c.dial(netaddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// a new conversation will be started after reconnected.
+	redial := func() (core.RawConn, error) {
+		conn, err := c.dial(netaddr)
+		if err != nil {
+			return nil, err
+		}
+		return newClientSession(conn, c.opts.Options), nil
+	}
+
+	subChannel := core.NewSubChannel(newClientSession(conn, c.opts.Options), c.opts.subChannelOpts(&core.ReconnectOpts{
+		AutoReconnect:     c.opts.AutoReconnect,
+		MaxReconnectTimes: c.opts.MaxReconnectTimes,
+		Policy:            c.opts.ReconnectPolicy,
+		Dial:              redial,
+		DisconnectedWrite: c.opts.DisconnectedWrite,
+	}))
+	c.FireConnect(subChannel)
+	return subChannel, nil
+}
+
+func (c *client) dial(addr net.Addr) (*net.UDPConn, error) {
+	raddr, err := net.ResolveUDPAddr(addr.Network(), addr.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var laddr *net.UDPAddr
+	if c.opts.localAddr != nil {
+		laddr, err = net.ResolveUDPAddr(c.opts.localAddr.Network(), c.opts.localAddr.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := net.DialUDP(raddr.Network(), laddr, raddr)
+	if err != nil {
+		return nil, err
+	}
+	c.opts.sockOptions.apply(conn)
+	return conn, nil
+}
+
+func (c *client) Close() {
+	if subChannel := c.SubChannel(); subChannel != nil {
+		subChannel.Close()
+	}
+}
@@ -0,0 +1,289 @@
+package rudp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/amsalt/log"
+	"github.com/amsalt/nginet/bytes"
+)
+
+var (
+	// ErrSessionClosed represents the session has been closed or expired.
+	ErrSessionClosed = errors.New("rudp: session closed")
+
+	// ErrDeadLink represents a segment was resent too many times without ack.
+	ErrDeadLink = errors.New("rudp: dead link")
+)
+
+var startTime = time.Now()
+
+// currentMs returns the milliseconds elapsed since process started.
+func currentMs() uint32 {
+	return uint32(time.Since(startTime) / time.Millisecond)
+}
+
+// messageReader reads the data of received messages as a stream,
+// so the remaining part can be read by next Read if buffer is not large enough.
+type messageReader struct {
+	data []byte
+}
+
+func (mr *messageReader) Read(p []byte) (int, error) {
+	n := copy(p, mr.data)
+	mr.data = mr.data[n:]
+	return n, nil
+}
+
+// session represents a reliable udp session, implements core.RawConn.
+// Inbound datagrams are fed by input, outbound datagrams are sent by output.
+type session struct {
+	sync.Mutex
+
+	arq  *arq
+	opts *Options
+
+	output     func(data []byte)
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	onClose    func(s *session)
+
+	reader     messageReader
+	readable   chan byte
+	writable   chan byte
+	closeChan  chan byte
+	closed     bool
+	err        error
+	lastActive time.Time
+}
+
+func newSession(conv uint32, opts *Options, output func(data []byte)) *session {
+	s := &session{opts: opts, output: output}
+	s.readable = make(chan byte, 1)
+	s.writable = make(chan byte, 1)
+	s.closeChan = make(chan byte)
+	s.lastActive = time.Now()
+	s.arq = s.newARQ(conv)
+
+	go s.updateloop()
+	return s
+}
+
+func (s *session) newARQ(conv uint32) *arq {
+	a := newARQ(conv, func(data []byte) {
+		s.output(data)
+	})
+	a.setNoDelay(s.opts.NoDelay, int(s.opts.Interval/time.Millisecond), s.opts.FastResend, s.opts.NoCongestionWindow)
+	a.setWndSize(s.opts.SndWnd, s.opts.RcvWnd)
+	if s.opts.MTU > 0 && !a.setMtu(s.opts.MTU) {
+		log.Warningf("rudp: invalid mtu %+v, use default", s.opts.MTU)
+	}
+	return a
+}
+
+func (s *session) updateloop() {
+	ticker := time.NewTicker(time.Duration(s.arq.interval) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Lock()
+			s.arq.update(currentMs())
+			dead := s.arq.dead()
+			s.Unlock()
+
+			if dead {
+				log.Infof("rudp session %+v dead link", s.remoteAddr)
+				s.fail(ErrDeadLink)
+			}
+		case <-s.closeChan:
+			return
+		}
+	}
+}
+
+// input feeds a datagram received from remote side.
+func (s *session) input(data []byte) {
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		return
+	}
+
+	s.lastActive = time.Now()
+	if err := s.arq.input(data); err != nil {
+		log.Debugf("rudp session input err: %+v", err)
+	}
+
+	if s.opts.AckNoDelay {
+		s.arq.current = currentMs()
+		s.arq.flush()
+	}
+	readable := s.arq.peekSize() >= 0
+	writable := s.sendable()
+	s.Unlock()
+
+	if readable {
+		s.notifyReadable()
+	}
+	if writable {
+		s.notifyWritable()
+	}
+}
+
+func (s *session) notifyReadable() {
+	select {
+	case s.readable <- 1:
+	default:
+	}
+}
+
+func (s *session) notifyWritable() {
+	select {
+	case s.writable <- 1:
+	default:
+	}
+}
+
+// sendable returns true if the segments waiting are less than the send window,
+// call it with session locked.
+func (s *session) sendable() bool {
+	return s.arq.waitSnd() < int(s.arq.sndWnd)
+}
+
+// fail records err which will be returned by Read and Write.
+func (s *session) fail(err error) {
+	s.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.Unlock()
+	s.notifyReadable()
+	s.notifyWritable()
+}
+
+// failed returns true if the session is closed or failed.
+func (s *session) failed() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.closed || s.err != nil
+}
+
+func (s *session) idle(now time.Time, timeout time.Duration) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return now.Sub(s.lastActive) >= timeout
+}
+
+// Write sends data reliably to opposite side, it blocks while the segments waiting
+// to be sent or acked fill the send window.
+func (s *session) Write(msg []byte) error {
+	for {
+		s.Lock()
+		if s.closed {
+			s.Unlock()
+			return ErrSessionClosed
+		}
+		if s.err != nil {
+			err := s.err
+			s.Unlock()
+			return err
+		}
+		if s.sendable() {
+			break
+		}
+		s.Unlock()
+
+		select {
+		case <-s.writable:
+		case <-s.closeChan:
+		}
+	}
+	defer s.Unlock()
+
+	if err := s.arq.send(msg); err != nil {
+		log.Errorf("rudp session write err: %+v", err)
+		return err
+	}
+
+	s.arq.current = currentMs()
+	s.arq.flush()
+	return nil
+}
+
+// Read reads the data of received messages in order.
+func (s *session) Read(buf bytes.ReadOnlyBuffer) error {
+	for {
+		s.Lock()
+		if len(s.reader.data) == 0 {
+			if msg := s.arq.recv(); msg != nil {
+				s.reader.data = msg
+			}
+		}
+
+		if len(s.reader.data) > 0 {
+			if buf.Len() == 0 {
+				buf.Reset()
+			}
+			_, err := buf.ReadFrom(&s.reader)
+			more := len(s.reader.data) > 0 || s.arq.peekSize() >= 0
+			s.Unlock()
+
+			if more {
+				s.notifyReadable()
+			}
+			return err
+		}
+
+		if s.closed {
+			s.Unlock()
+			return ErrSessionClosed
+		}
+
+		if s.err != nil {
+			err := s.err
+			s.Unlock()
+			return err
+		}
+		s.Unlock()
+
+		select {
+		case <-s.readable:
+		case <-s.closeChan:
+		}
+	}
+}
+
+// LocalAddr returns the local addr.
+func (s *session) LocalAddr() net.Addr {
+	return s.localAddr
+}
+
+// RemoteAddr return the opposite side addr.
+func (s *session) RemoteAddr() net.Addr {
+	return s.remoteAddr
+}
+
+// Close closes the session.
+func (s *session) Close() error {
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		return ErrSessionClosed
+	}
+	s.closed = true
+	// send the pending segments once before closing.
+	s.arq.flush()
+	close(s.closeChan)
+	s.Unlock()
+
+	if s.onClose != nil {
+		s.onClose(s)
+	}
+	return nil
+}
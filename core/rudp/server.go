@@ -0,0 +1,253 @@
+package rudp
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/amsalt/log"
+	"github.com/amsalt/nginet/core"
+)
+
+// server represents a reliable udp server.
+// implements the AcceptorChannel interface.
+// Each remote address is mapped to a session and its own SubChannel.
+type server struct {
+	opts *serverOptions
+	*core.AttrMap
+	*core.Acceptor
+
+	conn      *net.UDPConn
+	localAddr net.Addr
+
+	sessionMutex sync.Mutex
+	sessions     map[string]*serverSession
+
+	closeChan chan byte
+	closeOnce sync.Once
+}
+
+// serverSession binds the session with its SubChannel.
+type serverSession struct {
+	*session
+	channel core.SubChannel
+}
+
+func newServerChannel(opts *serverOptions) core.AcceptorChannel {
+	s := new(server)
+	s.opts = opts
+	s.Acceptor = core.NewAcceptor()
+	s.sessions = make(map[string]*serverSession)
+	s.closeChan = make(chan byte)
+
+	return s
+}
+
+// Write writes message to opposite side.
+func (s *server) Write(msg interface{}, extra ...interface{}) error {
+	// nothing to do.
+	return nil
+}
+
+// LocalAddr returns the local addr.
+func (s *server) LocalAddr() net.Addr {
+	return s.localAddr
+}
+
+// RemoteAddr return the opposite side addr.
+func (s *server) RemoteAddr() net.Addr {
+	panic("not implementation")
+}
+
+// Listen announces on the local network address.
+func (s *server) Listen(addr net.Addr) {
+	s.localAddr = addr
+
+	udpAddr, err := net.ResolveUDPAddr(addr.Network(), addr.String())
+	if err != nil {
+		panic(fmt.Errorf("RUDP server init error: %+v", err))
+	}
+
+	conn, err := net.ListenUDP(udpAddr.Network(), udpAddr)
+	if err != nil {
+		panic(fmt.Errorf("RUDP server init error: %+v", err))
+	}
+
+	s.opts.sockOptions.apply(conn)
+	s.conn = conn
+}
+
+// Accept reads datagrams and dispatches them to the session of remote address.
+func (s *server) Accept() {
+	if s.opts.idleTimeout > 0 {
+		go s.expire()
+	}
+	s.serve()
+}
+
+func (s *server) serve() {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			// Stop
+			return
+		}
+
+		if n < overhead {
+			continue
+		}
+
+		conv := binary.LittleEndian.Uint32(buf)
+		ss := s.getOrCreateSession(addr, conv)
+		if ss != nil {
+			ss.input(buf[:n])
+		}
+	}
+}
+
+// Close closes the SubChannels of all sessions and the listening connection.
+func (s *server) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closeChan)
+		for _, ss := range s.allSessions() {
+			ss.channel.Close()
+		}
+		s.conn.Close()
+	})
+}
+
+// Shutdown stops serving new sessions and closes all SubChannels gracefully,
+// then closes the listening connection.
+func (s *server) Shutdown(ctx context.Context) error {
+	err := s.Acceptor.Shutdown(ctx)
+	s.Close()
+	return err
+}
+
+func (s *server) getOrCreateSession(addr *net.UDPAddr, conv uint32) *serverSession {
+	key := addr.String()
+
+	s.sessionMutex.Lock()
+	ss, ok := s.sessions[key]
+	if ok && ss.arq.conv == conv {
+		s.sessionMutex.Unlock()
+		return ss
+	}
+	s.sessionMutex.Unlock()
+
+	// the remote side restarts a new conversation, the live session is kept against
+	// the stale or spoofed datagrams until it's dead or idle expired.
+	if ok {
+		if !s.replaceable(ss, time.Now()) {
+			log.Debugf("rudp datagram of conv %+v from %+v dropped, session conv %+v is alive", conv, addr, ss.arq.conv)
+			return nil
+		}
+		ss.channel.Close()
+	}
+
+	s.sessionMutex.Lock()
+	if s.IsShutdown() {
+		s.sessionMutex.Unlock()
+		return nil
+	}
+
+	if !s.validate() {
+		s.sessionMutex.Unlock()
+		log.Debugf("too many rudp sessions, datagram from %+v will be dropped", addr)
+		return nil
+	}
+
+	ss = &serverSession{session: newSession(conv, s.opts.Options, func(data []byte) {
+		s.conn.WriteToUDP(data, addr)
+	})}
+	ss.localAddr = s.conn.LocalAddr()
+	ss.remoteAddr = addr
+	ss.onClose = func(*session) { s.removeSession(key, ss) }
+	ss.channel = core.NewSubChannel(ss.session, s.opts.subChannelOpts(nil))
+	s.sessions[key] = ss
+	s.sessionMutex.Unlock()
+
+	s.processNewSession(ss)
+	return ss
+}
+
+// replaceable returns true if the session can be replaced by a new conversation.
+func (s *server) replaceable(ss *serverSession, now time.Time) bool {
+	if ss.failed() {
+		return true
+	}
+	return s.opts.idleTimeout > 0 && ss.idle(now, s.opts.idleTimeout)
+}
+
+func (s *server) validate() bool {
+	if len(s.sessions) >= s.opts.maxConnNum {
+		return false
+	}
+	return true
+}
+
+func (s *server) processNewSession(ss *serverSession) {
+	log.Debugf("new rudp session: %+v, conv: %+v", ss.RemoteAddr(), ss.arq.conv)
+	s.FireConnect(ss.channel)
+}
+
+func (s *server) removeSession(key string, ss *serverSession) {
+	s.sessionMutex.Lock()
+	if cur, ok := s.sessions[key]; ok && cur == ss {
+		delete(s.sessions, key)
+	}
+	s.sessionMutex.Unlock()
+
+	s.RemoveSubChannel(ss.channel)
+}
+
+// expire closes the SubChannels of sessions which are idle more than idleTimeout.
+func (s *server) expire() {
+	interval := s.opts.idleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, ss := range s.idleSessions(now) {
+				log.Debugf("rudp session %+v expired", ss.RemoteAddr())
+				ss.channel.Close()
+			}
+		case <-s.closeChan:
+			return
+		}
+	}
+}
+
+func (s *server) allSessions() []*serverSession {
+	s.sessionMutex.Lock()
+	defer s.sessionMutex.Unlock()
+
+	sessions := make([]*serverSession, 0, len(s.sessions))
+	for _, ss := range s.sessions {
+		sessions = append(sessions, ss)
+	}
+	return sessions
+}
+
+func (s *server) idleSessions(now time.Time) []*serverSession {
+	var idles []*serverSession
+	for _, ss := range s.allSessions() {
+		if ss.idle(now, s.opts.idleTimeout) {
+			idles = append(idles, ss)
+		}
+	}
+	return idles
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Sharable is implemented by the handler which can be added to multiple
+// ChannelPipelines at the same time. A Sharable handler must be stateless or
+// safe for concurrent use, since it handles the events of all the channels.
+//
+// A handler not Sharable can only be attached to one pipeline, adding it again
+// before removed is refused, the error is fired and the channel closed. Use
+// AddLastFactory of the AcceptorChannel or ConnectorChannel to build a fresh
+// handler per channel.
+type Sharable interface {
+	Sharable()
+}
+
+// attachedHandlers records the non-sharable handlers attached to pipelines.
+var attachedHandlers = struct {
+	sync.Mutex
+	handlers map[interface{}]bool
+}{handlers: make(map[interface{}]bool)}
+
+// trackable reports whether the handler holds the state and should be attached
+// to one pipeline only. Only the pointers to non-empty values are tracked, the
+// handler passed by value is copied per pipeline.
+func trackable(handler interface{}) bool {
+	if _, ok := handler.(Sharable); ok {
+		return false
+	}
+	t := reflect.TypeOf(handler)
+	return t != nil && t.Kind() == reflect.Ptr && t.Elem().Size() > 0
+}
+
+// attachHandler marks the handler attached, returns an error if a non-sharable
+// handler is already attached to a pipeline.
+func attachHandler(handler interface{}) error {
+	if !trackable(handler) {
+		return nil
+	}
+
+	attachedHandlers.Lock()
+	defer attachedHandlers.Unlock()
+	if attachedHandlers.handlers[handler] {
+		return fmt.Errorf("handler %T is not Sharable and already attached to a pipeline", handler)
+	}
+	attachedHandlers.handlers[handler] = true
+	return nil
+}
+
+// detachHandler marks the handler removed from the pipeline.
+func detachHandler(handler interface{}) {
+	if !trackable(handler) {
+		return
+	}
+
+	attachedHandlers.Lock()
+	delete(attachedHandlers.handlers, handler)
+	attachedHandlers.Unlock()
+}
+
+// handlerFactory builds the handler named name for each new SubChannel.
+type handlerFactory struct {
+	name    string
+	factory func() interface{}
+}
+
+// addFactories adds the handlers built by factories at the last of the pipeline.
+func addFactories(pipeline ChannelPipeline, factories []handlerFactory) {
+	for _, f := range factories {
+		pipeline.AddLast(nil, f.name, f.factory())
+	}
+}
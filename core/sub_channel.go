@@ -1,9 +1,11 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/amsalt/nginet/bytes"
@@ -22,48 +24,161 @@ var (
 
 	// ErrConnLost connection lost
 	ErrConnLost = errors.New("connection lost")
+
+	// ErrChannelClosing represents the channel is closing gracefully, no more message accepted.
+	ErrChannelClosing = errors.New("channel is closing")
+
+	// ErrMessageTruncated represents the message read is larger than ReadBufSize and dropped,
+	// such as a large datagram. It's fired without closing the channel.
+	ErrMessageTruncated = errors.New("message truncated")
+)
+
+// gracefulClose is put to the write queue by GracefullyClose,
+// the channel will be closed after all messages before it sent.
+type gracefulClose struct{}
+
+// replay is put to the write queue by Replay, the messages buffered
+// while reconnecting will be sent when writeloop reaches it.
+type replay struct{}
+
+// connection states of a SubChannel which reconnects automatically.
+const (
+	stateConnected int32 = iota
+	stateReconnecting
+	stateHandshaking
 )
 
 // DefaultSubChannel represents a default implementation of SubChannel.
 // When a new connection conntect to ServreChannel, a corresponding
 //  SubChannel will be created.
 type DefaultSubChannel struct {
+	// pendingBytes is accessed atomically, keep it the first for 64-bit alignment.
+	pendingBytes int64
+
 	*BaseChannel
 	sync.Mutex
 
 	conn        RawConn
 	closeChan   chan byte
 	closeFlag   bool
+	closing     int32
 	writeBuf    chan interface{}
 	readBufSize int
 
+	// initialized is closed after the channel initialized and the Connect
+	// event fired, reading starts after it.
+	initialized chan struct{}
+	initOnce    sync.Once
+
+	// connMutex guards conn which will be replaced after reconnected.
+	connMutex sync.RWMutex
+
 	// if open, will reconnect with the same SubChannel instance
-	autoReconnect     bool
-	reconnectTimes    int
-	maxReconnectTimes int
-	reconnecting      bool
+	autoReconnect   bool
+	reconnectPolicy ReconnectPolicy
+	dial            DialFunc
+	connState       int32
+	reconnectBuf    *reconnectBuffer
+
+	waterMark     *WriteBufferWaterMark
+	sizeEstimator MessageSizeEstimator
+	unwritable    int32
+
+	// writeBatchSize limits the number of messages coalesced into one write.
+	writeBatchSize int
+	batchMutex     sync.Mutex
+	batch          writeBatch
 }
 
+// SubChannelOpts represents the options of creating a DefaultSubChannel.
+type SubChannelOpts struct {
+	ReadBufSize  int
+	WriteBufSize int
+
+	// WaterMark enables the writability of pending outbound bytes if not nil.
+	WaterMark *WriteBufferWaterMark
+
+	// SizeEstimator estimates the message size for WaterMark, defaults to DefaultMessageSizeEstimator.
+	SizeEstimator MessageSizeEstimator
+
+	// WriteBatchSize limits the number of queued messages coalesced into one vectored write
+	// if the RawConn is a BatchWriter, defaults to 64, 1 means writing one by one.
+	WriteBatchSize int
+
+	// Reconnect enables reconnecting if not nil.
+	Reconnect *ReconnectOpts
+}
+
+// ReconnectOpts represents the options of reconnecting when the connection lost.
 type ReconnectOpts struct {
-	AutoReconnect     bool
+	AutoReconnect bool
+
+	// MaxReconnectTimes is used by the default ReconnectPolicy if Policy is nil.
 	MaxReconnectTimes int
+
+	// Policy decides when to retry reconnecting, defaults to an ExponentialBackOff.
+	Policy ReconnectPolicy
+
+	// Dial dials the remote side again, supplied by the transport.
+	// It's required to reconnect, AutoReconnect is ignored if nil.
+	Dial DialFunc
+
+	// DisconnectedWrite decides how to handle the messages written while reconnecting,
+	// defaults to buffer at most 1024 messages and replay them after reconnected.
+	DisconnectedWrite *DisconnectedWriteOpts
 }
 
 // NewDefaultSubChannel returns a new instance of SubChannel
 // The same time, the subchannel will start the read loop and write loop
 // to serve reading message and writting message.
 func NewDefaultSubChannel(conn RawConn, readBufSize, writeBufSize int, reconnOpts ...*ReconnectOpts) SubChannel {
+	opts := &SubChannelOpts{ReadBufSize: readBufSize, WriteBufSize: writeBufSize}
+	if len(reconnOpts) > 0 {
+		opts.Reconnect = reconnOpts[0]
+	}
+	return NewSubChannel(conn, opts)
+}
+
+// NewSubChannel returns a new instance of SubChannel with the options,
+// the read loop and write loop will be started.
+func NewSubChannel(conn RawConn, subOpts *SubChannelOpts) SubChannel {
 	dsc := &DefaultSubChannel{conn: conn}
 	dsc.BaseChannel = NewBaseChannel(dsc)
 	dsc.closeChan = make(chan byte)
+	dsc.initialized = make(chan struct{})
+
+	if subOpts.WaterMark != nil {
+		subOpts.WaterMark.validate()
+		waterMark := *subOpts.WaterMark
+		dsc.waterMark = &waterMark
+		dsc.sizeEstimator = subOpts.SizeEstimator
+		if dsc.sizeEstimator == nil {
+			dsc.sizeEstimator = DefaultMessageSizeEstimator
+		}
+	}
 
-	if len(reconnOpts) > 0 {
-		dsc.autoReconnect = reconnOpts[0].AutoReconnect
-		dsc.maxReconnectTimes = reconnOpts[0].MaxReconnectTimes
+	if subOpts.Reconnect != nil {
+		opts := subOpts.Reconnect
+		dsc.autoReconnect = opts.AutoReconnect && opts.Dial != nil
+		if opts.AutoReconnect && opts.Dial == nil {
+			log.Warningf("AutoReconnect ignored without ReconnectOpts.Dial")
+		}
+		dsc.reconnectPolicy = opts.Policy
+		if dsc.reconnectPolicy == nil {
+			backOff := NewExponentialBackOff()
+			backOff.MaxRetries = opts.MaxReconnectTimes
+			dsc.reconnectPolicy = backOff
+		}
+		dsc.dial = opts.Dial
+		dsc.reconnectBuf = newReconnectBuffer(opts.DisconnectedWrite)
 	}
 
-	dsc.writeBuf = make(chan interface{}, writeBufSize)
-	dsc.readBufSize = readBufSize
+	dsc.writeBuf = make(chan interface{}, subOpts.WriteBufSize)
+	dsc.readBufSize = subOpts.ReadBufSize
+	dsc.writeBatchSize = subOpts.WriteBatchSize
+	if dsc.writeBatchSize <= 0 {
+		dsc.writeBatchSize = defaultWriteBatchSize
+	}
 
 	dsc.start()
 	return dsc
@@ -74,60 +189,119 @@ func (dsc *DefaultSubChannel) start() {
 	go dsc.writeloop()
 }
 
-func (dsc *DefaultSubChannel) resetReconn() {
-	dsc.reconnecting = false
-	dsc.reconnectTimes = 0
+// initializer is implemented by SubChannels which wait for initialization before reading.
+type initializer interface {
+	markInitialized()
 }
 
-func (dsc *DefaultSubChannel) reconnect() bool {
-	dsc.reconnecting = true
-	for dsc.reconnectTimes < dsc.maxReconnectTimes {
-		log.Warning("reconnecting...")
-		dsc.reconnectTimes++
-		netaddr := dsc.conn.RemoteAddr()
+func markInitialized(channel SubChannel) {
+	if i, ok := channel.(initializer); ok {
+		i.markInitialized()
+	}
+}
+
+func (dsc *DefaultSubChannel) markInitialized() {
+	dsc.initOnce.Do(func() { close(dsc.initialized) })
+}
 
-		conn, err := net.Dial(netaddr.Network(), netaddr.String())
-		if err == nil {
-			log.Debug("reconnect success: %+v", conn)
-			dsc.conn.SetConn(conn)
-			dsc.resetReconn()
-			return true
-		} else {
-			time.Sleep((time.Duration(dsc.reconnectTimes) * 2 % RetryMaxWaitSec) * time.Second)
+// reconnect retries dialing by the ReconnectPolicy until succeeded, returns false
+// if the policy gives up or the channel closed.
+func (dsc *DefaultSubChannel) reconnect(cause error) bool {
+	atomic.StoreInt32(&dsc.connState, stateReconnecting)
+
+	start := time.Now()
+	err := cause
+	for attempt := 1; ; attempt++ {
+		backOff, ok := dsc.reconnectPolicy.NextBackOff(attempt, time.Since(start))
+		if !ok {
+			log.Warningf("reconnect failed after %d attempts: %+v", attempt-1, err)
+			dsc.FireEvent(ReconnectFailedEvent{Attempts: attempt - 1, Err: err})
+			return false
 		}
+
+		select {
+		case <-time.After(backOff):
+		case <-dsc.closeChan:
+			return false
+		}
+
+		log.Warningf("reconnecting, attempt: %d", attempt)
+		dsc.FireEvent(ReconnectingEvent{Attempt: attempt, Err: err})
+
+		var conn RawConn
+		conn, err = dsc.dial()
+		if err != nil {
+			continue
+		}
+
+		if !dsc.setConn(conn) {
+			conn.Close()
+			return false
+		}
+		log.Infof("reconnect success: %+v", conn.RemoteAddr())
+		atomic.StoreInt32(&dsc.connState, stateHandshaking)
+		dsc.FireEvent(ReconnectedEvent{Attempts: attempt})
+		if !dsc.reconnectBuf.opts.ManualReplay {
+			dsc.Replay()
+		}
+		return true
 	}
+}
 
-	dsc.resetReconn()
-	return false
+// setConn replaces the lost connection and closes it, returns false if the channel closed.
+func (dsc *DefaultSubChannel) setConn(conn RawConn) bool {
+	dsc.Lock()
+	defer dsc.Unlock()
+	if dsc.closeFlag {
+		return false
+	}
+
+	dsc.connMutex.Lock()
+	lost := dsc.conn
+	dsc.conn = conn
+	dsc.connMutex.Unlock()
+
+	if lost != nil && lost != conn {
+		lost.Close()
+	}
+	return true
 }
 
 func (dsc *DefaultSubChannel) readloop() {
 	log.Debug("start read loop.")
+	select {
+	case <-dsc.initialized:
+	case <-dsc.closeChan:
+		return
+	}
 	readerBuf := bytes.NewReadOnlyBuffer(dsc.readBufSize)
 
 	for {
-		err := dsc.conn.Read(readerBuf)
+		err := dsc.RawConn().Read(readerBuf)
 
+		if errors.Is(err, ErrMessageTruncated) {
+			dsc.FireError(err)
+			readerBuf.Reset()
+			continue
+		}
 		if err != nil {
 			log.Infof("read message err: %+v", err)
-			if !dsc.autoReconnect {
+			if !dsc.autoReconnect || dsc.isClosed() {
 				goto ERR
 			} else {
-				reconnectSuccess := dsc.reconnect()
+				reconnectSuccess := dsc.reconnect(err)
 				if !reconnectSuccess {
 					goto ERR
 				}
+				// drop the partial data of the lost connection.
+				readerBuf.Reset()
+				continue
 			}
 		}
 
-		// ensure process all messages.
-		for readerBuf.Len() > 0 {
-			oldBufLen := readerBuf.Len()
-			dsc.FireRead(readerBuf)
-			if oldBufLen == readerBuf.Len() {
-				break
-			}
-		}
+		// the bytes not consumed by handlers are kept, and fired again
+		// with the bytes read next time, see handler.ByteToMessageDecoder.
+		dsc.FireRead(readerBuf)
 	}
 ERR:
 	dsc.Close()
@@ -136,77 +310,313 @@ ERR:
 
 func (dsc *DefaultSubChannel) writeloop() {
 	log.Debug("start write loop.")
-	for msg := range dsc.writeBuf {
-		if msg == nil {
-			continue
+	defer dsc.failPendingWrites()
+
+	// buffering represents the messages should be buffered until replay,
+	// since the disconnection observed.
+	buffering := false
+	for {
+		var msg interface{}
+		select {
+		case msg = <-dsc.writeBuf:
+		case <-dsc.closeChan:
+			return
+		}
+
+		// drain the queued messages and flush them at once.
+		dsc.beginBatch()
+		closing := false
+		for n := 1; ; n++ {
+			if closing = !dsc.writeMessage(msg, &buffering); closing || n >= dsc.writeBatchSize {
+				break
+			}
+
+			drained := false
+			select {
+			case msg = <-dsc.writeBuf:
+			default:
+				drained = true
+			}
+			if drained {
+				break
+			}
+		}
+		dsc.endBatch()
+
+		if closing {
+			dsc.Close()
+			return
+		}
+	}
+}
+
+// writeMessage fires a queued message to the pipeline, returns false if the channel should be closed.
+func (dsc *DefaultSubChannel) writeMessage(msg interface{}, buffering *bool) bool {
+	switch msg.(type) {
+	case nil:
+		return true
+	case gracefulClose:
+		return false
+	case replay:
+		*buffering = false
+		for _, pw := range dsc.reconnectBuf.take() {
+			dsc.Pipeline().FireWriteWithPromise(pw.msg, pw.promise)
 		}
+		return true
+	}
+
+	var promise WritePromise
+	flush := false
+	if pw, ok := msg.(pendingWrite); ok {
+		msg, promise, flush = pw.msg, pw.promise, pw.flush
+	}
 
-		for dsc.reconnecting {
-			log.Debug("wait write when reconnecting.")
-			time.Sleep(time.Second)
+	promise = dsc.trackPending(promise, dsc.messageSize(msg))
+	if *buffering || atomic.LoadInt32(&dsc.connState) != stateConnected {
+		*buffering = true
+		dsc.reconnectBuf.add(msg, promise)
+	} else {
+		dsc.Pipeline().FireWriteWithPromise(msg, promise)
+		if flush {
+			dsc.endBatch()
+			dsc.beginBatch()
 		}
+	}
+	return true
+}
+
+// failPendingWrites fails the promises of messages not written after the writeloop exited.
+func (dsc *DefaultSubChannel) failPendingWrites() {
+	if dsc.reconnectBuf != nil {
+		dsc.reconnectBuf.fail(ErrConnLost)
+	}
 
-		dsc.Pipeline().FireWrite(msg)
+	for {
+		select {
+		case msg := <-dsc.writeBuf:
+			if pw, ok := msg.(pendingWrite); ok {
+				completePromise(pw.promise, ErrConnLost)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// writeFailed handles the error of writing to conn, the channel will be closed
+// or reconnected if AutoReconnect set.
+func (dsc *DefaultSubChannel) writeFailed(conn RawConn, err error) {
+	if dsc.isClosed() {
+		return
+	}
+
+	dsc.FireError(err)
+	if dsc.autoReconnect {
+		// buffer the messages written from now on, readloop reconnects after the connection closed.
+		if dsc.RawConn() == conn {
+			atomic.CompareAndSwapInt32(&dsc.connState, stateConnected, stateReconnecting)
+		}
+		conn.Close()
+		return
 	}
 	dsc.Close()
 }
 
 // Write writes message to opposite side.
-func (dsc *DefaultSubChannel) Write(msg interface{}, extra ...interface{}) (err error) {
-	var output interface{}
-	var combines []interface{}
+func (dsc *DefaultSubChannel) Write(msg interface{}, extra ...interface{}) error {
+	err := dsc.write(nil, pendingWrite{msg: combineMessage(msg, extra)})
+	if err == ErrMessageDropped {
+		return nil
+	}
+	return err
+}
+
+// WriteContext writes message to opposite side, blocks until there is room
+// in the write queue or the ctx done.
+func (dsc *DefaultSubChannel) WriteContext(ctx context.Context, msg interface{}, extra ...interface{}) error {
+	err := dsc.write(ctx, pendingWrite{msg: combineMessage(msg, extra)})
+	if err == ErrMessageDropped {
+		return nil
+	}
+	return err
+}
+
+// WriteAndFlush writes message to opposite side, the message will be flushed
+// without waiting for coalescing with the messages queued after it.
+// Write may coalesce the message with others queued into one vectored write.
+func (dsc *DefaultSubChannel) WriteAndFlush(msg interface{}, extra ...interface{}) error {
+	err := dsc.write(nil, pendingWrite{msg: combineMessage(msg, extra), flush: true})
+	if err == ErrMessageDropped {
+		return nil
+	}
+	return err
+}
+
+// WriteAsync writes message to opposite side, returns a WriteFuture which is completed
+// after the message written to the RawConn, or failed with the error.
+func (dsc *DefaultSubChannel) WriteAsync(msg interface{}, extra ...interface{}) WriteFuture {
+	promise := NewWritePromise()
+	if err := dsc.write(nil, pendingWrite{msg: combineMessage(msg, extra), promise: promise}); err != nil {
+		promise.Complete(err)
+	}
+	return promise
+}
+
+func combineMessage(msg interface{}, extra []interface{}) interface{} {
 	if len(extra) > 0 {
-		combines = append(combines, msg, extra[0])
-		output = combines
-	} else {
-		output = msg
+		return []interface{}{msg, extra[0]}
+	}
+	return msg
+}
+
+// write puts the message to the write queue, with the promise and flush flag if set.
+// It blocks until there is room or ctx done if ctx not nil.
+func (dsc *DefaultSubChannel) write(ctx context.Context, pw pendingWrite) (err error) {
+	defer func() {
+		if err != nil && err != ErrMessageDropped {
+			log.Errorf("subchannel write message err: %+v", err)
+		}
+	}()
+
+	if atomic.LoadInt32(&dsc.closing) == 1 {
+		return ErrChannelClosing
+	}
+
+	if atomic.LoadInt32(&dsc.connState) != stateConnected {
+		switch {
+		case dsc.reconnectBuf.opts.Policy == DropWrites:
+			return ErrMessageDropped
+		case dsc.reconnectBuf.opts.Policy == FailFastWrites:
+			return ErrReconnecting
+		case dsc.reconnectBuf.isFull():
+			return ErrReconnectBufferFull
+		}
+	}
+
+	var output interface{} = pw.msg
+	if pw.promise != nil || pw.flush {
+		output = pw
 	}
 
 	select {
-	case dsc.writeBuf <- output:
 	case <-dsc.closeChan:
-		err = ErrConnLost
+		return ErrConnLost
 	default:
-		err = ErrWriteMsgQueueFull
 	}
 
-	if err != nil {
-		log.Errorf("subchannel write message err: %+v", err)
+	size := dsc.messageSize(pw.msg)
+	if ctx == nil {
+		select {
+		case dsc.writeBuf <- output:
+		case <-dsc.closeChan:
+			err = ErrConnLost
+		default:
+			err = ErrWriteMsgQueueFull
+		}
+	} else {
+		select {
+		case dsc.writeBuf <- output:
+		case <-dsc.closeChan:
+			err = ErrConnLost
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	if err == nil {
+		dsc.addPendingBytes(size)
 	}
 	return
 }
 
 // LocalAddr returns the local addr.
 func (dsc *DefaultSubChannel) LocalAddr() net.Addr {
-	if dsc.conn == nil {
+	conn := dsc.RawConn()
+	if conn == nil {
 		return nil
 	}
-	return dsc.conn.LocalAddr()
+	return conn.LocalAddr()
 }
 
 // RemoteAddr return the opposite side addr.
 func (dsc *DefaultSubChannel) RemoteAddr() net.Addr {
-	if dsc.conn == nil {
+	conn := dsc.RawConn()
+	if conn == nil {
 		return nil
 	}
-	return dsc.conn.RemoteAddr()
+	return conn.RemoteAddr()
 }
 
+// GracefullyClose closes the connection after all messages written before sent,
+// the messages written after it will be refused with ErrChannelClosing.
 func (dsc *DefaultSubChannel) GracefullyClose() {
+	if !atomic.CompareAndSwapInt32(&dsc.closing, 0, 1) {
+		return
+	}
 
+	select {
+	case dsc.writeBuf <- gracefulClose{}:
+	case <-dsc.closeChan:
+	default:
+		// the write queue is full, wait in background.
+		go func() {
+			select {
+			case dsc.writeBuf <- gracefulClose{}:
+			case <-dsc.closeChan:
+			}
+		}()
+	}
+}
+
+// Replay replays the messages buffered while reconnecting and resumes writing,
+// it's called automatically after reconnected unless DisconnectedWriteOpts.ManualReplay set.
+func (dsc *DefaultSubChannel) Replay() {
+	if !atomic.CompareAndSwapInt32(&dsc.connState, stateHandshaking, stateConnected) {
+		return
+	}
+
+	select {
+	case dsc.writeBuf <- replay{}:
+	case <-dsc.closeChan:
+	}
+}
+
+// CloseNotify returns a channel which is closed when the SubChannel closed.
+func (dsc *DefaultSubChannel) CloseNotify() <-chan byte {
+	return dsc.closeChan
 }
 
 // Close closes the connection.
 func (dsc *DefaultSubChannel) Close() {
+	if !dsc.close() {
+		return
+	}
+
+	// the handlers are removed out of lock, they may access the channel in HandlerRemoved.
+	if pd, ok := dsc.Pipeline().(pipelineDestroyer); ok {
+		pd.destroy()
+	}
+}
+
+// close closes the connection and fires the Disconnect event, returns false if already closed.
+func (dsc *DefaultSubChannel) close() bool {
 	dsc.Lock()
 	defer dsc.Unlock()
 
-	if dsc.conn != nil && !dsc.closeFlag {
+	if conn := dsc.RawConn(); conn != nil && !dsc.closeFlag {
 		dsc.closeFlag = true
 		close(dsc.closeChan)
-		dsc.conn.Close()
+		conn.Close()
 		dsc.FireDisconnect()
+		return true
 	}
+	return false
+}
+
+func (dsc *DefaultSubChannel) isClosed() bool {
+	dsc.Lock()
+	defer dsc.Unlock()
+	return dsc.closeFlag
 }
 
 // FireConnect fires a connect event. Nothing to do for a SubChannel.
@@ -238,5 +648,7 @@ func (dsc *DefaultSubChannel) FireEvent(event interface{}) InboundInvoker {
 
 // RawConn returns the raw connection.
 func (dsc *DefaultSubChannel) RawConn() RawConn {
+	dsc.connMutex.RLock()
+	defer dsc.connMutex.RUnlock()
 	return dsc.conn
 }
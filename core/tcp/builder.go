@@ -1,24 +1,45 @@
 package tcp
 
 import (
+	"crypto/tls"
+	"net"
+	"time"
+
 	"github.com/amsalt/nginet/core"
 )
 
 func init() {
 	core.Register(&tcpServBuilder{})
+	core.RegisterConnector(&tcpCliBuilder{})
 }
 
 // WithWriteBufSize sets max size of pending wirte.
 func WithWriteBufSize(s int) core.BuildOption {
 	return func(o interface{}) {
-		o.(*serverOptions).WriteBufSize = s
+		options(o).WriteBufSize = s
 	}
 }
 
 // WithReadBufSize sets max size of pending read.
 func WithReadBufSize(s int) core.BuildOption {
 	return func(o interface{}) {
-		o.(*serverOptions).ReadBufSize = s
+		options(o).ReadBufSize = s
+	}
+}
+
+// WithWriteBatchSize sets max number of queued messages coalesced into one vectored write,
+// 1 means writing one by one.
+func WithWriteBatchSize(n int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).WriteBatchSize = n
+	}
+}
+
+// WithWriteBufferWaterMark sets the watermarks of pending outbound bytes,
+// see core.WriteBufferWaterMark.
+func WithWriteBufferWaterMark(low, high int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).WaterMark = &core.WriteBufferWaterMark{Low: low, High: high}
 	}
 }
 
@@ -33,7 +54,7 @@ func WithMaxConnNum(mcn int) core.BuildOption {
 // transmit buffer associated with the connection.
 func WithTCPWriteBufSize(s int) core.BuildOption {
 	return func(o interface{}) {
-		o.(*serverOptions).tcpWriteBufSize = s
+		sockOpts(o).tcpWriteBufSize = s
 	}
 }
 
@@ -41,7 +62,7 @@ func WithTCPWriteBufSize(s int) core.BuildOption {
 // receive buffer associated with the connection.
 func WithTCPReadBufSize(s int) core.BuildOption {
 	return func(o interface{}) {
-		o.(*serverOptions).tcpReadBufSize = s
+		sockOpts(o).tcpReadBufSize = s
 	}
 }
 
@@ -51,7 +72,7 @@ func WithTCPReadBufSize(s int) core.BuildOption {
 // sent as soon as possible after a Write.
 func WithNodelay(b bool) core.BuildOption {
 	return func(o interface{}) {
-		o.(*serverOptions).noDelay = b
+		sockOpts(o).noDelay = b
 	}
 }
 
@@ -59,14 +80,14 @@ func WithNodelay(b bool) core.BuildOption {
 // keepalive messages on the connection.
 func WithKeepalive(b bool) core.BuildOption {
 	return func(o interface{}) {
-		o.(*serverOptions).keepalive = b
+		sockOpts(o).keepalive = b
 	}
 }
 
 // WithKeepalivePeriod sets period between keep alives.
 func WithKeepalivePeriod(sec int) core.BuildOption {
 	return func(o interface{}) {
-		o.(*serverOptions).keepalivePeriod = sec
+		sockOpts(o).keepalivePeriod = sec
 	}
 }
 
@@ -84,24 +105,137 @@ func WithKeepalivePeriod(sec int) core.BuildOption {
 // unsent data may be discarded.
 func WithLinger(sec int) core.BuildOption {
 	return func(o interface{}) {
-		o.(*serverOptions).linger = sec
+		sockOpts(o).linger = sec
 	}
 }
 
 // WithAutoReconnect sets whether auto reconnect when disconnect with server.
 func WithAutoReconnect(r bool) core.BuildOption {
 	return func(o interface{}) {
-		o.(*serverOptions).AutoReconnect = r
+		options(o).AutoReconnect = r
 	}
 }
 
 // WithMaxReconnectTimes sets max number for trying reconnect with server.
 func WithMaxReconnectTimes(n int) core.BuildOption {
 	return func(o interface{}) {
-		o.(*serverOptions).MaxReconnectTimes = n
+		options(o).MaxReconnectTimes = n
+	}
+}
+
+// WithReconnectPolicy sets the policy deciding when to retry reconnecting,
+// the default is an exponential back off with jitter limited by MaxReconnectTimes.
+func WithReconnectPolicy(p core.ReconnectPolicy) core.BuildOption {
+	return func(o interface{}) {
+		options(o).ReconnectPolicy = p
+	}
+}
+
+// WithDisconnectedWrite sets how to handle the messages written while reconnecting,
+// the default is buffering them and replaying after reconnected.
+func WithDisconnectedWrite(opts *core.DisconnectedWriteOpts) core.BuildOption {
+	return func(o interface{}) {
+		options(o).DisconnectedWrite = opts
+	}
+}
+
+// WithTLSConfig enables TLS with the config, for server, the certificate
+// options below will override the corresponding fields of it.
+func WithTLSConfig(c *tls.Config) core.BuildOption {
+	return func(o interface{}) {
+		switch opts := o.(type) {
+		case *serverOptions:
+			opts.tlsConfig = c
+		default:
+			options(o).TLSConfig = c
+		}
+	}
+}
+
+// WithCertFile sets the certificate file for TLS.
+func WithCertFile(cf string) core.BuildOption {
+	return func(o interface{}) {
+		o.(*serverOptions).certFile = cf
+	}
+}
+
+// WithKeyFile sets the private key file for TLS.
+func WithKeyFile(kf string) core.BuildOption {
+	return func(o interface{}) {
+		o.(*serverOptions).keyFile = kf
+	}
+}
+
+// WithCertReloadInterval sets the interval for checking whether the certificate
+// and key files changed, the changed key pair will be reloaded without
+// restarting the acceptor. The default is 0, which means never reload.
+func WithCertReloadInterval(d time.Duration) core.BuildOption {
+	return func(o interface{}) {
+		o.(*serverOptions).certReloadInterval = d
+	}
+}
+
+// WithKeyPairReloader sets the KeyPairReloader which provides the certificate,
+// the caller can reload it whenever needed.
+func WithKeyPairReloader(r *KeyPairReloader) core.BuildOption {
+	return func(o interface{}) {
+		o.(*serverOptions).reloader = r
+	}
+}
+
+// WithClientCAFile sets the CA file for verifying client certificates,
+// the client must present a valid certificate if set (mutual TLS).
+func WithClientCAFile(caf string) core.BuildOption {
+	return func(o interface{}) {
+		o.(*serverOptions).clientCAFile = caf
+	}
+}
+
+// WithHandshakeTimeout sets the max duration of the TLS handshake.
+// The default is 10 seconds.
+func WithHandshakeTimeout(d time.Duration) core.BuildOption {
+	return func(o interface{}) {
+		o.(*serverOptions).handshakeTimeout = d
+	}
+}
+
+// WithDialTimeout sets the max duration for client connecting (including the
+// TLS handshake). The default is 0, which means no timeout.
+func WithDialTimeout(d time.Duration) core.BuildOption {
+	return func(o interface{}) {
+		o.(*clientOptions).dialTimeout = d
 	}
 }
 
+// WithLocalAddr sets the local address to bind when client connecting.
+func WithLocalAddr(addr net.Addr) core.BuildOption {
+	return func(o interface{}) {
+		o.(*clientOptions).localAddr = addr
+	}
+}
+
+// options returns the Options shared by server and client.
+func options(o interface{}) *Options {
+	switch opts := o.(type) {
+	case *serverOptions:
+		return opts.Options
+	case *clientOptions:
+		return opts.Options
+	}
+	panic("tcp: unknown options type")
+}
+
+// sockOpts returns the socket options shared by server and client.
+func sockOpts(o interface{}) *sockOptions {
+	switch opts := o.(type) {
+	case *serverOptions:
+		return &opts.sockOptions
+	case *clientOptions:
+		return opts.sockOptions
+	}
+	panic("tcp: unknown options type")
+}
+
 type tcpServBuilder struct {
 }
 
@@ -111,6 +245,8 @@ func (tb *tcpServBuilder) Name() string {
 
 func (tb *tcpServBuilder) Build(opt ...core.BuildOption) core.AcceptorChannel {
 	opts := defaultServeroptions
+	cliOpts := *defaultServeroptions.Options
+	opts.Options = &cliOpts
 	for _, o := range opt {
 		o(&opts)
 	}
@@ -118,6 +254,24 @@ func (tb *tcpServBuilder) Build(opt ...core.BuildOption) core.AcceptorChannel {
 	return newServerChannel(&opts)
 }
 
+type tcpCliBuilder struct {
+}
+
+func (tb *tcpCliBuilder) Name() string {
+	return core.TCPCliBuilder
+}
+
+func (tb *tcpCliBuilder) Build(opt ...core.BuildOption) core.ConnectorChannel {
+	cliOpts := defaultCliOptions
+	sockOpts := defaultSockOptions
+	opts := clientOptions{Options: &cliOpts, sockOptions: &sockOpts}
+	for _, o := range opt {
+		o(&opts)
+	}
+
+	return newClientChannel(&opts)
+}
+
 var defaultCliOptions = Options{
 	WriteBufSize:      1024,
 	ReadBufSize:       1024,
@@ -125,12 +279,18 @@ var defaultCliOptions = Options{
 	MaxReconnectTimes: 20,
 }
 
+var defaultSockOptions = sockOptions{
+	noDelay:   true,
+	keepalive: false,
+}
+
 var defaultServeroptions = serverOptions{
-	Options: &defaultCliOptions,
+	Options:     &defaultCliOptions,
+	sockOptions: defaultSockOptions,
 
 	maxConnNum: 1000 * 10000,
-	noDelay:    true,
-	keepalive:  false,
+
+	handshakeTimeout: 10 * time.Second,
 }
 
 type Options struct {
@@ -138,12 +298,28 @@ type Options struct {
 	ReadBufSize       int
 	AutoReconnect     bool
 	MaxReconnectTimes int
+	ReconnectPolicy   core.ReconnectPolicy
+	DisconnectedWrite *core.DisconnectedWriteOpts
+	WaterMark         *core.WriteBufferWaterMark
+	WriteBatchSize    int
+
+	// TLSConfig enables TLS for client if not nil.
+	TLSConfig *tls.Config
 }
 
-type serverOptions struct {
-	*Options
+// subChannelOpts returns the options of creating SubChannel.
+func (o *Options) subChannelOpts(reconnect *core.ReconnectOpts) *core.SubChannelOpts {
+	return &core.SubChannelOpts{
+		ReadBufSize:    o.ReadBufSize,
+		WriteBufSize:   o.WriteBufSize,
+		WaterMark:      o.WaterMark,
+		WriteBatchSize: o.WriteBatchSize,
+		Reconnect:      reconnect,
+	}
+}
 
-	maxConnNum      int
+// sockOptions represents the socket options applied to the connection.
+type sockOptions struct {
 	tcpWriteBufSize int
 	tcpReadBufSize  int
 
@@ -152,3 +328,48 @@ type serverOptions struct {
 	keepalivePeriod int
 	linger          int
 }
+
+func (so *sockOptions) apply(conn *net.TCPConn) {
+	conn.SetNoDelay(so.noDelay)
+	conn.SetKeepAlive(so.keepalive)
+
+	if so.tcpReadBufSize > 0 {
+		conn.SetReadBuffer(so.tcpReadBufSize)
+	}
+
+	if so.tcpWriteBufSize > 0 {
+		conn.SetWriteBuffer(so.tcpWriteBufSize)
+	}
+
+	if so.keepalivePeriod > 0 {
+		conn.SetKeepAlivePeriod(time.Second * time.Duration(so.keepalivePeriod))
+	}
+
+	if so.linger > 0 {
+		conn.SetLinger(so.linger)
+	}
+}
+
+type serverOptions struct {
+	*Options
+	sockOptions
+
+	maxConnNum int
+
+	tlsConfig          *tls.Config
+	certFile           string
+	keyFile            string
+	clientCAFile       string
+	certReloadInterval time.Duration
+	reloader           *KeyPairReloader
+	handshakeTimeout   time.Duration
+}
+
+type clientOptions struct {
+	*Options
+	// nil if created by NewClientChannel, the socket options are not applied.
+	*sockOptions
+
+	dialTimeout time.Duration
+	localAddr   net.Addr
+}
@@ -1,26 +1,28 @@
 package tcp
 
 import (
+	"crypto/tls"
 	"net"
 
 	"github.com/amsalt/nginet/core"
 )
 
 type client struct {
-	opts *Options
+	opts *clientOptions
 	*core.Connector
-	conn net.Conn
 	addr net.Addr
 }
 
 func NewClientChannel(opts ...*Options) core.ConnectorChannel {
-	c := &client{}
-	c.Connector = core.NewConnector()
 	if len(opts) == 0 {
-		c.opts = &defaultCliOptions
-	} else {
-		c.opts = opts[0]
+		return newClientChannel(&clientOptions{Options: &defaultCliOptions})
 	}
+	return newClientChannel(&clientOptions{Options: opts[0]})
+}
+
+func newClientChannel(opts *clientOptions) core.ConnectorChannel {
+	c := &client{opts: opts}
+	c.Connector = core.NewConnector()
 
 	return c
 }
@@ -31,18 +33,64 @@ func (c *client) Connect(addr interface{}) (core.SubChannel, error) {
 		panic("tcp.client connect option must be net.Addr type")
 	}
 	c.addr = netaddr
-	conn, err := net.Dial(netaddr.Network(), netaddr.String())
+	conn, err := c.dial(netaddr)
 	if err != nil {
 		return nil, err
 	}
 
-	subChannel := core.NewDefaultSubChannel(newRawConn(conn), c.opts.ReadBufSize, c.opts.WriteBufSize, &core.ReconnectOpts{AutoReconnect: c.opts.AutoReconnect, MaxReconnectTimes: c.opts.MaxReconnectTimes})
+	var subChannel core.SubChannel
+	redial := func() (core.RawConn, error) {
+		conn, err := c.dial(netaddr)
+		if err != nil {
+			return nil, err
+		}
+		storeConnectionState(subChannel, conn)
+		return newRawConn(conn), nil
+	}
+
+	subChannel = core.NewSubChannel(newRawConn(conn), c.opts.subChannelOpts(&core.ReconnectOpts{
+		AutoReconnect:     c.opts.AutoReconnect,
+		MaxReconnectTimes: c.opts.MaxReconnectTimes,
+		Policy:            c.opts.ReconnectPolicy,
+		Dial:              redial,
+		DisconnectedWrite: c.opts.DisconnectedWrite,
+	}))
+	storeConnectionState(subChannel, conn)
 	c.FireConnect(subChannel)
 	return subChannel, nil
 }
 
+func (c *client) dial(addr net.Addr) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: c.opts.dialTimeout, LocalAddr: c.opts.localAddr}
+	conn, err := dialer.Dial(addr.Network(), addr.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok && c.opts.sockOptions != nil {
+		c.opts.sockOptions.apply(tcpConn)
+	}
+
+	if c.opts.TLSConfig == nil {
+		return conn, nil
+	}
+
+	config := c.opts.TLSConfig
+	if config.ServerName == "" && !config.InsecureSkipVerify {
+		config = config.Clone()
+		config.ServerName, _, _ = net.SplitHostPort(addr.String())
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := handshake(tlsConn, c.opts.dialTimeout); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
 func (c *client) Close() {
-	if c.conn != nil {
-		c.conn.Close()
+	if subChannel := c.SubChannel(); subChannel != nil {
+		subChannel.Close()
 	}
 }
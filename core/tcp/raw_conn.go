@@ -17,18 +17,46 @@ func newRawConn(conn net.Conn) core.RawConn {
 	return r
 }
 
-func (r *rawConn) SetConn(conn net.Conn) {
-	r.conn = conn
+// Write writes message to opposite side.
+func (r *rawConn) Write(msg []byte) error {
+	if r.conn == nil {
+		return errors.New("tcp.rawConn Write() failed for conn is nil")
+	}
+	_, err := r.conn.Write(msg)
+	return err
 }
 
-// Write writes message to opposite side.
-func (r *rawConn) Write(msg []byte) {
-	if r.conn != nil {
-		r.conn.Write(msg)
+// WriteBuffers writes bufs by a vectored write, the bufs are joined
+// into one write for TLS connection.
+func (r *rawConn) WriteBuffers(bufs net.Buffers) error {
+	if r.conn == nil {
+		return errors.New("tcp.rawConn WriteBuffers() failed for conn is nil")
 	}
+
+	if _, ok := r.conn.(*net.TCPConn); !ok {
+		_, err := r.conn.Write(joinBuffers(bufs))
+		return err
+	}
+	_, err := bufs.WriteTo(r.conn)
+	return err
+}
+
+func joinBuffers(bufs net.Buffers) []byte {
+	n := 0
+	for _, b := range bufs {
+		n += len(b)
+	}
+	data := make([]byte, 0, n)
+	for _, b := range bufs {
+		data = append(data, b...)
+	}
+	return data
 }
 
 func (r *rawConn) Read(buf bytes.ReadOnlyBuffer) error {
+	if buf.Len() == 0 {
+		buf.Reset()
+	}
 	_, err := buf.ReadFrom(r.conn)
 	return err
 }
@@ -1,6 +1,8 @@
 package tcp
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"time"
@@ -18,6 +20,8 @@ type server struct {
 
 	ln        net.Listener
 	localAddr net.Addr
+	tlsConfig *tls.Config
+	reloader  *KeyPairReloader
 
 	retryDelay time.Duration
 }
@@ -50,6 +54,12 @@ func (s *server) RemoteAddr() net.Addr {
 func (s *server) Listen(addr net.Addr) {
 	s.localAddr = addr
 
+	tlsConfig, err := s.buildTLSConfig()
+	if err != nil {
+		panic(fmt.Errorf("TCP server TLS init error: %+v", err))
+	}
+	s.tlsConfig = tlsConfig
+
 	ln, err := net.Listen(addr.Network(), addr.String())
 	if err != nil {
 		panic(fmt.Errorf("TCP server init error: %+v", err))
@@ -80,13 +90,27 @@ func (s *server) accept() {
 			continue
 		}
 
-		s.applyOptions(conn)
+		s.opts.sockOptions.apply(conn)
+		if s.tlsConfig != nil {
+			// handshake in another goroutine, avoid blocking accepting.
+			go s.processNewTLSConn(tls.Server(conn, s.tlsConfig))
+			continue
+		}
 		s.processNewConn(conn)
 	}
 }
 
 func (s *server) Close() {
 	s.ln.Close()
+	if s.reloader != nil {
+		s.reloader.Close()
+	}
+}
+
+// Shutdown stops accepting and closes all SubChannels gracefully.
+func (s *server) Shutdown(ctx context.Context) error {
+	s.Close()
+	return s.Acceptor.Shutdown(ctx)
 }
 
 func (s *server) waitWhenTemporaryErr() {
@@ -113,28 +137,18 @@ func (s *server) validate(conn *net.TCPConn) bool {
 	return true
 }
 
-func (s *server) applyOptions(conn *net.TCPConn) {
-	conn.SetNoDelay(s.opts.noDelay)
-	conn.SetKeepAlive(s.opts.keepalive)
-
-	if s.opts.tcpReadBufSize > 0 {
-		conn.SetReadBuffer(s.opts.tcpReadBufSize)
-	}
-
-	if s.opts.tcpWriteBufSize > 0 {
-		conn.SetWriteBuffer(s.opts.tcpWriteBufSize)
-	}
-
-	if s.opts.keepalivePeriod > 0 {
-		conn.SetKeepAlivePeriod(time.Second * time.Duration(s.opts.keepalivePeriod))
-	}
-
-	if s.opts.linger > 0 {
-		conn.SetLinger(s.opts.linger)
+func (s *server) processNewTLSConn(conn *tls.Conn) {
+	if err := handshake(conn, s.opts.handshakeTimeout); err != nil {
+		log.Warningf("tls handshake with %+v failed: %+v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
 	}
+	s.processNewConn(conn)
 }
 
-func (s *server) processNewConn(conn *net.TCPConn) {
+func (s *server) processNewConn(conn net.Conn) {
 	log.Debugf("new connection: %+v", conn)
-	s.FireConnect(core.NewDefaultSubChannel(newRawConn(conn), s.opts.ReadBufSize, s.opts.WriteBufSize))
+	subChannel := core.NewSubChannel(newRawConn(conn), s.opts.subChannelOpts(nil))
+	storeConnectionState(subChannel, conn)
+	s.FireConnect(subChannel)
 }
@@ -0,0 +1,204 @@
+package tcp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/amsalt/log"
+	"github.com/amsalt/nginet/core"
+)
+
+const (
+	// AttrPeerCertificates is the key of the peer's certificate chain ([]*x509.Certificate)
+	// in SubChannel's AttrMap, only set when the peer presents certificates.
+	AttrPeerCertificates = "tcp.peer_certificates"
+
+	// AttrNegotiatedProtocol is the key of the negotiated ALPN protocol (string)
+	// in SubChannel's AttrMap.
+	AttrNegotiatedProtocol = "tcp.negotiated_protocol"
+)
+
+// PeerCertificatesOf returns the peer's certificate chain of the TLS channel,
+// nil if the channel is not TLS or the peer presents no certificate.
+func PeerCertificatesOf(channel core.Channel) []*x509.Certificate {
+	certs, _ := channel.Attr().Value(AttrPeerCertificates).([]*x509.Certificate)
+	return certs
+}
+
+// NegotiatedProtocolOf returns the negotiated ALPN protocol of the TLS channel.
+func NegotiatedProtocolOf(channel core.Channel) string {
+	proto, _ := channel.Attr().Value(AttrNegotiatedProtocol).(string)
+	return proto
+}
+
+func storeConnectionState(channel core.Channel, conn net.Conn) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return
+	}
+
+	state := tlsConn.ConnectionState()
+	if len(state.PeerCertificates) > 0 {
+		channel.Attr().SetValue(AttrPeerCertificates, state.PeerCertificates)
+	}
+	channel.Attr().SetValue(AttrNegotiatedProtocol, state.NegotiatedProtocol)
+}
+
+// KeyPairReloader holds a certificate which can be reloaded from the cert and key
+// files without restarting the acceptor.
+// The new certificate takes effect on the next handshake, the established
+// connections are not affected.
+type KeyPairReloader struct {
+	sync.RWMutex
+	certFile string
+	keyFile  string
+
+	cert    *tls.Certificate
+	modTime time.Time
+
+	closeChan chan byte
+	closeOnce sync.Once
+}
+
+// NewKeyPairReloader loads the key pair from certFile and keyFile, returns error if failed.
+func NewKeyPairReloader(certFile, keyFile string) (*KeyPairReloader, error) {
+	r := &KeyPairReloader{certFile: certFile, keyFile: keyFile, closeChan: make(chan byte)}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the key pair from files again, keeps the old one if failed.
+func (r *KeyPairReloader) Reload() error {
+	modTime := r.lastModTime()
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.Unlock()
+	return nil
+}
+
+// GetCertificate returns the current certificate, can be used as tls.Config.GetCertificate.
+func (r *KeyPairReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.cert, nil
+}
+
+// GetClientCertificate returns the current certificate, can be used as
+// tls.Config.GetClientCertificate.
+func (r *KeyPairReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.cert, nil
+}
+
+// Watch checks the files every interval, reloads the key pair when changed.
+// It returns until Close called.
+func (r *KeyPairReloader) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.RLock()
+			changed := r.lastModTime().After(r.modTime)
+			r.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				log.Warningf("tcp: reload key pair failed: %+v", err)
+			} else {
+				log.Infof("tcp: key pair reloaded from %+v", r.certFile)
+			}
+		case <-r.closeChan:
+			return
+		}
+	}
+}
+
+// Close stops watching.
+func (r *KeyPairReloader) Close() {
+	r.closeOnce.Do(func() { close(r.closeChan) })
+}
+
+func (r *KeyPairReloader) lastModTime() time.Time {
+	var latest time.Time
+	for _, f := range []string{r.certFile, r.keyFile} {
+		if fi, err := os.Stat(f); err == nil && fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest
+}
+
+// buildTLSConfig returns the server side tls.Config built from options, nil if TLS disabled.
+func (s *server) buildTLSConfig() (*tls.Config, error) {
+	opts := s.opts
+	if opts.tlsConfig == nil && opts.certFile == "" && opts.keyFile == "" && opts.reloader == nil {
+		return nil, nil
+	}
+
+	var config *tls.Config
+	if opts.tlsConfig != nil {
+		config = opts.tlsConfig.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+
+	if opts.reloader == nil && (opts.certFile != "" || opts.keyFile != "") {
+		reloader, err := NewKeyPairReloader(opts.certFile, opts.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		if opts.certReloadInterval > 0 {
+			go reloader.Watch(opts.certReloadInterval)
+		}
+		s.reloader = reloader
+		config.GetCertificate = reloader.GetCertificate
+	} else if opts.reloader != nil {
+		config.GetCertificate = opts.reloader.GetCertificate
+	}
+
+	if opts.clientCAFile != "" {
+		pem, err := ioutil.ReadFile(opts.clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %+v", opts.clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if len(config.Certificates) == 0 && config.GetCertificate == nil && config.GetConfigForClient == nil {
+		return nil, errors.New("no certificate configured")
+	}
+	return config, nil
+}
+
+// handshake completes the TLS handshake within the handshake timeout.
+func handshake(conn *tls.Conn, timeout time.Duration) error {
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+	return conn.Handshake()
+}
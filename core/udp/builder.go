@@ -0,0 +1,209 @@
+package udp
+
+import (
+	"net"
+	"time"
+
+	"github.com/amsalt/nginet/core"
+)
+
+// maxDatagramSize is the largest payload a UDP datagram can carry.
+const maxDatagramSize = 65535
+
+func init() {
+	core.Register(&udpServBuilder{})
+	core.RegisterConnector(&udpCliBuilder{})
+}
+
+// WithWriteBufSize sets max size of pending wirte.
+func WithWriteBufSize(s int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).WriteBufSize = s
+	}
+}
+
+// WithReadBufSize sets max size of a single datagram can be read.
+func WithReadBufSize(s int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).ReadBufSize = s
+	}
+}
+
+// WithWriteBufferWaterMark sets the watermarks of pending outbound bytes,
+// see core.WriteBufferWaterMark.
+func WithWriteBufferWaterMark(low, high int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).WaterMark = &core.WriteBufferWaterMark{Low: low, High: high}
+	}
+}
+
+// WithMaxConnNum sets max number of remote peers served at the same time.
+func WithMaxConnNum(mcn int) core.BuildOption {
+	return func(o interface{}) {
+		o.(*serverOptions).maxConnNum = mcn
+	}
+}
+
+// WithIdleTimeout sets the duration after which a remote peer without
+// any inbound datagram will be expired and its SubChannel closed.
+// Zero disables expiry.
+func WithIdleTimeout(t time.Duration) core.BuildOption {
+	return func(o interface{}) {
+		o.(*serverOptions).idleTimeout = t
+	}
+}
+
+// WithPendingDatagramNum sets max number of received datagrams queued for
+// a remote peer, datagrams beyond the limit will be dropped.
+func WithPendingDatagramNum(n int) core.BuildOption {
+	return func(o interface{}) {
+		o.(*serverOptions).pendingDatagramNum = n
+	}
+}
+
+// WithUDPWriteBufSize sets the size of the operating system's
+// transmit buffer associated with the connection.
+func WithUDPWriteBufSize(s int) core.BuildOption {
+	return func(o interface{}) {
+		sockOpts(o).udpWriteBufSize = s
+	}
+}
+
+// WithUDPReadBufSize sets the size of the operating system's
+// receive buffer associated with the connection.
+func WithUDPReadBufSize(s int) core.BuildOption {
+	return func(o interface{}) {
+		sockOpts(o).udpReadBufSize = s
+	}
+}
+
+// WithLocalAddr sets the local address to bind when client connecting.
+func WithLocalAddr(addr net.Addr) core.BuildOption {
+	return func(o interface{}) {
+		o.(*clientOptions).localAddr = addr
+	}
+}
+
+// options returns the Options shared by server and client.
+func options(o interface{}) *Options {
+	switch opts := o.(type) {
+	case *serverOptions:
+		return opts.Options
+	case *clientOptions:
+		return opts.Options
+	}
+	panic("udp: unknown options type")
+}
+
+// sockOpts returns the socket options shared by server and client.
+func sockOpts(o interface{}) *sockOptions {
+	switch opts := o.(type) {
+	case *serverOptions:
+		return &opts.sockOptions
+	case *clientOptions:
+		return &opts.sockOptions
+	}
+	panic("udp: unknown options type")
+}
+
+type udpServBuilder struct {
+}
+
+func (ub *udpServBuilder) Name() string {
+	return core.UDPServBuilder
+}
+
+func (ub *udpServBuilder) Build(opt ...core.BuildOption) core.AcceptorChannel {
+	opts := defaultServeroptions
+	cliOpts := *defaultServeroptions.Options
+	opts.Options = &cliOpts
+	for _, o := range opt {
+		o(&opts)
+	}
+
+	return newServerChannel(&opts)
+}
+
+type udpCliBuilder struct {
+}
+
+func (cb *udpCliBuilder) Name() string {
+	return core.UDPCliBuilder
+}
+
+func (cb *udpCliBuilder) Build(opt ...core.BuildOption) core.ConnectorChannel {
+	cliOpts := defaultCliOptions
+	opts := clientOptions{Options: &cliOpts}
+	for _, o := range opt {
+		o(&opts)
+	}
+
+	return newClientChannel(&opts)
+}
+
+var defaultCliOptions = Options{
+	WriteBufSize:      1024,
+	ReadBufSize:       maxDatagramSize,
+	AutoReconnect:     false,
+	MaxReconnectTimes: 20,
+}
+
+var defaultServeroptions = serverOptions{
+	Options: &defaultCliOptions,
+
+	maxConnNum:         1000 * 10000,
+	idleTimeout:        time.Minute,
+	pendingDatagramNum: 128,
+}
+
+type Options struct {
+	WriteBufSize      int
+	ReadBufSize       int
+	AutoReconnect     bool
+	MaxReconnectTimes int
+	ReconnectPolicy   core.ReconnectPolicy
+	DisconnectedWrite *core.DisconnectedWriteOpts
+	WaterMark         *core.WriteBufferWaterMark
+}
+
+// subChannelOpts returns the options of creating SubChannel.
+func (o *Options) subChannelOpts(reconnect *core.ReconnectOpts) *core.SubChannelOpts {
+	return &core.SubChannelOpts{
+		ReadBufSize:  o.ReadBufSize,
+		WriteBufSize: o.WriteBufSize,
+		WaterMark:    o.WaterMark,
+		Reconnect:    reconnect,
+	}
+}
+
+type serverOptions struct {
+	*Options
+
+	maxConnNum         int
+	idleTimeout        time.Duration
+	pendingDatagramNum int
+	sockOptions
+}
+
+// sockOptions represents the socket options applied to the connection.
+type sockOptions struct {
+	udpWriteBufSize int
+	udpReadBufSize  int
+}
+
+func (so *sockOptions) apply(conn *net.UDPConn) {
+	if so.udpReadBufSize > 0 {
+		conn.SetReadBuffer(so.udpReadBufSize)
+	}
+
+	if so.udpWriteBufSize > 0 {
+		conn.SetWriteBuffer(so.udpWriteBufSize)
+	}
+}
+
+type clientOptions struct {
+	*Options
+	sockOptions
+
+	localAddr net.Addr
+}
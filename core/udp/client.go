@@ -0,0 +1,86 @@
+package udp
+
+import (
+	"net"
+
+	"github.com/amsalt/nginet/core"
+)
+
+type client struct {
+	opts *clientOptions
+	*core.Connector
+	addr net.Addr
+}
+
+func NewClientChannel(opts ...*Options) core.ConnectorChannel {
+	if len(opts) == 0 {
+		return newClientChannel(&clientOptions{Options: &defaultCliOptions})
+	}
+	return newClientChannel(&clientOptions{Options: opts[0]})
+}
+
+func newClientChannel(opts *clientOptions) core.ConnectorChannel {
+	c := &client{opts: opts}
+	c.Connector = core.NewConnector()
+
+	return c
+}
+
+func (c *client) Connect(addr interface{}) (core.SubChannel, error) {
+	netaddr, ok := addr.(net.Addr)
+	if !ok {
+		panic("udp.client connect option must be net.Addr type")
+	}
+	c.addr = netaddr
+
+	conn, err := c.dial(netaddr)
+	if err != nil {
+		return nil, err
+	}
+
+	redial := func() (core.RawConn, error) {
+		conn, err := c.dial(netaddr)
+		if err != nil {
+			return nil, err
+		}
+		return newRawConn(conn), nil
+	}
+
+	subChannel := core.NewSubChannel(newRawConn(conn), c.opts.subChannelOpts(&core.ReconnectOpts{
+		AutoReconnect:     c.opts.AutoReconnect,
+		MaxReconnectTimes: c.opts.MaxReconnectTimes,
+		Policy:            c.opts.ReconnectPolicy,
+		Dial:              redial,
+		DisconnectedWrite: c.opts.DisconnectedWrite,
+	}))
+	c.FireConnect(subChannel)
+	return subChannel, nil
+}
+
+func (c *client) dial(addr net.Addr) (*net.UDPConn, error) {
+	raddr, err := net.ResolveUDPAddr(addr.Network(), addr.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var laddr *net.UDPAddr
+	if c.opts.localAddr != nil {
+		laddr, err = net.ResolveUDPAddr(c.opts.localAddr.Network(), c.opts.localAddr.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := net.DialUDP(raddr.Network(), laddr, raddr)
+	if err != nil {
+		return nil, err
+	}
+	c.opts.sockOptions.apply(conn)
+	return conn, nil
+}
+
+func (c *client) Close() {
+	if subChannel := c.SubChannel(); subChannel != nil {
+		subChannel.Close()
+	}
+}
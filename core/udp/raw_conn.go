@@ -0,0 +1,174 @@
+package udp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+)
+
+var (
+	// ErrPeerClosed represents the remote peer has been closed or expired.
+	ErrPeerClosed = errors.New("udp: peer closed")
+)
+
+// datagram is a reader which returns a whole datagram in one Read.
+type datagram []byte
+
+func (d datagram) Read(p []byte) (int, error) {
+	return copy(p, d), nil
+}
+
+// readDatagram reads data to buf as a whole datagram, fails with core.ErrMessageTruncated
+// if data is larger than the free space of buf.
+func readDatagram(buf bytes.ReadOnlyBuffer, data []byte) error {
+	buf.Reset()
+	if len(data) > len(buf.FreeBytes()) {
+		return fmt.Errorf("%w: %v bytes datagram exceeds ReadBufSize %v", core.ErrMessageTruncated, len(data), len(buf.FreeBytes()))
+	}
+	_, err := buf.ReadFrom(datagram(data))
+	return err
+}
+
+// rawConn is the client side connection, backed by a connected *net.UDPConn.
+type rawConn struct {
+	conn *net.UDPConn
+	buf  []byte // a datagram is read whole to detect the truncation.
+}
+
+func newRawConn(conn *net.UDPConn) core.RawConn {
+	r := &rawConn{conn: conn, buf: make([]byte, maxDatagramSize)}
+	return r
+}
+
+// Write writes a datagram to opposite side.
+func (r *rawConn) Write(msg []byte) error {
+	if r.conn == nil {
+		return errors.New("udp.rawConn Write() failed for conn is nil")
+	}
+	_, err := r.conn.Write(msg)
+	return err
+}
+
+// Read reads one datagram, the unread data of previous datagram will be dropped
+// to keep the datagram boundaries.
+func (r *rawConn) Read(buf bytes.ReadOnlyBuffer) error {
+	n, err := r.conn.Read(r.buf)
+	if err != nil {
+		return err
+	}
+	return readDatagram(buf, r.buf[:n])
+}
+
+// LocalAddr returns the local addr.
+func (r *rawConn) LocalAddr() net.Addr {
+	if r.conn == nil {
+		return nil
+	}
+
+	return r.conn.LocalAddr()
+}
+
+// RemoteAddr return the opposite side addr.
+func (r *rawConn) RemoteAddr() net.Addr {
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.RemoteAddr()
+}
+
+func (r *rawConn) Close() error {
+	if r.conn != nil {
+		return r.conn.Close()
+	}
+	return errors.New("udp.rawConn Close() failed for conn is nil")
+}
+
+// peerConn is the server side connection of a remote peer.
+// All peers share the listening *net.UDPConn, inbound datagrams are
+// dispatched to peerConn by the server.
+type peerConn struct {
+	s    *server
+	addr *net.UDPAddr
+
+	sync.Mutex
+	inbound    chan []byte
+	closed     bool
+	lastActive time.Time
+}
+
+func newPeerConn(s *server, addr *net.UDPAddr, pending int) *peerConn {
+	pc := &peerConn{s: s, addr: addr}
+	pc.inbound = make(chan []byte, pending)
+	pc.lastActive = time.Now()
+	return pc
+}
+
+// deliver queues a datagram received from the peer, drops it if the queue is full.
+func (pc *peerConn) deliver(data []byte) {
+	pc.Lock()
+	defer pc.Unlock()
+
+	if pc.closed {
+		return
+	}
+
+	pc.lastActive = time.Now()
+	select {
+	case pc.inbound <- data:
+	default:
+		// drop datagram as udp does.
+	}
+}
+
+func (pc *peerConn) idle(now time.Time, timeout time.Duration) bool {
+	pc.Lock()
+	defer pc.Unlock()
+
+	return now.Sub(pc.lastActive) >= timeout
+}
+
+// Write writes a datagram to the peer.
+func (pc *peerConn) Write(msg []byte) error {
+	_, err := pc.s.conn.WriteToUDP(msg, pc.addr)
+	return err
+}
+
+// Read reads one datagram, the unread data of previous datagram will be dropped
+// to keep the datagram boundaries.
+func (pc *peerConn) Read(buf bytes.ReadOnlyBuffer) error {
+	data, ok := <-pc.inbound
+	if !ok {
+		return ErrPeerClosed
+	}
+
+	return readDatagram(buf, data)
+}
+
+// LocalAddr returns the local addr.
+func (pc *peerConn) LocalAddr() net.Addr {
+	return pc.s.conn.LocalAddr()
+}
+
+// RemoteAddr return the opposite side addr.
+func (pc *peerConn) RemoteAddr() net.Addr {
+	return pc.addr
+}
+
+// Close detaches the peer from server, the shared socket is kept open.
+func (pc *peerConn) Close() error {
+	pc.Lock()
+	defer pc.Unlock()
+
+	if pc.closed {
+		return ErrPeerClosed
+	}
+	pc.closed = true
+	close(pc.inbound)
+	pc.s.removePeer(pc)
+	return nil
+}
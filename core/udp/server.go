@@ -0,0 +1,231 @@
+package udp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/amsalt/log"
+	"github.com/amsalt/nginet/core"
+)
+
+// server represents a udp server.
+// implements the AcceptorChannel interface.
+// Each remote address is mapped to its own SubChannel.
+type server struct {
+	opts *serverOptions
+	*core.AttrMap
+	*core.Acceptor
+
+	conn      *net.UDPConn
+	localAddr net.Addr
+
+	peerMutex sync.Mutex
+	peers     map[string]*peer
+
+	closeChan chan byte
+	closeOnce sync.Once
+}
+
+// peer binds the peerConn with its SubChannel.
+type peer struct {
+	conn    *peerConn
+	channel core.SubChannel
+}
+
+func newServerChannel(opts *serverOptions) core.AcceptorChannel {
+	s := new(server)
+	s.opts = opts
+	s.Acceptor = core.NewAcceptor()
+	s.peers = make(map[string]*peer)
+	s.closeChan = make(chan byte)
+
+	return s
+}
+
+// Write writes message to opposite side.
+func (s *server) Write(msg interface{}, extra ...interface{}) error {
+	// nothing to do.
+	return nil
+}
+
+// LocalAddr returns the local addr.
+func (s *server) LocalAddr() net.Addr {
+	return s.localAddr
+}
+
+// RemoteAddr return the opposite side addr.
+func (s *server) RemoteAddr() net.Addr {
+	panic("not implementation")
+}
+
+// Listen announces on the local network address.
+func (s *server) Listen(addr net.Addr) {
+	s.localAddr = addr
+
+	udpAddr, err := net.ResolveUDPAddr(addr.Network(), addr.String())
+	if err != nil {
+		panic(fmt.Errorf("UDP server init error: %+v", err))
+	}
+
+	conn, err := net.ListenUDP(udpAddr.Network(), udpAddr)
+	if err != nil {
+		panic(fmt.Errorf("UDP server init error: %+v", err))
+	}
+
+	s.opts.sockOptions.apply(conn)
+	s.conn = conn
+}
+
+// Accept reads datagrams and dispatches them to the SubChannel of remote address.
+func (s *server) Accept() {
+	if s.opts.idleTimeout > 0 {
+		go s.expire()
+	}
+	s.serve()
+}
+
+func (s *server) serve() {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			// Stop
+			return
+		}
+
+		data := make([]byte, n)
+		copy(data, buf[:n])
+
+		p := s.getOrCreatePeer(addr)
+		if p != nil {
+			p.conn.deliver(data)
+		}
+	}
+}
+
+// Close closes the SubChannels of all peers and the listening connection.
+func (s *server) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closeChan)
+		for _, p := range s.allPeers() {
+			p.channel.Close()
+		}
+		s.conn.Close()
+	})
+}
+
+// Shutdown stops serving new peers and closes all SubChannels gracefully,
+// then closes the listening connection.
+func (s *server) Shutdown(ctx context.Context) error {
+	err := s.Acceptor.Shutdown(ctx)
+	s.Close()
+	return err
+}
+
+func (s *server) getOrCreatePeer(addr *net.UDPAddr) *peer {
+	key := addr.String()
+
+	s.peerMutex.Lock()
+	p, ok := s.peers[key]
+	if ok {
+		s.peerMutex.Unlock()
+		return p
+	}
+
+	if s.IsShutdown() {
+		s.peerMutex.Unlock()
+		return nil
+	}
+
+	if !s.validate() {
+		s.peerMutex.Unlock()
+		log.Debugf("too many udp peers, datagram from %+v will be dropped", addr)
+		return nil
+	}
+
+	conn := newPeerConn(s, addr, s.opts.pendingDatagramNum)
+	p = &peer{conn: conn}
+	p.channel = core.NewSubChannel(conn, s.opts.subChannelOpts(nil))
+	s.peers[key] = p
+	s.peerMutex.Unlock()
+
+	s.processNewPeer(p)
+	return p
+}
+
+func (s *server) validate() bool {
+	if len(s.peers) >= s.opts.maxConnNum {
+		return false
+	}
+	return true
+}
+
+func (s *server) processNewPeer(p *peer) {
+	log.Debugf("new udp peer: %+v", p.conn.RemoteAddr())
+	s.FireConnect(p.channel)
+}
+
+func (s *server) removePeer(pc *peerConn) {
+	key := pc.addr.String()
+
+	s.peerMutex.Lock()
+	p, ok := s.peers[key]
+	if ok && p.conn == pc {
+		delete(s.peers, key)
+	}
+	s.peerMutex.Unlock()
+
+	if ok {
+		s.RemoveSubChannel(p.channel)
+	}
+}
+
+// expire closes the SubChannels of peers which are idle more than idleTimeout.
+func (s *server) expire() {
+	interval := s.opts.idleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, p := range s.idlePeers(now) {
+				log.Debugf("udp peer %+v expired", p.conn.RemoteAddr())
+				p.channel.Close()
+			}
+		case <-s.closeChan:
+			return
+		}
+	}
+}
+
+func (s *server) allPeers() []*peer {
+	s.peerMutex.Lock()
+	defer s.peerMutex.Unlock()
+
+	peers := make([]*peer, 0, len(s.peers))
+	for _, p := range s.peers {
+		peers = append(peers, p)
+	}
+	return peers
+}
+
+func (s *server) idlePeers(now time.Time) []*peer {
+	var idles []*peer
+	for _, p := range s.allPeers() {
+		if p.conn.idle(now, s.opts.idleTimeout) {
+			idles = append(idles, p)
+		}
+	}
+	return idles
+}
@@ -0,0 +1,202 @@
+package unix
+
+import (
+	"time"
+
+	"github.com/amsalt/nginet/core"
+)
+
+func init() {
+	core.Register(&unixServBuilder{})
+	core.RegisterConnector(&unixCliBuilder{})
+}
+
+// WithWriteBufSize sets max size of pending wirte.
+func WithWriteBufSize(s int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).WriteBufSize = s
+	}
+}
+
+// WithReadBufSize sets max size of pending read.
+// For unixpacket, it should be large enough to hold a whole packet.
+func WithReadBufSize(s int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).ReadBufSize = s
+	}
+}
+
+// WithWriteBatchSize sets max number of queued messages coalesced into one vectored write,
+// 1 means writing one by one.
+func WithWriteBatchSize(n int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).WriteBatchSize = n
+	}
+}
+
+// WithWriteBufferWaterMark sets the watermarks of pending outbound bytes,
+// see core.WriteBufferWaterMark.
+func WithWriteBufferWaterMark(low, high int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).WaterMark = &core.WriteBufferWaterMark{Low: low, High: high}
+	}
+}
+
+// WithMaxConnNum sets max connection number.
+func WithMaxConnNum(mcn int) core.BuildOption {
+	return func(o interface{}) {
+		o.(*serverOptions).maxConnNum = mcn
+	}
+}
+
+// WithRemoveStaleSocket sets whether to remove the socket file left by a
+// dead process when Listen. The default is true.
+func WithRemoveStaleSocket(b bool) core.BuildOption {
+	return func(o interface{}) {
+		o.(*serverOptions).removeStale = b
+	}
+}
+
+// WithPeerCred sets whether to retrieve the credentials of the peer process,
+// which are stored in the SubChannel's AttrMap with key AttrPeerCred.
+// The default is true.
+func WithPeerCred(b bool) core.BuildOption {
+	return func(o interface{}) {
+		options(o).PeerCred = b
+	}
+}
+
+// WithAutoReconnect sets whether auto reconnect when disconnect with server.
+func WithAutoReconnect(r bool) core.BuildOption {
+	return func(o interface{}) {
+		options(o).AutoReconnect = r
+	}
+}
+
+// WithMaxReconnectTimes sets max number for trying reconnect with server.
+func WithMaxReconnectTimes(n int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).MaxReconnectTimes = n
+	}
+}
+
+// WithReconnectPolicy sets the policy deciding when to retry reconnecting,
+// the default is an exponential back off with jitter limited by MaxReconnectTimes.
+func WithReconnectPolicy(p core.ReconnectPolicy) core.BuildOption {
+	return func(o interface{}) {
+		options(o).ReconnectPolicy = p
+	}
+}
+
+// WithDisconnectedWrite sets how to handle the messages written while reconnecting,
+// the default is buffering them and replaying after reconnected.
+func WithDisconnectedWrite(opts *core.DisconnectedWriteOpts) core.BuildOption {
+	return func(o interface{}) {
+		options(o).DisconnectedWrite = opts
+	}
+}
+
+// WithDialTimeout sets the max duration for client connecting.
+// The default is 0, which means no timeout.
+func WithDialTimeout(d time.Duration) core.BuildOption {
+	return func(o interface{}) {
+		o.(*clientOptions).dialTimeout = d
+	}
+}
+
+// options returns the Options shared by server and client.
+func options(o interface{}) *Options {
+	switch opts := o.(type) {
+	case *serverOptions:
+		return opts.Options
+	case *clientOptions:
+		return opts.Options
+	}
+	panic("unix: unknown options type")
+}
+
+type unixServBuilder struct {
+}
+
+func (ub *unixServBuilder) Name() string {
+	return core.UnixServBuilder
+}
+
+func (ub *unixServBuilder) Build(opt ...core.BuildOption) core.AcceptorChannel {
+	opts := defaultServeroptions
+	cliOpts := *defaultServeroptions.Options
+	opts.Options = &cliOpts
+	for _, o := range opt {
+		o(&opts)
+	}
+
+	return newServerChannel(&opts)
+}
+
+type unixCliBuilder struct {
+}
+
+func (cb *unixCliBuilder) Name() string {
+	return core.UnixCliBuilder
+}
+
+func (cb *unixCliBuilder) Build(opt ...core.BuildOption) core.ConnectorChannel {
+	cliOpts := defaultCliOptions
+	opts := clientOptions{Options: &cliOpts}
+	for _, o := range opt {
+		o(&opts)
+	}
+
+	return newClientChannel(&opts)
+}
+
+var defaultCliOptions = Options{
+	WriteBufSize:      1024,
+	ReadBufSize:       1024,
+	AutoReconnect:     false,
+	MaxReconnectTimes: 20,
+	PeerCred:          true,
+}
+
+var defaultServeroptions = serverOptions{
+	Options: &defaultCliOptions,
+
+	maxConnNum:  1000 * 10000,
+	removeStale: true,
+}
+
+type Options struct {
+	WriteBufSize      int
+	ReadBufSize       int
+	AutoReconnect     bool
+	MaxReconnectTimes int
+	ReconnectPolicy   core.ReconnectPolicy
+	DisconnectedWrite *core.DisconnectedWriteOpts
+	WaterMark         *core.WriteBufferWaterMark
+	WriteBatchSize    int
+	PeerCred          bool
+}
+
+// subChannelOpts returns the options of creating SubChannel.
+func (o *Options) subChannelOpts(reconnect *core.ReconnectOpts) *core.SubChannelOpts {
+	return &core.SubChannelOpts{
+		ReadBufSize:    o.ReadBufSize,
+		WriteBufSize:   o.WriteBufSize,
+		WaterMark:      o.WaterMark,
+		WriteBatchSize: o.WriteBatchSize,
+		Reconnect:      reconnect,
+	}
+}
+
+type serverOptions struct {
+	*Options
+
+	maxConnNum  int
+	removeStale bool
+}
+
+type clientOptions struct {
+	*Options
+
+	dialTimeout time.Duration
+}
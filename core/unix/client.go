@@ -0,0 +1,72 @@
+package unix
+
+import (
+	"net"
+
+	"github.com/amsalt/nginet/core"
+)
+
+type client struct {
+	opts *clientOptions
+	*core.Connector
+	addr net.Addr
+}
+
+func NewClientChannel(opts ...*Options) core.ConnectorChannel {
+	if len(opts) == 0 {
+		return newClientChannel(&clientOptions{Options: &defaultCliOptions})
+	}
+	return newClientChannel(&clientOptions{Options: opts[0]})
+}
+
+func newClientChannel(opts *clientOptions) core.ConnectorChannel {
+	c := &client{opts: opts}
+	c.Connector = core.NewConnector()
+
+	return c
+}
+
+// Connect connects to the unix or unixpacket address.
+func (c *client) Connect(addr interface{}) (core.SubChannel, error) {
+	netaddr, ok := addr.(net.Addr)
+	if !ok {
+		panic("unix.client connect option must be net.Addr type")
+	}
+	c.addr = netaddr
+	conn, err := net.DialTimeout(netaddr.Network(), netaddr.String(), c.opts.dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	packet := isPacketNetwork(netaddr.Network())
+	var subChannel core.SubChannel
+	redial := func() (core.RawConn, error) {
+		conn, err := net.DialTimeout(netaddr.Network(), netaddr.String(), c.opts.dialTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if c.opts.PeerCred {
+			storePeerCred(subChannel, conn)
+		}
+		return newRawConn(conn, packet), nil
+	}
+
+	subChannel = core.NewSubChannel(newRawConn(conn, packet), c.opts.subChannelOpts(&core.ReconnectOpts{
+		AutoReconnect:     c.opts.AutoReconnect,
+		MaxReconnectTimes: c.opts.MaxReconnectTimes,
+		Policy:            c.opts.ReconnectPolicy,
+		Dial:              redial,
+		DisconnectedWrite: c.opts.DisconnectedWrite,
+	}))
+	if c.opts.PeerCred {
+		storePeerCred(subChannel, conn)
+	}
+	c.FireConnect(subChannel)
+	return subChannel, nil
+}
+
+func (c *client) Close() {
+	if subChannel := c.SubChannel(); subChannel != nil {
+		subChannel.Close()
+	}
+}
@@ -0,0 +1,47 @@
+package unix
+
+import (
+	"errors"
+	"net"
+
+	"github.com/amsalt/log"
+	"github.com/amsalt/nginet/core"
+)
+
+const (
+	// AttrPeerCred is the key of the peer process credentials in SubChannel's AttrMap.
+	AttrPeerCred = "unix.peer_cred"
+)
+
+var (
+	// ErrPeerCredUnsupported represents the platform can not retrieve peer credentials.
+	ErrPeerCredUnsupported = errors.New("unix: peer credentials unsupported")
+)
+
+// PeerCred represents the credentials of the process on the other side of the socket.
+type PeerCred struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+// PeerCredOf returns the peer credentials stored in the channel's AttrMap,
+// nil if not retrieved.
+func PeerCredOf(channel core.Channel) *PeerCred {
+	cred, _ := channel.Attr().Value(AttrPeerCred).(*PeerCred)
+	return cred
+}
+
+func storePeerCred(channel core.Channel, conn net.Conn) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return
+	}
+
+	cred, err := getPeerCred(uc)
+	if err != nil {
+		log.Debugf("unix: retrieve peer credentials failed: %+v", err)
+		return
+	}
+	channel.Attr().SetValue(AttrPeerCred, cred)
+}
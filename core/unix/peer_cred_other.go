@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package unix
+
+import "net"
+
+// getPeerCred is not supported on this platform.
+func getPeerCred(conn *net.UnixConn) (*PeerCred, error) {
+	return nil, ErrPeerCredUnsupported
+}
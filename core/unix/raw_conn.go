@@ -0,0 +1,115 @@
+package unix
+
+import (
+	stdbytes "bytes"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+)
+
+type rawConn struct {
+	conn   net.Conn
+	packet bool
+
+	// packetBuf reads a unixpacket with one more byte than the read buffer to detect the truncation.
+	packetBuf []byte
+}
+
+// newRawConn creates a RawConn, packet represents the unixpacket network
+// whose packet boundaries should be kept.
+func newRawConn(conn net.Conn, packet bool) core.RawConn {
+	r := &rawConn{conn: conn, packet: packet}
+	return r
+}
+
+// Write writes message to opposite side.
+func (r *rawConn) Write(msg []byte) error {
+	if r.conn == nil {
+		return errors.New("unix.rawConn Write() failed for conn is nil")
+	}
+	_, err := r.conn.Write(msg)
+	return err
+}
+
+// WriteBuffers writes bufs by a vectored write, for unixpacket
+// they're written one by one to keep the packet boundaries.
+func (r *rawConn) WriteBuffers(bufs net.Buffers) error {
+	if r.conn == nil {
+		return errors.New("unix.rawConn WriteBuffers() failed for conn is nil")
+	}
+
+	if r.packet {
+		for _, b := range bufs {
+			if _, err := r.conn.Write(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	_, err := bufs.WriteTo(r.conn)
+	return err
+}
+
+// Read reads data from the connection. For unixpacket, one packet is read
+// each time and the unread data of previous packet will be dropped.
+func (r *rawConn) Read(buf bytes.ReadOnlyBuffer) error {
+	if r.packet {
+		return r.readPacket(buf)
+	}
+	if buf.Len() == 0 {
+		buf.Reset()
+	}
+	_, err := buf.ReadFrom(r.conn)
+	return err
+}
+
+// readPacket reads one packet, fails with core.ErrMessageTruncated if the packet is
+// larger than the free space of buf.
+func (r *rawConn) readPacket(buf bytes.ReadOnlyBuffer) error {
+	buf.Reset()
+	size := len(buf.FreeBytes())
+	if len(r.packetBuf) != size+1 {
+		r.packetBuf = make([]byte, size+1)
+	}
+
+	n, err := r.conn.Read(r.packetBuf)
+	if err != nil {
+		return err
+	}
+	if n > size {
+		return fmt.Errorf("%w: packet exceeds ReadBufSize %v", core.ErrMessageTruncated, size)
+	}
+	_, err = buf.ReadFrom(stdbytes.NewReader(r.packetBuf[:n]))
+	return err
+}
+
+// LocalAddr returns the local addr.
+func (r *rawConn) LocalAddr() net.Addr {
+	if r.conn == nil {
+		return nil
+	}
+
+	return r.conn.LocalAddr()
+}
+
+// RemoteAddr return the opposite side addr.
+func (r *rawConn) RemoteAddr() net.Addr {
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.RemoteAddr()
+}
+
+func (r *rawConn) Close() error {
+	if r.conn != nil {
+		return r.conn.Close()
+	}
+	return errors.New("unix.rawConn Close() failed for conn is nil")
+}
+
+func isPacketNetwork(network string) bool {
+	return network == "unixpacket"
+}
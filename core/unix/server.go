@@ -0,0 +1,175 @@
+package unix
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/amsalt/log"
+	"github.com/amsalt/nginet/core"
+)
+
+// server represents a unix domain socket server, listens on unix or unixpacket network.
+// implements the AcceptorChannel interface.
+type server struct {
+	opts *serverOptions
+	*core.AttrMap
+	*core.Acceptor
+
+	ln        *net.UnixListener
+	localAddr net.Addr
+	packet    bool
+
+	retryDelay time.Duration
+}
+
+func newServerChannel(opts *serverOptions) core.AcceptorChannel {
+	s := new(server)
+	s.opts = opts
+	s.Acceptor = core.NewAcceptor()
+
+	return s
+}
+
+// Write writes message to opposite side.
+func (s *server) Write(msg interface{}, extra ...interface{}) error {
+	// nothing to do.
+	return nil
+}
+
+// LocalAddr returns the local addr.
+func (s *server) LocalAddr() net.Addr {
+	return s.localAddr
+}
+
+// RemoteAddr return the opposite side addr.
+func (s *server) RemoteAddr() net.Addr {
+	panic("not implementation")
+}
+
+// Listen announces on the local network address.
+func (s *server) Listen(addr net.Addr) {
+	s.localAddr = addr
+	s.packet = isPacketNetwork(addr.Network())
+
+	unixAddr, err := net.ResolveUnixAddr(addr.Network(), addr.String())
+	if err != nil {
+		panic(fmt.Errorf("Unix server init error: %+v", err))
+	}
+
+	if s.opts.removeStale {
+		removeStaleSocket(unixAddr)
+	}
+
+	ln, err := net.ListenUnix(unixAddr.Network(), unixAddr)
+	if err != nil {
+		panic(fmt.Errorf("Unix server init error: %+v", err))
+	}
+	ln.SetUnlinkOnClose(true)
+	s.ln = ln
+}
+
+// removeStaleSocket removes the socket file which nobody listens on.
+func removeStaleSocket(addr *net.UnixAddr) {
+	// abstract socket has no file.
+	if addr.Name == "" || addr.Name[0] == '@' {
+		return
+	}
+
+	fi, err := os.Stat(addr.Name)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return
+	}
+
+	conn, err := net.DialTimeout(addr.Network(), addr.Name, time.Second)
+	if err == nil {
+		// someone is serving on it, let Listen report the error.
+		conn.Close()
+		return
+	}
+
+	log.Infof("remove stale unix socket file: %+v", addr.Name)
+	if err := os.Remove(addr.Name); err != nil {
+		log.Warningf("remove stale unix socket file failed: %+v", err)
+	}
+}
+
+// Accept accepts the next incoming call
+func (s *server) Accept() {
+	s.accept()
+}
+
+func (s *server) accept() {
+	for {
+		conn, err := s.ln.AcceptUnix()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				s.waitWhenTemporaryErr()
+				continue
+			}
+			// Stop
+			return
+		}
+		s.resetWhenSucc()
+
+		if !s.validate(conn) {
+			conn.Close()
+			continue
+		}
+
+		s.processNewConn(conn)
+	}
+}
+
+func (s *server) Close() {
+	s.ln.Close()
+}
+
+// Shutdown stops accepting and closes all SubChannels gracefully.
+func (s *server) Shutdown(ctx context.Context) error {
+	s.Close()
+	return s.Acceptor.Shutdown(ctx)
+}
+
+func (s *server) waitWhenTemporaryErr() {
+	if s.retryDelay == 0 {
+		s.retryDelay = 5 * time.Millisecond
+	} else {
+		s.retryDelay *= 2
+	}
+	if max := 1 * time.Second; s.retryDelay > max {
+		s.retryDelay = max
+	}
+
+	time.Sleep(s.retryDelay)
+}
+
+func (s *server) resetWhenSucc() {
+	s.retryDelay = 0
+}
+
+func (s *server) validate(conn *net.UnixConn) bool {
+	if len(s.SubChannels()) >= s.opts.maxConnNum {
+		return false
+	}
+	return true
+}
+
+func (s *server) processNewConn(conn *net.UnixConn) {
+	log.Debugf("new connection: %+v", conn)
+	subChannel := core.NewSubChannel(newRawConn(conn, s.packet), s.opts.subChannelOpts(nil))
+	if s.opts.PeerCred {
+		storePeerCred(subChannel, conn)
+	}
+	// the peer may send data once connected, the SubChannel starts reading
+	// after FireConnect added the handlers, see core.Acceptor.FireConnect.
+	s.FireConnect(subChannel)
+
+	// the closed SubChannel is not counted by maxConnNum any more.
+	go func() {
+		<-subChannel.CloseNotify()
+		s.RemoveSubChannel(subChannel)
+	}()
+}
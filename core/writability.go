@@ -0,0 +1,98 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// WriteBufferWaterMark represents the watermarks of pending outbound bytes of a SubChannel.
+// The channel becomes unwritable when the pending bytes above High, and writable again
+// when they drop to Low. A message is pending from queued until written to the RawConn
+// or dropped, including the time buffered while reconnecting.
+type WriteBufferWaterMark struct {
+	Low  int
+	High int
+}
+
+func (wm *WriteBufferWaterMark) validate() {
+	if wm.Low < 0 || wm.High <= 0 || wm.Low > wm.High {
+		panic(fmt.Errorf("invalid WriteBufferWaterMark, low: %d, high: %d", wm.Low, wm.High))
+	}
+}
+
+// WritabilityChangedEvent is fired through the pipeline when the writability of a SubChannel changed.
+type WritabilityChangedEvent struct {
+	Writable bool
+}
+
+// IsWritable returns false if the pending outbound bytes exceeded the high watermark
+// and not yet dropped to the low watermark. Always true if no watermark set.
+func (dsc *DefaultSubChannel) IsWritable() bool {
+	return atomic.LoadInt32(&dsc.unwritable) == 0
+}
+
+// messageSize estimates the size of msg for the watermarks, 0 if no watermark set.
+func (dsc *DefaultSubChannel) messageSize(msg interface{}) int {
+	if dsc.waterMark == nil {
+		return 0
+	}
+	return dsc.sizeEstimator(msg)
+}
+
+// addPendingBytes adds delta to the pending outbound bytes and updates the writability.
+func (dsc *DefaultSubChannel) addPendingBytes(delta int) {
+	if dsc.waterMark == nil || delta == 0 {
+		return
+	}
+	atomic.AddInt64(&dsc.pendingBytes, int64(delta))
+	dsc.updateWritability()
+}
+
+// pendingPromise releases the pending bytes of the message when completed, so that
+// the bytes are counted until the message written or dropped, including the time
+// buffered while reconnecting.
+type pendingPromise struct {
+	WritePromise
+	dsc  *DefaultSubChannel
+	size int
+	once sync.Once
+}
+
+func (pp *pendingPromise) Complete(err error) bool {
+	pp.once.Do(func() { pp.dsc.addPendingBytes(-pp.size) })
+	return pp.WritePromise.Complete(err)
+}
+
+// trackPending returns the promise releases size pending bytes when completed.
+func (dsc *DefaultSubChannel) trackPending(promise WritePromise, size int) WritePromise {
+	if size == 0 {
+		return promise
+	}
+	if promise == nil {
+		promise = NewWritePromise()
+	}
+	return &pendingPromise{WritePromise: promise, dsc: dsc, size: size}
+}
+
+// updateWritability fires WritabilityChangedEvent if the watermark crossed, it loops
+// until the writability consistent with the pending bytes for concurrent updating.
+func (dsc *DefaultSubChannel) updateWritability() {
+	for {
+		pending := atomic.LoadInt64(&dsc.pendingBytes)
+		switch {
+		case pending > int64(dsc.waterMark.High):
+			if !atomic.CompareAndSwapInt32(&dsc.unwritable, 0, 1) {
+				return
+			}
+			dsc.FireEvent(WritabilityChangedEvent{Writable: false})
+		case pending <= int64(dsc.waterMark.Low):
+			if !atomic.CompareAndSwapInt32(&dsc.unwritable, 1, 0) {
+				return
+			}
+			dsc.FireEvent(WritabilityChangedEvent{Writable: true})
+		default:
+			return
+		}
+	}
+}
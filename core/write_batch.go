@@ -0,0 +1,116 @@
+package core
+
+import (
+	"net"
+
+	"github.com/amsalt/log"
+)
+
+const (
+	// defaultWriteBatchSize is the max number of messages coalesced into one write.
+	defaultWriteBatchSize = 64
+)
+
+// BatchWriter is implemented by the RawConn which writes multiple buffers at once,
+// such as the vectored write(writev) of stream connections.
+type BatchWriter interface {
+	WriteBuffers(bufs net.Buffers) error
+}
+
+// outboundBatcher is implemented by the Channel which coalesces the data written by HeadContext.
+type outboundBatcher interface {
+	// appendOutbound appends data to the current batch, returns false if not batching.
+	appendOutbound(conn RawConn, data []byte, promise WritePromise) bool
+}
+
+// writeBatch holds the data written by HeadContext during a batch of writeloop.
+type writeBatch struct {
+	active   bool
+	conn     RawConn
+	bufs     net.Buffers
+	promises []WritePromise
+}
+
+func (wb *writeBatch) reset() {
+	wb.conn = nil
+	wb.bufs = nil
+	wb.promises = nil
+}
+
+// beginBatch starts coalescing the data written to a BatchWriter.
+func (dsc *DefaultSubChannel) beginBatch() {
+	if dsc.writeBatchSize <= 1 {
+		return
+	}
+	dsc.batchMutex.Lock()
+	dsc.batch.active = true
+	dsc.batchMutex.Unlock()
+}
+
+// endBatch stops coalescing and flushes the batch.
+func (dsc *DefaultSubChannel) endBatch() {
+	if dsc.writeBatchSize <= 1 {
+		return
+	}
+	dsc.batchMutex.Lock()
+	dsc.batch.active = false
+	conn, bufs, promises := dsc.batch.conn, dsc.batch.bufs, dsc.batch.promises
+	dsc.batch.reset()
+	dsc.batchMutex.Unlock()
+
+	dsc.flushBuffers(conn, bufs, promises)
+}
+
+func (dsc *DefaultSubChannel) appendOutbound(conn RawConn, data []byte, promise WritePromise) bool {
+	if _, ok := conn.(BatchWriter); !ok {
+		return false
+	}
+
+	dsc.batchMutex.Lock()
+	if !dsc.batch.active {
+		dsc.batchMutex.Unlock()
+		return false
+	}
+
+	// the connection replaced during the batch, flush the data of previous one.
+	var prevConn RawConn
+	var prevBufs net.Buffers
+	var prevPromises []WritePromise
+	if dsc.batch.conn != nil && dsc.batch.conn != conn {
+		prevConn, prevBufs, prevPromises = dsc.batch.conn, dsc.batch.bufs, dsc.batch.promises
+		dsc.batch.reset()
+	}
+
+	dsc.batch.conn = conn
+	dsc.batch.bufs = append(dsc.batch.bufs, data)
+	if promise != nil {
+		dsc.batch.promises = append(dsc.batch.promises, promise)
+	}
+	dsc.batchMutex.Unlock()
+
+	dsc.flushBuffers(prevConn, prevBufs, prevPromises)
+	return true
+}
+
+// flushBuffers writes bufs to conn by a single vectored write, the promises
+// will be completed with the result.
+func (dsc *DefaultSubChannel) flushBuffers(conn RawConn, bufs net.Buffers, promises []WritePromise) {
+	if len(bufs) == 0 {
+		return
+	}
+
+	var err error
+	if len(bufs) == 1 {
+		err = conn.Write(bufs[0])
+	} else {
+		err = conn.(BatchWriter).WriteBuffers(bufs)
+	}
+
+	for _, promise := range promises {
+		promise.Complete(err)
+	}
+	if err != nil {
+		log.Errorf("subchannel flush err: %+v", err)
+		dsc.writeFailed(conn, err)
+	}
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrMessageDropped represents the message is dropped before written to the connection.
+	ErrMessageDropped = errors.New("message dropped")
+
+	// ErrUnsupportedMessage represents the message reaches the HeadContext is neither
+	// []byte nor bytes.WriteOnlyBuffer.
+	ErrUnsupportedMessage = errors.New("unsupported message type")
+)
+
+// WriteFuture represents the result of an asynchronous write.
+type WriteFuture interface {
+	// Done returns a channel which is closed when the write completed.
+	Done() <-chan byte
+
+	// Err returns the error of the write, nil if succeeded or not completed.
+	Err() error
+
+	// Wait blocks until the write completed and returns its error.
+	Wait() error
+}
+
+// WritePromise is a writable WriteFuture, it's completed when the message
+// written to the RawConn by the HeadContext, or failed with the error.
+type WritePromise interface {
+	WriteFuture
+
+	// Complete completes the promise with err, returns false if already completed.
+	Complete(err error) bool
+}
+
+type defaultWritePromise struct {
+	done chan byte
+	once sync.Once
+	err  error
+}
+
+// NewWritePromise returns a new instance of WritePromise.
+func NewWritePromise() WritePromise {
+	return &defaultWritePromise{done: make(chan byte)}
+}
+
+// newFailedFuture returns a WriteFuture which has failed with err.
+func newFailedFuture(err error) WriteFuture {
+	p := NewWritePromise()
+	p.Complete(err)
+	return p
+}
+
+func (p *defaultWritePromise) Done() <-chan byte {
+	return p.done
+}
+
+func (p *defaultWritePromise) Err() error {
+	select {
+	case <-p.done:
+		return p.err
+	default:
+		return nil
+	}
+}
+
+func (p *defaultWritePromise) Wait() error {
+	<-p.done
+	return p.err
+}
+
+func (p *defaultWritePromise) Complete(err error) bool {
+	completed := false
+	p.once.Do(func() {
+		p.err = err
+		close(p.done)
+		completed = true
+	})
+	return completed
+}
+
+// completePromise completes the promise if not nil.
+func completePromise(promise WritePromise, err error) {
+	if promise != nil {
+		promise.Complete(err)
+	}
+}
+
+// pendingWrite is put to the write queue by WriteAsync and WriteAndFlush,
+// carries the message with its promise and flush flag.
+type pendingWrite struct {
+	msg     interface{}
+	promise WritePromise
+	flush   bool
+}
+
+// writeFailer is implemented by the Channel which handles the error of writing to the RawConn.
+type writeFailer interface {
+	writeFailed(conn RawConn, err error)
+}
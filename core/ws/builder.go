@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"net"
 	"time"
 
 	"github.com/amsalt/nginet/core"
@@ -8,19 +9,28 @@ import (
 
 func init() {
 	core.Register(&wsServBuilder{})
+	core.RegisterConnector(&wsCliBuilder{})
 }
 
 // WithWriteBufSize sets max size of pending wirte.
 func WithWriteBufSize(s int) core.BuildOption {
 	return func(o interface{}) {
-		o.(*serverOptions).WriteBufSize = s
+		options(o).WriteBufSize = s
 	}
 }
 
 // WithReadBufSize sets max size of pending read.
 func WithReadBufSize(s int) core.BuildOption {
 	return func(o interface{}) {
-		o.(*serverOptions).ReadBufSize = s
+		options(o).ReadBufSize = s
+	}
+}
+
+// WithWriteBufferWaterMark sets the watermarks of pending outbound bytes,
+// see core.WriteBufferWaterMark.
+func WithWriteBufferWaterMark(low, high int) core.BuildOption {
+	return func(o interface{}) {
+		options(o).WaterMark = &core.WriteBufferWaterMark{Low: low, High: high}
 	}
 }
 
@@ -58,17 +68,59 @@ func WithKeyFile(kf string) core.BuildOption {
 // WithAutoReconnect sets whether auto reconnect when disconnect with server.
 func WithAutoReconnect(r bool) core.BuildOption {
 	return func(o interface{}) {
-		o.(*serverOptions).AutoReconnect = r
+		options(o).AutoReconnect = r
 	}
 }
 
 // WithMaxReconnectTimes sets max number for trying reconnect with server.
 func WithMaxReconnectTimes(n int) core.BuildOption {
 	return func(o interface{}) {
-		o.(*serverOptions).MaxReconnectTimes = n
+		options(o).MaxReconnectTimes = n
+	}
+}
+
+// WithReconnectPolicy sets the policy deciding when to retry reconnecting,
+// the default is an exponential back off with jitter limited by MaxReconnectTimes.
+func WithReconnectPolicy(p core.ReconnectPolicy) core.BuildOption {
+	return func(o interface{}) {
+		options(o).ReconnectPolicy = p
+	}
+}
+
+// WithDisconnectedWrite sets how to handle the messages written while reconnecting,
+// the default is buffering them and replaying after reconnected.
+func WithDisconnectedWrite(opts *core.DisconnectedWriteOpts) core.BuildOption {
+	return func(o interface{}) {
+		options(o).DisconnectedWrite = opts
+	}
+}
+
+// WithDialTimeout sets the max duration for client connecting (including the
+// websocket handshake). The default is 0, which means no timeout.
+func WithDialTimeout(d time.Duration) core.BuildOption {
+	return func(o interface{}) {
+		o.(*clientOptions).dialTimeout = d
+	}
+}
+
+// WithLocalAddr sets the local address to bind when client connecting.
+func WithLocalAddr(addr net.Addr) core.BuildOption {
+	return func(o interface{}) {
+		o.(*clientOptions).localAddr = addr
 	}
 }
 
+// options returns the Options shared by server and client.
+func options(o interface{}) *Options {
+	switch opts := o.(type) {
+	case *serverOptions:
+		return opts.Options
+	case *clientOptions:
+		return opts.Options
+	}
+	panic("ws: unknown options type")
+}
+
 type wsServBuilder struct {
 }
 
@@ -78,6 +130,8 @@ func (tb *wsServBuilder) Name() string {
 
 func (tb *wsServBuilder) Build(opt ...core.BuildOption) core.AcceptorChannel {
 	opts := defaultServeroptions
+	cliOpts := *defaultServeroptions.Options
+	opts.Options = &cliOpts
 	for _, o := range opt {
 		o(&opts)
 	}
@@ -85,6 +139,23 @@ func (tb *wsServBuilder) Build(opt ...core.BuildOption) core.AcceptorChannel {
 	return newServerChannel(&opts)
 }
 
+type wsCliBuilder struct {
+}
+
+func (tb *wsCliBuilder) Name() string {
+	return core.WebsocketCliBuilder
+}
+
+func (tb *wsCliBuilder) Build(opt ...core.BuildOption) core.ConnectorChannel {
+	cliOpts := defaultCliOptions
+	opts := clientOptions{Options: &cliOpts}
+	for _, o := range opt {
+		o(&opts)
+	}
+
+	return newClientChannel(&opts)
+}
+
 var defaultCliOptions = Options{
 	WriteBufSize:      1024,
 	ReadBufSize:       1024,
@@ -103,6 +174,19 @@ type Options struct {
 	ReadBufSize       int
 	AutoReconnect     bool
 	MaxReconnectTimes int
+	ReconnectPolicy   core.ReconnectPolicy
+	DisconnectedWrite *core.DisconnectedWriteOpts
+	WaterMark         *core.WriteBufferWaterMark
+}
+
+// subChannelOpts returns the options of creating SubChannel.
+func (o *Options) subChannelOpts(reconnect *core.ReconnectOpts) *core.SubChannelOpts {
+	return &core.SubChannelOpts{
+		ReadBufSize:  o.ReadBufSize,
+		WriteBufSize: o.WriteBufSize,
+		WaterMark:    o.WaterMark,
+		Reconnect:    reconnect,
+	}
 }
 
 type serverOptions struct {
@@ -117,3 +201,10 @@ type serverOptions struct {
 	certFile string
 	keyFile  string
 }
+
+type clientOptions struct {
+	*Options
+
+	dialTimeout time.Duration
+	localAddr   net.Addr
+}
@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"net"
 	"net/http"
 
 	"github.com/amsalt/log"
@@ -11,50 +12,67 @@ import (
 type client struct {
 	*core.Connector
 
-	conn     *websocket.Conn
 	response *http.Response
 
-	opts *Options
+	opts *clientOptions
 }
 
 func NewClientChannel(opts ...*Options) core.ConnectorChannel {
-	c := &client{}
-	c.Connector = core.NewConnector()
 	if len(opts) > 0 {
-		c.opts = opts[0]
-	} else {
-		c.opts = &defaultCliOptions
+		return newClientChannel(&clientOptions{Options: opts[0]})
 	}
+	return newClientChannel(&clientOptions{Options: &defaultCliOptions})
+}
+
+func newClientChannel(opts *clientOptions) core.ConnectorChannel {
+	c := &client{opts: opts}
+	c.Connector = core.NewConnector()
 
 	return c
 }
 
 func (c *client) Connect(addr interface{}) (core.SubChannel, error) {
 	log.Debugf("ws Connect addr: %+v", addr)
-	d := &websocket.Dialer{}
 
 	// TODO: addr
-	conn, response, err := d.Dial(addr.(string), nil)
+	url := addr.(string)
+	conn, response, err := c.dial(url)
 	if err != nil {
 		return nil, err
 	}
-
-	c.conn = conn
 	c.response = response
-	subChannel := core.NewDefaultSubChannel(
-		newRawConn(conn),
-		c.opts.WriteBufSize,
-		c.opts.ReadBufSize,
-		&core.ReconnectOpts{
-			AutoReconnect:     c.opts.AutoReconnect,
-			MaxReconnectTimes: c.opts.MaxReconnectTimes,
-		})
+
+	redial := func() (core.RawConn, error) {
+		conn, _, err := c.dial(url)
+		if err != nil {
+			return nil, err
+		}
+		return newRawConn(conn), nil
+	}
+
+	subChannel := core.NewSubChannel(newRawConn(conn), c.opts.subChannelOpts(&core.ReconnectOpts{
+		AutoReconnect:     c.opts.AutoReconnect,
+		MaxReconnectTimes: c.opts.MaxReconnectTimes,
+		Policy:            c.opts.ReconnectPolicy,
+		Dial:              redial,
+		DisconnectedWrite: c.opts.DisconnectedWrite,
+	}))
 	c.FireConnect(subChannel)
 	return subChannel, nil
 }
 
+func (c *client) dial(url string) (*websocket.Conn, *http.Response, error) {
+	d := &websocket.Dialer{HandshakeTimeout: c.opts.dialTimeout}
+	if c.opts.dialTimeout > 0 || c.opts.localAddr != nil {
+		nd := &net.Dialer{Timeout: c.opts.dialTimeout, LocalAddr: c.opts.localAddr}
+		d.NetDial = nd.Dial
+	}
+
+	return d.Dial(url, nil)
+}
+
 func (c *client) Close() {
-	if c.conn != nil {
-		c.conn.Close()
+	if subChannel := c.SubChannel(); subChannel != nil {
+		subChannel.Close()
 	}
 }
@@ -18,15 +18,12 @@ func newRawConn(conn *websocket.Conn) core.RawConn {
 	return r
 }
 
-func (r *rawConn) SetConn(conn net.Conn) {
-
-}
-
 // Write writes message to opposite side.
-func (r *rawConn) Write(msg []byte) {
-	if r.conn != nil {
-		r.conn.WriteMessage(websocket.BinaryMessage, msg)
+func (r *rawConn) Write(msg []byte) error {
+	if r.conn == nil {
+		return errors.New("ws.rawConn Write() failed for conn is nil")
 	}
+	return r.conn.WriteMessage(websocket.BinaryMessage, msg)
 }
 
 func (r *rawConn) Read(buf bytes.ReadOnlyBuffer) error {
@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
@@ -64,6 +65,12 @@ func (s *server) Close() {
 	s.ln.Close()
 }
 
+// Shutdown stops accepting and closes all SubChannels gracefully.
+func (s *server) Shutdown(ctx context.Context) error {
+	s.Close()
+	return s.Acceptor.Shutdown(ctx)
+}
+
 func (s *server) serve() {
 	handler := http.NewServeMux()
 	upgrader := websocket.Upgrader{
@@ -108,5 +115,5 @@ func (s *server) validate() bool {
 
 func (s *server) processNewConn(conn *websocket.Conn) {
 	log.Debugf("new connection: %+v", conn)
-	s.FireConnect(core.NewDefaultSubChannel(newRawConn(conn), s.opts.ReadBufSize, s.opts.WriteBufSize))
+	s.FireConnect(core.NewSubChannel(newRawConn(conn), s.opts.subChannelOpts(nil)))
 }
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/amsalt/log"
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+)
+
+const (
+	// DefaultMaxCumulation is the default max number of bytes cumulated by ByteToMessageDecoder.
+	DefaultMaxCumulation = 16 * 1024 * 1024
+)
+
+var (
+	ErrCumulationTooLarge = errors.New("ByteToMessageDecoder: cumulation too large")
+)
+
+// Decoder decodes the messages from the bytes cumulated by ByteToMessageDecoder.
+type Decoder interface {
+	// Decode decodes the messages from in and appends them to out, it's called
+	// repeatedly until nothing read from in. Decode should leave in untouched if
+	// no enough bytes for a message, the rest bytes are decoded again when more
+	// bytes received. The bytes read from in are not reused, the messages can
+	// reference them safely.
+	Decode(ctx *core.ChannelContext, in bytes.ReadOnlyBuffer, out *[]interface{}) error
+}
+
+// Resetter is implemented by the Decoder keeps the state between Decode calls, such as
+// the frame being discarded. Reset is called when the cumulation discarded.
+type Resetter interface {
+	Reset()
+}
+
+// ByteToMessageDecoder cumulates the inbound bytes until the Decoder decodes a
+// whole message, which handles the frames split across reads or several frames
+// in one read. The bytes cumulated are discarded when disconnected or reconnected,
+// a frame never continues on the new connection. It's stateful, add a new one per channel.
+// netty-like ByteToMessageDecoder
+type ByteToMessageDecoder struct {
+	*core.DefaultInboundHandler
+
+	decoder       Decoder
+	cumulation    []byte
+	maxCumulation int
+}
+
+// NewByteToMessageDecoder creates a ByteToMessageDecoder decodes messages by decoder.
+func NewByteToMessageDecoder(decoder Decoder) *ByteToMessageDecoder {
+	bd := &ByteToMessageDecoder{decoder: decoder, maxCumulation: DefaultMaxCumulation}
+	bd.DefaultInboundHandler = core.NewDefaultInboundHandler()
+	return bd
+}
+
+// SetMaxCumulation sets the max number of bytes cumulated, the cumulation is discarded
+// with ErrCumulationTooLarge fired if exceeded. 0 means no limit.
+func (bd *ByteToMessageDecoder) SetMaxCumulation(maxCumulation int) *ByteToMessageDecoder {
+	bd.maxCumulation = maxCumulation
+	return bd
+}
+
+// Cumulated returns the number of bytes cumulated and not decoded.
+func (bd *ByteToMessageDecoder) Cumulated() int {
+	return len(bd.cumulation)
+}
+
+// OnDisconnect implements InboundHandler, discards the bytes cumulated.
+func (bd *ByteToMessageDecoder) OnDisconnect(ctx *core.ChannelContext) {
+	bd.reset()
+	ctx.FireDisconnect()
+}
+
+// OnEvent implements InboundHandler, discards the bytes of the lost connection on core.ReconnectedEvent.
+func (bd *ByteToMessageDecoder) OnEvent(ctx *core.ChannelContext, event interface{}) {
+	if _, ok := event.(core.ReconnectedEvent); ok {
+		bd.reset()
+	}
+	ctx.FireEvent(event)
+}
+
+// OnRead implements InboundHandler, all bytes of msg are consumed.
+func (bd *ByteToMessageDecoder) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	data, ok := msg.(bytes.ReadOnlyBuffer)
+	if !ok {
+		ctx.FireError(fmt.Errorf("ByteToMessageDecoder.OnRead msg not bytes.ReadOnlyBuffer"))
+		return
+	}
+
+	// copy the bytes, since the buffer of msg is reused by the channel.
+	bd.cumulation = append(bd.cumulation, data.Bytes()...)
+	data.Discard(data.Len())
+	if bd.maxCumulation > 0 && len(bd.cumulation) > bd.maxCumulation {
+		log.Errorf("ByteToMessageDecoder cumulated %d bytes, exceeds %d", len(bd.cumulation), bd.maxCumulation)
+		bd.reset()
+		ctx.FireError(ErrCumulationTooLarge)
+		return
+	}
+
+	bd.callDecode(ctx)
+}
+
+// callDecode decodes until no more messages, then compacts the cumulation.
+func (bd *ByteToMessageDecoder) callDecode(ctx *core.ChannelContext) {
+	in := bytes.NewReadOnlyBufferWithBytes(bd.cumulation)
+	var out []interface{}
+	for in.Len() > 0 {
+		before := in.Len()
+		err := bd.decoder.Decode(ctx, in, &out)
+		for _, msg := range out {
+			ctx.FireRead(msg)
+		}
+		out = out[:0]
+
+		if err != nil {
+			bd.reset()
+			ctx.FireError(fmt.Errorf("ByteToMessageDecoder decode failed: %w", err))
+			return
+		}
+		if in.Len() == before {
+			break // need more bytes.
+		}
+	}
+
+	// the consumed bytes may be referenced by the messages fired,
+	// so the rest bytes are moved to a new cumulation instead of in place.
+	if rest := in.Len(); rest == 0 {
+		bd.cumulation = nil
+	} else if rest < len(bd.cumulation) {
+		bd.cumulation = append([]byte(nil), in.Bytes()...)
+	}
+}
+
+// reset discards the cumulation and the state of the Decoder.
+func (bd *ByteToMessageDecoder) reset() {
+	bd.cumulation = nil
+	if resetter, ok := bd.decoder.(Resetter); ok {
+		resetter.Reset()
+	}
+}
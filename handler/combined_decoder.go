@@ -24,6 +24,9 @@ func NewCombinedDecoder(messageDeserializer *MessageDeserializer, idParser *IDPa
 	return cd
 }
 
+// Sharable marks CombinedDecoder can be shared by all channels.
+func (cd *CombinedDecoder) Sharable() {}
+
 func (cd *CombinedDecoder) OnRead(ctx *core.ChannelContext, msg interface{}) {
 	log.Debugf("CombinedDecoder.OnRead msg %T", msg)
 	if data, ok := msg.(bytes.ReadOnlyBuffer); ok {
@@ -24,6 +24,9 @@ func NewCombinedEncoder(messageSerializer *MessageSerializer, idParser *IDParser
 	return ce
 }
 
+// Sharable marks CombinedEncoder can be shared by all channels.
+func (ce *CombinedEncoder) Sharable() {}
+
 func (ce *CombinedEncoder) OnWrite(ctx *core.ChannelContext, msg interface{}) {
 	log.Debugf("CombinedEncoder.OnWrite msg: %T", msg)
 	if combined, ok := msg.([]interface{}); ok { // support combined message.
@@ -39,7 +42,7 @@ func (ce *CombinedEncoder) OnWrite(ctx *core.ChannelContext, msg interface{}) {
 			idBuf, _, err := ce.idParser.EncodeID(rawPacket)
 			log.Debugf("encode raw packet id: %+v", idBuf)
 			if err != nil {
-				ctx.FireError(err)
+				ctx.FailWrite(err)
 				return
 			}
 			payload, ok := rawPacket.Payload().([]byte)
@@ -54,7 +57,7 @@ func (ce *CombinedEncoder) OnWrite(ctx *core.ChannelContext, msg interface{}) {
 			extraBuf, err := ce.encode(extra)
 			log.Debugf("encode extraBuf: %+v, err: %+v", extraBuf, err)
 			if err != nil {
-				ctx.FireError(err)
+				ctx.FailWrite(err)
 			} else {
 				// use uint16 as length field.
 				length := make([]byte, ExtraMsgLength)
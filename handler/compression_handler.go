@@ -0,0 +1,188 @@
+package handler
+
+import (
+	stdbytes "bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/amsalt/log"
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+)
+
+const (
+	// DefaultCompressionThreshold is the default min size of the frames compressed.
+	DefaultCompressionThreshold = 1024
+
+	// DefaultMaxInflatedSize is the default max size of a frame decompressed.
+	DefaultMaxInflatedSize = 4 * 1024 * 1024
+)
+
+// The flag prepended to each frame by CompressionHandler.
+const (
+	FlagUncompressed byte = 0
+	FlagCompressed   byte = 1
+)
+
+var (
+	ErrInflatedTooLarge = errors.New("CompressionHandler: inflated frame too large")
+)
+
+// CompressionHandler compresses the outbound frames not smaller than the threshold, and
+// decompresses the inbound frames. A flag byte is prepended to each frame, so that the
+// small frames are sent uncompressed. Both sides should add it between PacketLengthPrepender
+// and MessageEncoder, and between PacketLengthDecoder and MessageDecoder:
+//
+//	+---------------+         +------+-----------------------+
+//	|  frame        |  ---->  | flag | frame or compressed   |
+//	+---------------+         +------+-----------------------+
+//
+//	compression := handler.NewCompressionHandler(handler.NewDeflateCompressor(flate.DefaultCompression))
+//	channel.Pipeline().AddLast(nil, "PacketLengthDecoder", handler.NewPacketLengthDecoder(2))
+//	channel.Pipeline().AddLast(nil, "PacketLengthPrepender", handler.NewPacketLengthPrepender(2))
+//	channel.Pipeline().AddLast(nil, "CompressionHandler", compression)
+//	channel.Pipeline().AddLast(nil, "MessageEncoder", encoder)
+//	channel.Pipeline().AddLast(nil, "MessageDecoder", decoder)
+type CompressionHandler struct {
+	*core.DefaultInboundHandler
+	*core.DefaultOutboundHandler
+
+	compressor      Compressor
+	threshold       int
+	maxInflatedSize int
+}
+
+// NewCompressionHandler creates a CompressionHandler compresses by compressor.
+func NewCompressionHandler(compressor Compressor) *CompressionHandler {
+	if compressor == nil {
+		panic("CompressionHandler needs a Compressor")
+	}
+	return &CompressionHandler{
+		DefaultInboundHandler:  core.NewDefaultInboundHandler(),
+		DefaultOutboundHandler: core.NewDefaultOutboundHandler(),
+		compressor:             compressor,
+		threshold:              DefaultCompressionThreshold,
+		maxInflatedSize:        DefaultMaxInflatedSize,
+	}
+}
+
+// Sharable implements core.Sharable, each frame is compressed independently.
+func (ch *CompressionHandler) Sharable() {}
+
+// SetThreshold sets the min size of the frames compressed, the smaller ones are sent uncompressed.
+func (ch *CompressionHandler) SetThreshold(threshold int) *CompressionHandler {
+	ch.threshold = threshold
+	return ch
+}
+
+// SetMaxInflatedSize sets the max size of a frame decompressed, the frame exceeds
+// is discarded with ErrInflatedTooLarge fired, which guards the decompression bombs.
+func (ch *CompressionHandler) SetMaxInflatedSize(maxInflatedSize int) *CompressionHandler {
+	ch.maxInflatedSize = maxInflatedSize
+	return ch
+}
+
+// OnRead implements InboundHandler, msg should be the bytes.ReadOnlyBuffer of a whole frame.
+func (ch *CompressionHandler) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	buff, ok := msg.(bytes.ReadOnlyBuffer)
+	if !ok {
+		ctx.FireError(fmt.Errorf("CompressionHandler.OnRead msg not bytes.ReadOnlyBuffer"))
+		return
+	}
+
+	flag, err := buff.Read(0, 1)
+	if err != nil {
+		ctx.FireError(fmt.Errorf("CompressionHandler.OnRead empty frame"))
+		return
+	}
+	switch flag[0] {
+	case FlagUncompressed:
+		ctx.FireRead(buff)
+	case FlagCompressed:
+		data, err := ch.decompress(buff.Bytes())
+		buff.Discard(buff.Len())
+		if err != nil {
+			log.Errorf("CompressionHandler.OnRead failed: %+v", err)
+			ctx.FireError(err)
+			return
+		}
+		ctx.FireRead(bytes.NewReadOnlyBufferWithBytes(data))
+	default:
+		ctx.FireError(fmt.Errorf("CompressionHandler.OnRead unknown flag: %d", flag[0]))
+	}
+}
+
+// OnWrite implements OutboundHandler, msg should be []byte or bytes.WriteOnlyBuffer.
+func (ch *CompressionHandler) OnWrite(ctx *core.ChannelContext, msg interface{}) {
+	var data []byte
+	switch m := msg.(type) {
+	case []byte:
+		data = m
+	case bytes.WriteOnlyBuffer:
+		data = m.Bytes()
+	default:
+		ctx.FailWrite(errors.New("CompressionHandler msg should be []byte or bytes.WriteOnlyBuffer type"))
+		return
+	}
+
+	if len(data) >= ch.threshold {
+		compressed, err := ch.compress(data)
+		if err != nil {
+			log.Errorf("CompressionHandler.OnWrite failed: %+v", err)
+			ctx.FailWrite(err)
+			return
+		}
+		// send uncompressed if not smaller.
+		if len(compressed) < len(data) {
+			buff := bytes.NewWriteOnlyBufferWithBytes(MaxPacketLen+1, compressed)
+			buff.WriteHeader([]byte{FlagCompressed})
+			ctx.FireWrite(buff)
+			return
+		}
+	}
+
+	buff, ok := msg.(bytes.WriteOnlyBuffer)
+	if !ok {
+		buff = bytes.NewWriteOnlyBufferWithBytes(MaxPacketLen+1, data)
+	}
+	if _, err := buff.WriteHeader([]byte{FlagUncompressed}); err == bytes.ErrNoEnoughHeader {
+		buff = bytes.NewWriteOnlyBufferWithBytes(MaxPacketLen+1, data)
+		buff.WriteHeader([]byte{FlagUncompressed})
+	}
+	ctx.FireWrite(buff)
+}
+
+func (ch *CompressionHandler) compress(data []byte) ([]byte, error) {
+	var out stdbytes.Buffer
+	w, err := ch.compressor.NewWriter(&out)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (ch *CompressionHandler) decompress(data []byte) ([]byte, error) {
+	r, err := ch.compressor.NewReader(stdbytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// read one more byte to know whether exceeds.
+	var out stdbytes.Buffer
+	if _, err = out.ReadFrom(io.LimitReader(r, int64(ch.maxInflatedSize)+1)); err != nil {
+		return nil, err
+	}
+	if out.Len() > ch.maxInflatedSize {
+		return nil, fmt.Errorf("%w: exceeds %v", ErrInflatedTooLarge, ch.maxInflatedSize)
+	}
+	return out.Bytes(), nil
+}
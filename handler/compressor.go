@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Compressor creates the compressing writers and decompressing readers of an algorithm,
+// implement it to plug in other algorithms to CompressionHandler, e.g. snappy:
+//
+//	type SnappyCompressor struct{}
+//
+//	func (SnappyCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+//		return snappy.NewBufferedWriter(w), nil
+//	}
+//
+//	func (SnappyCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+//		return ioutil.NopCloser(snappy.NewReader(r)), nil
+//	}
+//
+// The methods are called concurrently by the channels shared the CompressionHandler.
+type Compressor interface {
+	// NewWriter returns a writer compresses the data to w, the compressed data is
+	// flushed to w when the writer closed.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+
+	// NewReader returns a reader decompresses the data from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// DeflateCompressor compresses with the raw deflate format of RFC 1951.
+type DeflateCompressor struct {
+	level   int
+	writers sync.Pool
+}
+
+// NewDeflateCompressor creates a DeflateCompressor, level is one of the compress/flate levels.
+func NewDeflateCompressor(level int) *DeflateCompressor {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		panic(fmt.Errorf("invalid deflate compression level: %d", level))
+	}
+	return &DeflateCompressor{level: level}
+}
+
+// NewWriter implements Compressor, the writers are reused since they are expensive to create.
+func (dc *DeflateCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	if fw, ok := dc.writers.Get().(*flate.Writer); ok {
+		fw.Reset(w)
+		return &pooledWriter{fw, &dc.writers}, nil
+	}
+	fw, err := flate.NewWriter(w, dc.level)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledWriter{fw, &dc.writers}, nil
+}
+
+// NewReader implements Compressor.
+func (dc *DeflateCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
+
+// GzipCompressor compresses with the gzip format of RFC 1952, which is deflate with
+// a header and a CRC-32 checksum.
+type GzipCompressor struct {
+	level   int
+	writers sync.Pool
+}
+
+// NewGzipCompressor creates a GzipCompressor, level is one of the compress/gzip levels.
+func NewGzipCompressor(level int) *GzipCompressor {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		panic(fmt.Errorf("invalid gzip compression level: %d", level))
+	}
+	return &GzipCompressor{level: level}
+}
+
+// NewWriter implements Compressor.
+func (gc *GzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	if gw, ok := gc.writers.Get().(*gzip.Writer); ok {
+		gw.Reset(w)
+		return &pooledWriter{gw, &gc.writers}, nil
+	}
+	gw, err := gzip.NewWriterLevel(w, gc.level)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledWriter{gw, &gc.writers}, nil
+}
+
+// NewReader implements Compressor.
+func (gc *GzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// resetWriteCloser is the writer can be reused by Reset, such as *flate.Writer and *gzip.Writer.
+type resetWriteCloser interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// pooledWriter puts the writer back to the pool when closed.
+type pooledWriter struct {
+	resetWriteCloser
+	pool *sync.Pool
+}
+
+func (pw *pooledWriter) Close() error {
+	err := pw.resetWriteCloser.Close()
+	pw.resetWriteCloser.Reset(nil)
+	pw.pool.Put(pw.resetWriteCloser)
+	return err
+}
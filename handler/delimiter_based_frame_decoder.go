@@ -0,0 +1,153 @@
+package handler
+
+import (
+	stdbytes "bytes"
+	"fmt"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+)
+
+// LineDelimiters returns the delimiters of text lines, "\r\n" and "\n".
+func LineDelimiters() [][]byte {
+	return [][]byte{[]byte("\r\n"), []byte("\n")}
+}
+
+// NulDelimiter returns the NUL delimiter, used by Flash XML socket or similar protocols.
+func NulDelimiter() [][]byte {
+	return [][]byte{{0}}
+}
+
+// DelimiterBasedFrameDecoder splits the inbound bytes by one or more delimiters.
+// If several delimiters found, the one produces the shortest frame is used.
+// for example, split by LineDelimiters():
+//
+//	+--------------+         +------+------+
+//	| ABC\nDEF\r\n |  ---->  | ABC  | DEF  |
+//	+--------------+         +------+------+
+//
+// The frames are fired as bytes.ReadOnlyBuffer. The frame exceeds maxFrameLength
+// is discarded with ErrFrameTooLong fired, and the following frames are decoded normally.
+// netty-like DelimiterBasedFrameDecoder
+type DelimiterBasedFrameDecoder struct {
+	*ByteToMessageDecoder
+
+	delimiters     [][]byte
+	maxFrameLength int
+	stripDelimiter bool
+	failFast       bool
+
+	// the state of discarding a too long frame.
+	discarding         bool
+	tooLongFrameLength int
+}
+
+// NewDelimiterBasedFrameDecoder creates a DelimiterBasedFrameDecoder splits by delimiters,
+// the delimiters are stripped from the frames by default.
+func NewDelimiterBasedFrameDecoder(maxFrameLength int, delimiters ...[]byte) *DelimiterBasedFrameDecoder {
+	if maxFrameLength <= 0 {
+		panic(fmt.Sprintf("DelimiterBasedFrameDecoder invalid maxFrameLength: %d", maxFrameLength))
+	}
+	if len(delimiters) == 0 {
+		panic("DelimiterBasedFrameDecoder no delimiter")
+	}
+	for _, delimiter := range delimiters {
+		if len(delimiter) == 0 {
+			panic("DelimiterBasedFrameDecoder empty delimiter")
+		}
+	}
+
+	dd := &DelimiterBasedFrameDecoder{
+		delimiters:     delimiters,
+		maxFrameLength: maxFrameLength,
+		stripDelimiter: true,
+		failFast:       true,
+	}
+	dd.ByteToMessageDecoder = NewByteToMessageDecoder(dd)
+	return dd
+}
+
+// SetStripDelimiter sets whether the delimiter is stripped from the frame, default is true.
+func (dd *DelimiterBasedFrameDecoder) SetStripDelimiter(stripDelimiter bool) *DelimiterBasedFrameDecoder {
+	dd.stripDelimiter = stripDelimiter
+	return dd
+}
+
+// SetFailFast sets when ErrFrameTooLong fired, as soon as the frame exceeds maxFrameLength
+// if failFast(default), otherwise after the whole frame discarded.
+func (dd *DelimiterBasedFrameDecoder) SetFailFast(failFast bool) *DelimiterBasedFrameDecoder {
+	dd.failFast = failFast
+	return dd
+}
+
+// Reset implements Resetter, stops discarding the too long frame.
+func (dd *DelimiterBasedFrameDecoder) Reset() {
+	dd.discarding = false
+	dd.tooLongFrameLength = 0
+}
+
+// Decode implements Decoder, decodes one frame if a delimiter found.
+func (dd *DelimiterBasedFrameDecoder) Decode(ctx *core.ChannelContext, in bytes.ReadOnlyBuffer, out *[]interface{}) error {
+	buf := in.Bytes()
+	index, delimiter := dd.indexOf(buf)
+	if index < 0 {
+		if dd.discarding {
+			dd.tooLongFrameLength += len(buf)
+			in.Discard(len(buf))
+		} else if len(buf) > dd.maxFrameLength {
+			dd.discarding = true
+			dd.tooLongFrameLength = len(buf)
+			in.Discard(len(buf))
+			if dd.failFast {
+				dd.fail(ctx, dd.tooLongFrameLength)
+			}
+		}
+		return nil
+	}
+
+	frameLength := index + len(delimiter)
+	if dd.discarding {
+		// the end of the too long frame.
+		dd.discarding = false
+		in.Discard(frameLength)
+		if !dd.failFast {
+			dd.fail(ctx, dd.tooLongFrameLength+index)
+		}
+		return nil
+	}
+	if index > dd.maxFrameLength {
+		in.Discard(frameLength)
+		dd.fail(ctx, index)
+		return nil
+	}
+
+	if dd.stripDelimiter {
+		frameLength = index
+	}
+	frame, err := in.Read(0, frameLength)
+	if err != nil {
+		return err
+	}
+	if dd.stripDelimiter {
+		in.Discard(len(delimiter))
+	}
+	*out = append(*out, bytes.NewReadOnlyBufferWithBytes(frame))
+	return nil
+}
+
+// indexOf returns the index of the delimiter produces the shortest frame, -1 if not found.
+func (dd *DelimiterBasedFrameDecoder) indexOf(buf []byte) (int, []byte) {
+	index := -1
+	var found []byte
+	for _, delimiter := range dd.delimiters {
+		if i := stdbytes.Index(buf, delimiter); i >= 0 && (index < 0 || i < index) {
+			index = i
+			found = delimiter
+		}
+	}
+	return index, found
+}
+
+func (dd *DelimiterBasedFrameDecoder) fail(ctx *core.ChannelContext, frameLength int) {
+	ctx.FireError(fmt.Errorf("%w: %v exceeds %v", ErrFrameTooLong, frameLength, dd.maxFrameLength))
+}
@@ -0,0 +1,518 @@
+package handler
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/amsalt/log"
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// AEADSuite represents the AEAD algorithm of AEADCipher.
+type AEADSuite byte
+
+const (
+	// AES256GCM is AES-256 in Galois/Counter Mode, the fastest on CPUs with AES instructions.
+	AES256GCM AEADSuite = 1
+
+	// ChaCha20Poly1305 is the ChaCha20-Poly1305 of RFC 8439, fast on CPUs without AES instructions.
+	ChaCha20Poly1305 AEADSuite = 2
+)
+
+const (
+	// DefaultRekeyInterval is the default number of frames sent with a key before rotated.
+	DefaultRekeyInterval = 1 << 20
+
+	// DefaultHandshakeTimeout is the default time waiting for the handshake of the opposite side.
+	DefaultHandshakeTimeout = 10 * time.Second
+)
+
+const (
+	aeadVersion byte = 1
+
+	// the first byte of frames.
+	aeadFrameHandshake byte = 1
+	aeadFrameData      byte = 2
+
+	// handshake frame: type | version | suite | X25519 public key
+	aeadHandshakeLen = 3 + curve25519.PointSize
+	// data frame: type | epoch(4) | sequence(8) | ciphertext, the epoch and sequence are the nonce.
+	aeadDataHeaderLen = 13
+)
+
+var (
+	ErrAEADHandshake        = errors.New("AEADCipher: handshake failed")
+	ErrAEADHandshakeTimeout = errors.New("AEADCipher: handshake timeout")
+	ErrAEADNotReady         = errors.New("AEADCipher: data received before handshake")
+	ErrAEADReplay           = errors.New("AEADCipher: replayed or reordered frame")
+	ErrAEADAuthentication   = errors.New("AEADCipher: message authentication failed")
+)
+
+// HandshakeCompleteEvent is fired by AEADCipher after the keys agreed with the opposite side,
+// before any data read.
+type HandshakeCompleteEvent struct {
+	Suite AEADSuite
+}
+
+// AEADCipher encrypts and authenticates the frames with AES-256-GCM or ChaCha20-Poly1305.
+// The keys are agreed per connection by the X25519 handshake sent at OnConnect, and derived
+// by HKDF-SHA256 separately for each direction. Each frame carries the epoch and sequence
+// used as the nonce, the frames replayed or reordered are rejected, and the key is rotated
+// every rekeyInterval frames. Since the frame length is not encrypted, add it between
+// PacketLengthPrepender and MessageEncoder, and between PacketLengthDecoder and MessageDecoder:
+//
+//	channel.Pipeline().AddLast(nil, "PacketLengthDecoder", handler.NewPacketLengthDecoder(2))
+//	channel.Pipeline().AddLast(nil, "PacketLengthPrepender", handler.NewPacketLengthPrepender(2))
+//	channel.Pipeline().AddLast(nil, "AEADCipher", handler.NewAEADCipher(handler.AES256GCM))
+//	channel.Pipeline().AddLast(nil, "MessageEncoder", encoder)
+//	channel.Pipeline().AddLast(nil, "MessageDecoder", decoder)
+//
+// The messages written before the handshake complete are queued with their promises, and
+// sent in order after it. The handshake itself doesn't authenticate the opposite side, set
+// the same pre-shared key on both sides with SetPreSharedKey to defeat the man in the middle,
+// or authenticate at the application layer.
+//
+// With auto-reconnect, the keys are dropped and a new handshake is sent on core.ReconnectedEvent.
+// Set ManualReplay of core.DisconnectedWriteOpts and call Replay on HandshakeCompleteEvent,
+// so that the messages buffered while reconnecting wait for the new keys.
+// It's stateful, add a new one per channel.
+type AEADCipher struct {
+	*core.DefaultInboundHandler
+	*core.DefaultOutboundHandler
+
+	suite            AEADSuite
+	rekeyInterval    uint64
+	handshakeTimeout time.Duration
+	preSharedKey     []byte
+
+	// mutex guards the handshake state and the messages pending.
+	mutex      sync.Mutex
+	privateKey []byte
+	publicKey  []byte
+	send       *aeadKey
+	recv       *aeadKey
+	ready      bool // the handshake completed and no message pending.
+	closed     bool
+	pending    []aeadPendingWrite
+	timer      *time.Timer
+	generation int // increased by each handshake, expires the timer of the previous.
+}
+
+// aeadPendingWrite is a message written before the handshake completed,
+// ctx is the one passed to OnWrite which carries the promise.
+type aeadPendingWrite struct {
+	ctx  *core.ChannelContext
+	data []byte
+}
+
+// aeadKey is the key of a direction and the state of the nonce.
+type aeadKey struct {
+	suite AEADSuite
+	key   []byte
+	aead  cipher.AEAD
+	epoch uint32
+	seq   uint64 // the next sequence to send, or the min sequence expected.
+}
+
+// NewAEADCipher creates an AEADCipher encrypts with suite, the opposite side should use the same.
+func NewAEADCipher(suite AEADSuite) *AEADCipher {
+	if suite != AES256GCM && suite != ChaCha20Poly1305 {
+		panic(fmt.Errorf("unknown AEAD suite: %d", suite))
+	}
+
+	ac := &AEADCipher{
+		DefaultInboundHandler:  core.NewDefaultInboundHandler(),
+		DefaultOutboundHandler: core.NewDefaultOutboundHandler(),
+		suite:                  suite,
+		rekeyInterval:          DefaultRekeyInterval,
+		handshakeTimeout:       DefaultHandshakeTimeout,
+	}
+	if err := ac.generateKey(); err != nil {
+		panic(err)
+	}
+	return ac
+}
+
+// SetRekeyInterval sets the number of frames sent with a key before rotated, 0 means never.
+func (ac *AEADCipher) SetRekeyInterval(rekeyInterval uint64) *AEADCipher {
+	ac.rekeyInterval = rekeyInterval
+	return ac
+}
+
+// SetHandshakeTimeout sets the time waiting for the handshake of the opposite side,
+// the channel is closed if not received in time. 0 means no timeout.
+func (ac *AEADCipher) SetHandshakeTimeout(handshakeTimeout time.Duration) *AEADCipher {
+	ac.handshakeTimeout = handshakeTimeout
+	return ac
+}
+
+// SetPreSharedKey sets the key mixed into the key derivation, the frames from the opposite
+// side without the same key fail the authentication.
+func (ac *AEADCipher) SetPreSharedKey(preSharedKey []byte) *AEADCipher {
+	ac.preSharedKey = preSharedKey
+	return ac
+}
+
+// OnConnect implements InboundHandler, sends the handshake.
+func (ac *AEADCipher) OnConnect(ctx *core.ChannelContext, channel core.Channel) {
+	ac.startHandshake(ctx)
+	ctx.FireConnect(channel)
+}
+
+// OnDisconnect implements InboundHandler, the messages waiting for the handshake are dropped.
+func (ac *AEADCipher) OnDisconnect(ctx *core.ChannelContext) {
+	ac.stop()
+	ctx.FireDisconnect()
+}
+
+// OnEvent implements InboundHandler, the handshake is sent again with a new key on
+// core.ReconnectedEvent, the messages written meanwhile wait for it.
+func (ac *AEADCipher) OnEvent(ctx *core.ChannelContext, event interface{}) {
+	if _, ok := event.(core.ReconnectedEvent); ok {
+		ac.mutex.Lock()
+		err := ac.generateKey()
+		ac.send, ac.recv, ac.ready = nil, nil, false
+		ac.mutex.Unlock()
+		if err != nil {
+			ac.fail(ctx, err)
+			return
+		}
+		ac.startHandshake(ctx)
+	}
+	ctx.FireEvent(event)
+}
+
+// OnRead implements InboundHandler, msg should be the bytes.ReadOnlyBuffer of a whole frame.
+func (ac *AEADCipher) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	buff, ok := msg.(bytes.ReadOnlyBuffer)
+	if !ok {
+		ctx.FireError(fmt.Errorf("AEADCipher.OnRead msg not bytes.ReadOnlyBuffer"))
+		return
+	}
+
+	frame := buff.Bytes()
+	buff.Discard(buff.Len())
+	if len(frame) == 0 {
+		ac.fail(ctx, fmt.Errorf("AEADCipher.OnRead empty frame"))
+		return
+	}
+
+	switch frame[0] {
+	case aeadFrameHandshake:
+		if err := ac.handshake(frame); err != nil {
+			ac.fail(ctx, err)
+			return
+		}
+		ac.flush()
+		ctx.FireEvent(HandshakeCompleteEvent{Suite: ac.suite})
+
+	case aeadFrameData:
+		// recv is only replaced by the handshake read before.
+		ac.mutex.Lock()
+		recv := ac.recv
+		ac.mutex.Unlock()
+		if recv == nil {
+			ac.fail(ctx, ErrAEADNotReady)
+			return
+		}
+		plaintext, err := recv.open(frame)
+		if err != nil {
+			ac.fail(ctx, err)
+			return
+		}
+		ctx.FireRead(bytes.NewReadOnlyBufferWithBytes(plaintext))
+
+	default:
+		ac.fail(ctx, fmt.Errorf("AEADCipher.OnRead unknown frame type: %d", frame[0]))
+	}
+}
+
+// OnWrite implements OutboundHandler, msg should be []byte or bytes.WriteOnlyBuffer.
+// The message is queued if the handshake not completed.
+func (ac *AEADCipher) OnWrite(ctx *core.ChannelContext, msg interface{}) {
+	var data []byte
+	switch m := msg.(type) {
+	case []byte:
+		data = m
+	case bytes.WriteOnlyBuffer:
+		data = m.Bytes()
+	default:
+		ctx.FailWrite(errors.New("AEADCipher msg should be []byte or bytes.WriteOnlyBuffer type"))
+		return
+	}
+
+	ac.mutex.Lock()
+	if ac.closed {
+		ac.mutex.Unlock()
+		if promise := ctx.WritePromise(); promise != nil {
+			promise.Complete(ErrAEADNotReady)
+		}
+		return
+	}
+	if !ac.ready {
+		ac.pending = append(ac.pending, aeadPendingWrite{ctx: ctx, data: data})
+		ac.mutex.Unlock()
+		return
+	}
+	frame, err := ac.send.seal(data, ac.rekeyInterval)
+	ac.mutex.Unlock()
+
+	if err != nil {
+		log.Errorf("AEADCipher.OnWrite failed: %+v", err)
+		ctx.FailWrite(err)
+		return
+	}
+	ctx.FireWrite(bytes.NewWriteOnlyBufferWithBytes(MaxPacketLen, frame))
+}
+
+// generateKey generates the X25519 key pair of a handshake.
+func (ac *AEADCipher) generateKey() error {
+	privateKey := make([]byte, curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand.Reader, privateKey); err != nil {
+		return fmt.Errorf("generate X25519 private key failed for %+v", err)
+	}
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	if err != nil {
+		return fmt.Errorf("generate X25519 public key failed for %+v", err)
+	}
+	ac.privateKey, ac.publicKey = privateKey, publicKey
+	return nil
+}
+
+// startHandshake starts the timer and sends the X25519 public key.
+func (ac *AEADCipher) startHandshake(ctx *core.ChannelContext) {
+	ac.mutex.Lock()
+	ac.generation++
+	if ac.timer != nil {
+		ac.timer.Stop()
+	}
+	if ac.handshakeTimeout > 0 {
+		generation := ac.generation
+		ac.timer = time.AfterFunc(ac.handshakeTimeout, func() {
+			ac.mutex.Lock()
+			expired := generation == ac.generation && ac.recv == nil
+			ac.mutex.Unlock()
+			if expired {
+				ac.fail(ctx, ErrAEADHandshakeTimeout)
+			}
+		})
+	}
+	frame := make([]byte, 0, aeadHandshakeLen)
+	frame = append(frame, aeadFrameHandshake, aeadVersion, byte(ac.suite))
+	frame = append(frame, ac.publicKey...)
+	ac.mutex.Unlock()
+
+	ctx.FireWrite(bytes.NewWriteOnlyBufferWithBytes(MaxPacketLen, frame))
+}
+
+// handshake agrees the keys with the handshake of the opposite side.
+func (ac *AEADCipher) handshake(frame []byte) error {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	if ac.recv != nil {
+		return fmt.Errorf("%w: handshake received twice", ErrAEADHandshake)
+	}
+	if len(frame) != aeadHandshakeLen || frame[1] != aeadVersion {
+		return fmt.Errorf("%w: malformed handshake", ErrAEADHandshake)
+	}
+	if AEADSuite(frame[2]) != ac.suite {
+		return fmt.Errorf("%w: suite %d mismatched, want %d", ErrAEADHandshake, frame[2], ac.suite)
+	}
+
+	peerKey := frame[3:]
+	// rejects the low order points.
+	shared, err := curve25519.X25519(ac.privateKey, peerKey)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAEADHandshake, err)
+	}
+
+	if ac.send, err = ac.deriveKey(shared, ac.publicKey, peerKey); err != nil {
+		return err
+	}
+	if ac.recv, err = ac.deriveKey(shared, peerKey, ac.publicKey); err != nil {
+		return err
+	}
+
+	if ac.timer != nil {
+		ac.timer.Stop()
+	}
+	return nil
+}
+
+// flush sends the messages queued before the handshake completed. The messages written
+// meanwhile are queued as well, so that the frames are sent in the order of sequence.
+func (ac *AEADCipher) flush() {
+	for {
+		ac.mutex.Lock()
+		if ac.closed || ac.send == nil {
+			ac.mutex.Unlock()
+			return
+		}
+		pending := ac.pending
+		ac.pending = nil
+		if len(pending) == 0 {
+			ac.ready = true
+			ac.mutex.Unlock()
+			return
+		}
+		frames := make([][]byte, len(pending))
+		errs := make([]error, len(pending))
+		for i, pw := range pending {
+			frames[i], errs[i] = ac.send.seal(pw.data, ac.rekeyInterval)
+		}
+		ac.mutex.Unlock()
+
+		for i, pw := range pending {
+			if errs[i] != nil {
+				log.Errorf("AEADCipher.flush failed: %+v", errs[i])
+				pw.ctx.FailWrite(errs[i])
+				continue
+			}
+			pw.ctx.FireWrite(bytes.NewWriteOnlyBufferWithBytes(MaxPacketLen, frames[i]))
+		}
+	}
+}
+
+// deriveKey derives the key of the direction from the sender to the receiver.
+func (ac *AEADCipher) deriveKey(shared, sender, receiver []byte) (*aeadKey, error) {
+	info := make([]byte, 0, 16+2*curve25519.PointSize)
+	info = append(info, "nginet aead"...)
+	info = append(info, aeadVersion, byte(ac.suite))
+	info = append(info, sender...)
+	info = append(info, receiver...)
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, ac.preSharedKey, info), key); err != nil {
+		return nil, err
+	}
+	return newAEADKey(ac.suite, key)
+}
+
+// fail fires the error and closes the channel, the messages waiting for the handshake are dropped.
+func (ac *AEADCipher) fail(ctx *core.ChannelContext, err error) {
+	log.Errorf("AEADCipher failed: %+v", err)
+	ac.stop()
+	ctx.FireError(err)
+	ctx.Close()
+}
+
+// stop fails the messages pending, the messages written later are dropped.
+func (ac *AEADCipher) stop() {
+	ac.mutex.Lock()
+	if ac.closed {
+		ac.mutex.Unlock()
+		return
+	}
+	ac.closed = true
+	if ac.timer != nil {
+		ac.timer.Stop()
+	}
+	pending := ac.pending
+	ac.pending = nil
+	ac.mutex.Unlock()
+
+	for _, pw := range pending {
+		if promise := pw.ctx.WritePromise(); promise != nil {
+			promise.Complete(ErrAEADNotReady)
+		}
+	}
+}
+
+func newAEADKey(suite AEADSuite, key []byte) (*aeadKey, error) {
+	var aead cipher.AEAD
+	var err error
+	switch suite {
+	case AES256GCM:
+		var block cipher.Block
+		if block, err = aes.NewCipher(key); err == nil {
+			aead, err = cipher.NewGCM(block)
+		}
+	case ChaCha20Poly1305:
+		aead, err = chacha20poly1305.New(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &aeadKey{suite: suite, key: key, aead: aead}, nil
+}
+
+// next derives the key of the next epoch, the old key can't be derived from it.
+func (k *aeadKey) next() (*aeadKey, error) {
+	key := make([]byte, len(k.key))
+	if _, err := io.ReadFull(hkdf.New(sha256.New, k.key, nil, []byte("nginet aead rekey")), key); err != nil {
+		return nil, err
+	}
+	nk, err := newAEADKey(k.suite, key)
+	if err != nil {
+		return nil, err
+	}
+	nk.epoch = k.epoch + 1
+	return nk, nil
+}
+
+// seal encrypts data to a data frame, and rotates the key every rekeyInterval frames.
+func (k *aeadKey) seal(data []byte, rekeyInterval uint64) ([]byte, error) {
+	if rekeyInterval > 0 && k.seq >= rekeyInterval {
+		nk, err := k.next()
+		if err != nil {
+			return nil, err
+		}
+		*k = *nk
+	}
+
+	frame := make([]byte, aeadDataHeaderLen, aeadDataHeaderLen+len(data)+k.aead.Overhead())
+	frame[0] = aeadFrameData
+	binary.BigEndian.PutUint32(frame[1:5], k.epoch)
+	binary.BigEndian.PutUint64(frame[5:13], k.seq)
+	k.seq++
+
+	// the header is authenticated as the additional data.
+	return k.aead.Seal(frame, frame[1:aeadDataHeaderLen], data, frame[:aeadDataHeaderLen]), nil
+}
+
+// open decrypts a data frame, the frame of an old epoch or sequence is rejected.
+func (k *aeadKey) open(frame []byte) ([]byte, error) {
+	if len(frame) < aeadDataHeaderLen+k.aead.Overhead() {
+		return nil, fmt.Errorf("%w: frame too short", ErrAEADAuthentication)
+	}
+	epoch := binary.BigEndian.Uint32(frame[1:5])
+	seq := binary.BigEndian.Uint64(frame[5:13])
+
+	key := k
+	switch {
+	case epoch == k.epoch && seq >= k.seq:
+	case epoch == k.epoch+1:
+		// the key is committed after the frame authenticated.
+		nk, err := k.next()
+		if err != nil {
+			return nil, err
+		}
+		key = nk
+	default:
+		return nil, fmt.Errorf("%w: epoch %d sequence %d, want epoch %d sequence %d at least",
+			ErrAEADReplay, epoch, seq, k.epoch, k.seq)
+	}
+
+	plaintext, err := key.aead.Open(nil, frame[1:aeadDataHeaderLen], frame[aeadDataHeaderLen:], frame[:aeadDataHeaderLen])
+	if err != nil {
+		return nil, ErrAEADAuthentication
+	}
+	if key != k {
+		*k = *key
+	}
+	k.seq = seq + 1
+	return plaintext, nil
+}
@@ -9,6 +9,9 @@ import (
 	"github.com/amsalt/nginet/core"
 )
 
+// Rc4Cipher encrypts the bytes with RC4 keyed by the MD5 of a static password.
+//
+// Deprecated: RC4 is broken and has no integrity check, use AEADCipher instead.
 type Rc4Cipher struct {
 	*core.DefaultInboundHandler
 	*core.DefaultOutboundHandler
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HTTPRequest represents a HTTP/1.x request decoded by HTTPRequestDecoder.
+// It implements message.Packet with the path as ID, so that DefaultMessageHandler
+// dispatches it to the processor registered by path, for example:
+//
+//	processMgr.RegisterProcessorByID("/health", func(ctx *core.ChannelContext, msg interface{}, args ...interface{}) {
+//		req := msg.(*handler.HTTPRequest)
+//		ctx.Write(req.NewResponse(http.StatusOK, []byte("ok")))
+//	})
+type HTTPRequest struct {
+	Method     string
+	RequestURI string
+	URL        *url.URL
+	Proto      string // "HTTP/1.1" or "HTTP/1.0"
+	Header     http.Header
+	Body       []byte
+
+	// Trailer holds the trailer headers of the chunked body.
+	Trailer http.Header
+}
+
+// ID implements message.Packet, returns the path of the request.
+func (r *HTTPRequest) ID() interface{} {
+	return r.URL.Path
+}
+
+// Payload implements message.Packet, returns the request itself.
+func (r *HTTPRequest) Payload() interface{} {
+	return r
+}
+
+// KeepAlive reports whether the connection should be kept after responded.
+// HTTP/1.1 keeps alive unless "Connection: close", HTTP/1.0 closes unless "Connection: keep-alive".
+func (r *HTTPRequest) KeepAlive() bool {
+	connection := strings.ToLower(r.Header.Get("Connection"))
+	if r.Proto == "HTTP/1.0" {
+		return connection == "keep-alive"
+	}
+	return connection != "close"
+}
+
+// NewResponse creates a response of the request, keeps alive as the request.
+func (r *HTTPRequest) NewResponse(statusCode int, body []byte) *HTTPResponse {
+	resp := NewHTTPResponse(statusCode, body)
+	if !r.KeepAlive() {
+		resp.Close = true
+	} else if r.Proto == "HTTP/1.0" {
+		resp.Header.Set("Connection", "keep-alive")
+	}
+	if r.Method == http.MethodHead {
+		resp.noBody = true
+	}
+	return resp
+}
+
+// HTTPResponse represents a HTTP/1.1 response encoded by HTTPResponseEncoder.
+type HTTPResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	// Chunked sends the body with chunked transfer encoding instead of Content-Length.
+	Chunked bool
+
+	// Close closes the connection after the response sent.
+	Close bool
+
+	// noBody is set for the response of HEAD request.
+	noBody bool
+}
+
+// NewHTTPResponse creates a new HTTPResponse.
+func NewHTTPResponse(statusCode int, body []byte) *HTTPResponse {
+	return &HTTPResponse{StatusCode: statusCode, Header: make(http.Header), Body: body}
+}
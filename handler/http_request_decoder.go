@@ -0,0 +1,334 @@
+package handler
+
+import (
+	"bufio"
+	stdbytes "bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+)
+
+const (
+	// DefaultHTTPMaxHeaderSize is the default max size of the request line and headers.
+	DefaultHTTPMaxHeaderSize = 8 * 1024
+
+	// DefaultHTTPMaxContentLength is the default max size of the request body.
+	DefaultHTTPMaxContentLength = 1024 * 1024
+
+	// maxChunkSizeLine is the max size of the chunk size line, include the chunk extensions.
+	maxChunkSizeLine = 1024
+)
+
+var (
+	ErrHTTPHeaderTooLarge  = errors.New("http: request header too large")
+	ErrHTTPContentTooLarge = errors.New("http: request content too large")
+	ErrHTTPBadChunk        = errors.New("http: malformed chunked encoding")
+)
+
+var (
+	crlf       = []byte("\r\n")
+	headersEnd = []byte("\r\n\r\n")
+)
+
+type httpDecodeState int
+
+const (
+	httpReadHeader httpDecodeState = iota
+	httpReadContent
+	httpReadChunkSize
+	httpReadChunkData
+	httpReadTrailer
+)
+
+// HTTPRequestDecoder decodes the HTTP/1.x requests to *HTTPRequest, the body is read
+// by Content-Length or chunked transfer encoding. Multiple requests can be sent on a
+// keep-alive connection. A bad request is responded with the error status and the
+// connection is closed after, so HTTPResponseEncoder should be added too:
+//
+//	s.InitSubChannel(func(channel core.SubChannel) {
+//		channel.Pipeline().AddLast(nil, "HTTPResponseEncoder", handler.NewHTTPResponseEncoder())
+//		channel.Pipeline().AddLast(nil, "HTTPRequestDecoder", handler.NewHTTPRequestDecoder())
+//		channel.Pipeline().AddLast(nil, "processor", handler.NewDefaultMessageHandler(processMgr))
+//	})
+type HTTPRequestDecoder struct {
+	*ByteToMessageDecoder
+
+	maxHeaderSize    int
+	maxContentLength int
+
+	state     httpDecodeState
+	req       *HTTPRequest
+	remaining int // the bytes remaining of the content or current chunk.
+}
+
+// NewHTTPRequestDecoder creates a HTTPRequestDecoder.
+func NewHTTPRequestDecoder() *HTTPRequestDecoder {
+	hd := &HTTPRequestDecoder{
+		maxHeaderSize:    DefaultHTTPMaxHeaderSize,
+		maxContentLength: DefaultHTTPMaxContentLength,
+	}
+	hd.ByteToMessageDecoder = NewByteToMessageDecoder(hd)
+	return hd
+}
+
+// SetMaxHeaderSize sets the max size of the request line and headers.
+func (hd *HTTPRequestDecoder) SetMaxHeaderSize(maxHeaderSize int) *HTTPRequestDecoder {
+	hd.maxHeaderSize = maxHeaderSize
+	return hd
+}
+
+// SetMaxContentLength sets the max size of the request body.
+func (hd *HTTPRequestDecoder) SetMaxContentLength(maxContentLength int) *HTTPRequestDecoder {
+	hd.maxContentLength = maxContentLength
+	return hd
+}
+
+// Decode implements Decoder, decodes a part of the request per call.
+func (hd *HTTPRequestDecoder) Decode(ctx *core.ChannelContext, in bytes.ReadOnlyBuffer, out *[]interface{}) error {
+	switch hd.state {
+	case httpReadHeader:
+		return hd.decodeHeader(ctx, in, out)
+
+	case httpReadContent:
+		if in.Len() < hd.remaining {
+			return nil
+		}
+		hd.req.Body, _ = in.Read(0, hd.remaining)
+		hd.complete(out)
+
+	case httpReadChunkSize:
+		line, ok, err := hd.readLine(in, maxChunkSizeLine)
+		if err != nil {
+			return hd.fail(ctx, http.StatusBadRequest, err)
+		}
+		if !ok {
+			return nil
+		}
+		if i := stdbytes.IndexByte(line, ';'); i >= 0 {
+			line = line[:i] // ignore the chunk extensions.
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(string(line)), 16, 64)
+		if err != nil || size < 0 {
+			return hd.fail(ctx, http.StatusBadRequest, ErrHTTPBadChunk)
+		}
+		if size == 0 {
+			hd.state = httpReadTrailer
+		} else if int64(len(hd.req.Body))+size > int64(hd.maxContentLength) {
+			return hd.fail(ctx, http.StatusRequestEntityTooLarge, ErrHTTPContentTooLarge)
+		} else {
+			hd.remaining = int(size)
+			hd.state = httpReadChunkData
+		}
+
+	case httpReadChunkData:
+		if in.Len() < hd.remaining+len(crlf) {
+			return nil
+		}
+		data, _ := in.Read(0, hd.remaining)
+		if end, _ := in.Read(0, len(crlf)); !stdbytes.Equal(end, crlf) {
+			return hd.fail(ctx, http.StatusBadRequest, ErrHTTPBadChunk)
+		}
+		hd.req.Body = append(hd.req.Body, data...)
+		hd.state = httpReadChunkSize
+
+	case httpReadTrailer:
+		buf := in.Bytes()
+		if len(buf) < len(crlf) {
+			return nil
+		}
+		if stdbytes.HasPrefix(buf, crlf) {
+			in.Discard(len(crlf))
+			hd.complete(out)
+			return nil
+		}
+		end := stdbytes.Index(buf, headersEnd)
+		if end < 0 {
+			if len(buf) > hd.maxHeaderSize {
+				return hd.fail(ctx, http.StatusRequestHeaderFieldsTooLarge, ErrHTTPHeaderTooLarge)
+			}
+			return nil
+		}
+		trailer, _ := in.Read(0, end+len(headersEnd))
+		header, err := textproto.NewReader(bufio.NewReader(stdbytes.NewReader(trailer))).ReadMIMEHeader()
+		if err != nil {
+			return hd.fail(ctx, http.StatusBadRequest, err)
+		}
+		hd.req.Trailer = http.Header(header)
+		hd.complete(out)
+	}
+	return nil
+}
+
+// decodeHeader decodes the request line and headers, and decides how to read the body.
+func (hd *HTTPRequestDecoder) decodeHeader(ctx *core.ChannelContext, in bytes.ReadOnlyBuffer, out *[]interface{}) error {
+	buf := in.Bytes()
+	// ignore the empty lines before the request line, RFC 7230 section 3.5.
+	for stdbytes.HasPrefix(buf, crlf) {
+		in.Discard(len(crlf))
+		buf = in.Bytes()
+	}
+
+	end := stdbytes.Index(buf, headersEnd)
+	if end < 0 && len(buf) <= hd.maxHeaderSize {
+		return nil
+	}
+	if end < 0 || end+len(headersEnd) > hd.maxHeaderSize {
+		return hd.fail(ctx, http.StatusRequestHeaderFieldsTooLarge, ErrHTTPHeaderTooLarge)
+	}
+
+	head, _ := in.Read(0, end+len(headersEnd))
+	req, err := parseRequestHead(head)
+	if err != nil {
+		return hd.fail(ctx, http.StatusBadRequest, err)
+	}
+	hd.req = req
+
+	if strings.EqualFold(req.Header.Get("Expect"), "100-continue") && req.Proto == "HTTP/1.1" {
+		ctx.Write([]byte("HTTP/1.1 100 Continue\r\n\r\n"))
+	}
+
+	// the conflicting lengths are rejected, the proxies may frame the request differently, RFC 7230 section 3.3.3.
+	cl, err := contentLength(req.Header["Content-Length"])
+	if err != nil {
+		return hd.fail(ctx, http.StatusBadRequest, err)
+	}
+
+	if tes := req.Header["Transfer-Encoding"]; len(tes) > 1 {
+		return hd.fail(ctx, http.StatusBadRequest, fmt.Errorf("http: multiple Transfer-Encoding: %q", tes))
+	}
+	if te := req.Header.Get("Transfer-Encoding"); te != "" {
+		if cl != "" {
+			return hd.fail(ctx, http.StatusBadRequest, fmt.Errorf("http: Transfer-Encoding with Content-Length"))
+		}
+		if !strings.EqualFold(strings.TrimSpace(te), "chunked") {
+			return hd.fail(ctx, http.StatusNotImplemented, fmt.Errorf("http: unsupported transfer encoding: %q", te))
+		}
+		hd.state = httpReadChunkSize
+		return nil
+	}
+
+	if cl != "" {
+		length, err := strconv.ParseInt(cl, 10, 64)
+		if err != nil {
+			return hd.fail(ctx, http.StatusBadRequest, fmt.Errorf("http: bad Content-Length: %q", cl))
+		}
+		if length > int64(hd.maxContentLength) {
+			return hd.fail(ctx, http.StatusRequestEntityTooLarge, ErrHTTPContentTooLarge)
+		}
+		if length > 0 {
+			hd.remaining = int(length)
+			hd.state = httpReadContent
+			return nil
+		}
+	}
+
+	// the request without Content-Length or Transfer-Encoding has no body.
+	hd.complete(out)
+	return nil
+}
+
+// contentLength returns the Content-Length of the header values, the same values
+// repeated or separated by commas are accepted as one. Only the digits are valid,
+// the sign accepted by strconv is not.
+func contentLength(values []string) (string, error) {
+	var cl string
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			v = strings.TrimSpace(v)
+			if !isDigits(v) {
+				return "", fmt.Errorf("http: bad Content-Length: %q", value)
+			}
+			if cl != "" && v != cl {
+				return "", fmt.Errorf("http: conflicting Content-Length: %q", values)
+			}
+			cl = v
+		}
+	}
+	return cl, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// readLine reads a line end with "\r\n", returns false if the line not complete.
+func (hd *HTTPRequestDecoder) readLine(in bytes.ReadOnlyBuffer, maxLength int) ([]byte, bool, error) {
+	buf := in.Bytes()
+	end := stdbytes.Index(buf, crlf)
+	if end < 0 {
+		if len(buf) > maxLength {
+			return nil, false, ErrHTTPBadChunk
+		}
+		return nil, false, nil
+	}
+
+	line, _ := in.Read(0, end)
+	in.Discard(len(crlf))
+	return line, true, nil
+}
+
+func (hd *HTTPRequestDecoder) complete(out *[]interface{}) {
+	*out = append(*out, hd.req)
+	hd.Reset()
+}
+
+// Reset implements Resetter, drops the request being decoded.
+func (hd *HTTPRequestDecoder) Reset() {
+	hd.state = httpReadHeader
+	hd.req = nil
+	hd.remaining = 0
+}
+
+// fail responds the error status and closes the connection, the cumulated bytes
+// are discarded by the error returned.
+func (hd *HTTPRequestDecoder) fail(ctx *core.ChannelContext, statusCode int, err error) error {
+	hd.Reset()
+
+	resp := NewHTTPResponse(statusCode, []byte(http.StatusText(statusCode)))
+	resp.Close = true
+	ctx.Write(resp)
+	return err
+}
+
+// parseRequestHead parses the request line and headers, head ends with an empty line.
+func parseRequestHead(head []byte) (*HTTPRequest, error) {
+	tp := textproto.NewReader(bufio.NewReader(stdbytes.NewReader(head)))
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("http: malformed request line: %q", line)
+	}
+	req := &HTTPRequest{Method: parts[0], RequestURI: parts[1], Proto: parts[2]}
+	if req.Proto != "HTTP/1.1" && req.Proto != "HTTP/1.0" {
+		return nil, fmt.Errorf("http: unsupported protocol version: %q", req.Proto)
+	}
+	if req.URL, err = url.ParseRequestURI(req.RequestURI); err != nil {
+		return nil, err
+	}
+
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	req.Header = http.Header(header)
+	return req, nil
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	stdbytes "bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/amsalt/nginet/core"
+)
+
+// HTTPResponseEncoder encodes *HTTPResponse to HTTP/1.1 response bytes, the body is
+// sent with Content-Length or chunked transfer encoding. The connection is closed
+// gracefully after the response with Close set sent. Other messages are passed on.
+type HTTPResponseEncoder struct {
+	*core.DefaultOutboundHandler
+}
+
+// NewHTTPResponseEncoder creates a HTTPResponseEncoder.
+func NewHTTPResponseEncoder() *HTTPResponseEncoder {
+	return &HTTPResponseEncoder{core.NewDefaultOutboundHandler()}
+}
+
+// Sharable implements core.Sharable, each response is encoded independently.
+func (he *HTTPResponseEncoder) Sharable() {}
+
+// OnWrite implements OutboundHandler.
+func (he *HTTPResponseEncoder) OnWrite(ctx *core.ChannelContext, msg interface{}) {
+	resp, ok := msg.(*HTTPResponse)
+	if !ok {
+		ctx.FireWrite(msg)
+		return
+	}
+
+	ctx.FireWrite(encodeResponse(resp))
+	if resp.Close {
+		if gc, ok := ctx.Channel().(interface{ GracefullyClose() }); ok {
+			gc.GracefullyClose()
+		}
+	}
+}
+
+func encodeResponse(resp *HTTPResponse) []byte {
+	var buf stdbytes.Buffer
+	fmt.Fprintf(&buf, "HTTP/1.1 %03d %s\r\n", resp.StatusCode, http.StatusText(resp.StatusCode))
+
+	header := resp.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	// the 1xx, 204 and 304 responses have no body.
+	hasBody := resp.StatusCode >= http.StatusOK && resp.StatusCode != http.StatusNoContent &&
+		resp.StatusCode != http.StatusNotModified
+	if hasBody {
+		if resp.Chunked {
+			header.Del("Content-Length")
+			header.Set("Transfer-Encoding", "chunked")
+		} else {
+			header.Del("Transfer-Encoding")
+			header.Set("Content-Length", strconv.Itoa(len(resp.Body)))
+		}
+	}
+	if resp.Close {
+		header.Set("Connection", "close")
+	}
+	header.Write(&buf)
+	buf.WriteString("\r\n")
+
+	if !hasBody || resp.noBody {
+		return buf.Bytes()
+	}
+	if resp.Chunked {
+		if len(resp.Body) > 0 {
+			fmt.Fprintf(&buf, "%x\r\n", len(resp.Body))
+			buf.Write(resp.Body)
+			buf.WriteString("\r\n")
+		}
+		buf.WriteString("0\r\n\r\n")
+	} else {
+		buf.Write(resp.Body)
+	}
+	return buf.Bytes()
+}
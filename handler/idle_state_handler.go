@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"sync"
 	"time"
 
 	"github.com/amsalt/nginet/core"
@@ -22,18 +23,20 @@ type IdleStateHandler struct {
 	readTimeout    int
 	writeTimeout   int
 	needAllTimeout bool
-	rTimeout       bool
-	wTimeout       bool
 
+	// mutex guards the state below, which is updated by the timers.
+	mutex             sync.Mutex
+	rTimeout          bool
+	wTimeout          bool
 	lastReadTime      int
 	lastWriteTime     int
 	readTimeoutDelay  int
 	writeTimeoutDelay int
+	readCheckTimer    *time.Timer
+	writeCheckTimer   *time.Timer
 
-	readCheckTimer  *time.Timer
-	writeCheckTimer *time.Timer
-
-	stop bool
+	stop       bool
+	generation int // increased when added, the timers of the previous pipeline stop.
 }
 
 func NewIdleStateHandler(readTimeoutSec, writeTimeoutSec int, needAllTimeout bool) *IdleStateHandler {
@@ -52,25 +55,39 @@ func NewIdleStateHandler(readTimeoutSec, writeTimeoutSec int, needAllTimeout boo
 	return ish
 }
 
-func (ish *IdleStateHandler) OnConnect(ctx *core.ChannelContext, channel core.Channel) {
-	ish.run(ctx)
-	ctx.FireConnect(channel)
+// HandlerAdded starts the idle checking timers, the handler removed can be added again.
+func (ish *IdleStateHandler) HandlerAdded(ctx *core.ChannelContext) {
+	ish.mutex.Lock()
+	ish.stop = false
+	ish.generation++
+	generation := ish.generation
+	ish.rTimeout, ish.wTimeout = false, false
+	ish.readTimeoutDelay = ish.readTimeout
+	ish.writeTimeoutDelay = ish.writeTimeout
+	ish.mutex.Unlock()
+
+	ish.checkReadTimeout(ctx, generation)
+	ish.checkWriteTimeout(ctx, generation)
 }
 
-func (ish *IdleStateHandler) OnDisconnect(ctx *core.ChannelContext) {
+// HandlerRemoved stops the timers, it's called when removed from pipeline or the channel closed.
+func (ish *IdleStateHandler) HandlerRemoved(ctx *core.ChannelContext) {
 	ish.abort(ctx)
-	ctx.FireDisconnect()
 }
 
 func (ish *IdleStateHandler) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	ish.mutex.Lock()
 	ish.lastReadTime = time.Now().Nanosecond()
 	ish.readTimeoutDelay = ish.readTimeout
+	ish.mutex.Unlock()
 	ctx.FireRead(msg)
 }
 
 func (ish *IdleStateHandler) OnWrite(ctx *core.ChannelContext, msg interface{}) {
+	ish.mutex.Lock()
 	ish.lastWriteTime = time.Now().Nanosecond()
 	ish.writeTimeoutDelay = ish.writeTimeout
+	ish.mutex.Unlock()
 	ctx.FireWrite(msg)
 }
 
@@ -78,65 +95,96 @@ func (ish *IdleStateHandler) channelIdle(ctx *core.ChannelContext, event *IdleEv
 	ctx.FireEvent(event)
 }
 
-func (ish *IdleStateHandler) run(ctx *core.ChannelContext) {
-	ish.checkReadTimeout(ctx)
-	ish.checkWriteTimeout(ctx)
-}
-
 func (ish *IdleStateHandler) abort(ctx *core.ChannelContext) {
+	ish.mutex.Lock()
+	defer ish.mutex.Unlock()
+
 	ish.stop = true
-	ish.readCheckTimer.Stop()
-	ish.writeCheckTimer.Stop()
+	if ish.readCheckTimer != nil {
+		ish.readCheckTimer.Stop()
+	}
+	if ish.writeCheckTimer != nil {
+		ish.writeCheckTimer.Stop()
+	}
+}
+
+// running reports whether the timers of generation should go on, call it with mutex locked.
+func (ish *IdleStateHandler) running(generation int) bool {
+	return !ish.stop && generation == ish.generation
 }
 
-func (ish *IdleStateHandler) checkReadTimeout(ctx *core.ChannelContext) {
-	if ish.stop {
+func (ish *IdleStateHandler) checkReadTimeout(ctx *core.ChannelContext, generation int) {
+	ish.mutex.Lock()
+	defer ish.mutex.Unlock()
+	if !ish.running(generation) {
 		return
 	}
 
 	ish.readCheckTimer = time.AfterFunc(time.Second*time.Duration(ish.readTimeoutDelay), func() {
+		var event *IdleEvent
+		ish.mutex.Lock()
+		if !ish.running(generation) {
+			ish.mutex.Unlock()
+			return
+		}
 		ish.readTimeoutDelay -= (time.Now().Nanosecond() - ish.lastReadTime)
 		if ish.readTimeoutDelay < 0 {
 			ish.rTimeout = true
 			if !ish.needAllTimeout {
-				ish.channelIdle(ctx, &IdleEvent{TimeoutType: ReadTimeout})
+				event = &IdleEvent{TimeoutType: ReadTimeout}
 				ish.rTimeout = false
 				ish.readTimeoutDelay = ish.readTimeout
 			} else if ish.wTimeout {
-				ish.channelIdle(ctx, &IdleEvent{TimeoutType: AllTimeout})
+				event = &IdleEvent{TimeoutType: AllTimeout}
 				ish.rTimeout = false
 				ish.wTimeout = false
 				ish.readTimeoutDelay = ish.readTimeout
 				ish.writeTimeoutDelay = ish.writeTimeout
 			}
 		}
+		ish.mutex.Unlock()
 
-		ish.checkReadTimeout(ctx)
+		if event != nil {
+			ish.channelIdle(ctx, event)
+		}
+		ish.checkReadTimeout(ctx, generation)
 	})
 }
 
-func (ish *IdleStateHandler) checkWriteTimeout(ctx *core.ChannelContext) {
-	if ish.stop {
+func (ish *IdleStateHandler) checkWriteTimeout(ctx *core.ChannelContext, generation int) {
+	ish.mutex.Lock()
+	defer ish.mutex.Unlock()
+	if !ish.running(generation) {
 		return
 	}
 
 	ish.writeCheckTimer = time.AfterFunc(time.Second*time.Duration(ish.writeTimeoutDelay), func() {
+		var event *IdleEvent
+		ish.mutex.Lock()
+		if !ish.running(generation) {
+			ish.mutex.Unlock()
+			return
+		}
 		ish.writeTimeoutDelay -= (time.Now().Nanosecond() - ish.lastWriteTime)
 		if ish.writeTimeoutDelay < 0 {
-			ish.rTimeout = true
+			ish.wTimeout = true
 			if !ish.needAllTimeout {
-				ish.channelIdle(ctx, &IdleEvent{TimeoutType: WriteTimeout})
+				event = &IdleEvent{TimeoutType: WriteTimeout}
 				ish.wTimeout = false
 				ish.writeTimeoutDelay = ish.writeTimeout
 			} else if ish.rTimeout {
-				ish.channelIdle(ctx, &IdleEvent{TimeoutType: AllTimeout})
+				event = &IdleEvent{TimeoutType: AllTimeout}
 				ish.rTimeout = false
 				ish.wTimeout = false
 				ish.readTimeoutDelay = ish.readTimeout
 				ish.writeTimeoutDelay = ish.writeTimeout
 			}
 		}
+		ish.mutex.Unlock()
 
-		ish.checkWriteTimeout(ctx)
+		if event != nil {
+			ish.channelIdle(ctx, event)
+		}
+		ish.checkWriteTimeout(ctx, generation)
 	})
 }
@@ -0,0 +1,32 @@
+package handler
+
+// LineBasedFrameDecoder splits the inbound bytes by the end of line, both "\n" and "\r\n"
+// are handled. It's a DelimiterBasedFrameDecoder with LineDelimiters(), to split by
+// "\r\n" only, use NewDelimiterBasedFrameDecoder(maxLength, []byte("\r\n")) instead.
+// for example:
+//
+//	s.InitSubChannel(func(channel core.SubChannel) {
+//		channel.Pipeline().AddLast(nil, "LineBasedFrameDecoder", handler.NewLineBasedFrameDecoder(1024))
+//		channel.Pipeline().AddLast(nil, "StringEncoder", handler.NewStringEncoder())
+//	})
+type LineBasedFrameDecoder struct {
+	*DelimiterBasedFrameDecoder
+}
+
+// NewLineBasedFrameDecoder creates a LineBasedFrameDecoder, the line longer than maxLength
+// is discarded with ErrFrameTooLong fired.
+func NewLineBasedFrameDecoder(maxLength int) *LineBasedFrameDecoder {
+	return &LineBasedFrameDecoder{NewDelimiterBasedFrameDecoder(maxLength, LineDelimiters()...)}
+}
+
+// SetStripDelimiter sets whether the end of line is stripped from the line, default is true.
+func (ld *LineBasedFrameDecoder) SetStripDelimiter(stripDelimiter bool) *LineBasedFrameDecoder {
+	ld.DelimiterBasedFrameDecoder.SetStripDelimiter(stripDelimiter)
+	return ld
+}
+
+// SetFailFast sets when ErrFrameTooLong fired, see DelimiterBasedFrameDecoder.SetFailFast.
+func (ld *LineBasedFrameDecoder) SetFailFast(failFast bool) *LineBasedFrameDecoder {
+	ld.DelimiterBasedFrameDecoder.SetFailFast(failFast)
+	return ld
+}
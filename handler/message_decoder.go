@@ -23,6 +23,9 @@ func NewMessageDecoder(messageDeserializer *MessageDeserializer, idParser *IDPar
 	return md
 }
 
+// Sharable implements core.Sharable, decoding keeps no state between messages.
+func (md *MessageDecoder) Sharable() {}
+
 // OnRead ipdlements InboundHandler.
 func (md *MessageDecoder) OnRead(ctx *core.ChannelContext, msg interface{}) {
 	if buf, ok := msg.(bytes.ReadOnlyBuffer); ok {
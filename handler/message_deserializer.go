@@ -25,6 +25,9 @@ func NewMessageDeserializer(register message.Register, codec encoding.Codec) *Me
 	return md
 }
 
+// Sharable implements core.Sharable, deserializing keeps no state between messages.
+func (md *MessageDeserializer) Sharable() {}
+
 // OnRead ipdlements InboundHandler.
 func (md *MessageDeserializer) OnRead(ctx *core.ChannelContext, msg interface{}) {
 	if params, ok := msg.([]interface{}); ok && len(params) > 1 {
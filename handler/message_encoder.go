@@ -24,6 +24,9 @@ func NewMessageEncoder(messageSerializer *MessageSerializer, idParser *IDParser)
 	return me
 }
 
+// Sharable implements core.Sharable, encoding keeps no state between messages.
+func (me *MessageEncoder) Sharable() {}
+
 func (me *MessageEncoder) OnWrite(ctx *core.ChannelContext, msg interface{}) {
 	log.Debugf("MessageEncoder OnWrite: %+v", msg)
 	if _, ok := msg.([]byte); ok {
@@ -33,7 +36,7 @@ func (me *MessageEncoder) OnWrite(ctx *core.ChannelContext, msg interface{}) {
 	} else {
 		buf, err := me.encode(msg)
 		if err != nil {
-			ctx.FireError(err)
+			ctx.FailWrite(err)
 		} else {
 			ctx.FireWrite(buf)
 		}
@@ -23,14 +23,25 @@ func NewDefaultMessageHandler(mgr message.ProcessorMgr) *DefaultMessageHandler {
 	return mh
 }
 
+// Sharable implements core.Sharable, the processors are shared by all channels.
+func (mh *DefaultMessageHandler) Sharable() {}
+
 // OnRead InboundHandler
+// The msg is []interface{}{id, msg, args...} decoded by MessageDecoder, or a message.Packet
+// such as *HTTPRequest.
 func (mh *DefaultMessageHandler) OnRead(ctx *core.ChannelContext, msg interface{}) {
-	if params, ok := msg.([]interface{}); ok && len(params) > 1 {
+	params, ok := msg.([]interface{})
+	if packet, isPacket := msg.(message.Packet); isPacket {
+		params, ok = []interface{}{packet.ID(), packet.Payload()}, true
+	}
+
+	if ok && len(params) > 1 {
 		id := params[0]
 		p := mh.processorMgr.GetProcessorByID(id)
 		if p == nil {
 			log.Errorf("msg id %+v not register processor", id)
 			ctx.FireError(errors.New("msg not registered"))
+			return
 		}
 		if len(params) > 2 {
 			var args []interface{}
@@ -28,6 +28,9 @@ func NewMessageSerializer(register message.Register, codec encoding.Codec) *Mess
 	return ms
 }
 
+// Sharable implements core.Sharable, serializing keeps no state between messages.
+func (ms *MessageSerializer) Sharable() {}
+
 func (ms *MessageSerializer) OnWrite(ctx *core.ChannelContext, msg interface{}) {
 	if rawBytes, ok := msg.([]byte); ok {
 		ctx.FireWrite(rawBytes)
@@ -41,16 +44,16 @@ func (ms *MessageSerializer) OnWrite(ctx *core.ChannelContext, msg interface{})
 		if ok {
 			err := ms.EncodePayload(buf, msgOrigin, msgID)
 			if err != nil {
-				ctx.FireError(err)
+				ctx.FailWrite(err)
 				return
 			}
 			ctx.FireWrite(buf)
 		} else {
-			ctx.FireError(errors.New("MessageSerializer.OnWrite invalid msg type,an bytes.WriteOnlyBuffer required."))
+			ctx.FailWrite(errors.New("MessageSerializer.OnWrite invalid msg type,an bytes.WriteOnlyBuffer required."))
 		}
 
 	} else {
-		ctx.FireError(errors.New("MessageSerializer.OnWrite invalid msg type, an array required."))
+		ctx.FailWrite(errors.New("MessageSerializer.OnWrite invalid msg type, an array required."))
 	}
 }
 
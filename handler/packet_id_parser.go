@@ -29,6 +29,9 @@ func NewIDParser(register message.Register, parser message.PacketIDParser) *IDPa
 	return ip
 }
 
+// Sharable implements core.Sharable, the register and parser are only read.
+func (ip *IDParser) Sharable() {}
+
 // OnRead implements InboundHandler
 func (ip *IDParser) OnRead(ctx *core.ChannelContext, msg interface{}) {
 	log.Debugf("IDParser OnRead message type: %T", msg)
@@ -100,7 +103,7 @@ func (ip *IDParser) OnWrite(ctx *core.ChannelContext, msg interface{}) {
 			output = append(output, idBuf, msg, id)
 			ctx.FireWrite(output)
 		} else {
-			ctx.FireError(err)
+			ctx.FailWrite(err)
 		}
 	}
 }
@@ -15,6 +15,18 @@ const (
 	MaxExtraLen  = 8
 )
 
+// LengthMode represents what the value of the length field counts.
+type LengthMode int
+
+const (
+	// LengthInclusive means the length counts the whole frame, include the length field
+	// and the bytes before it, the default mode.
+	LengthInclusive LengthMode = iota
+
+	// LengthExclusive means the length counts the bytes after the length field only.
+	LengthExclusive
+)
+
 var (
 	ErrFrameTooLong     = errors.New("LengthFieldBasedFrameDecoder: frame too long")
 	ErrInBoundEventType = errors.New("Invalid type for OnInboundEvent args")
@@ -33,18 +45,27 @@ var (
 // |   length    |  id_header   | msg_payload |
 // --------------|--------------|--------------
 //
-// binary data decoder based on head size and payload body
+// binary data decoder based on head size and payload body, the packet split
+// across reads is cumulated by ByteToMessageDecoder.
 // netty-like PacketLengthDecoder
 type PacketLengthDecoder struct {
-	*core.DefaultInboundHandler
+	*ByteToMessageDecoder
 
 	maxFrameLength      uint64
 	lengthFieldOffset   uint
 	sizeOfLengthField   uint
 	initialBytesToStrip int
 	byteorder           binary.ByteOrder // default binary.BigEndian
+	lengthMode          LengthMode
+	lengthAdjustment    int64
+	failFast            bool
 
 	lengthFieldEndOffset int
+
+	// the state of discarding a too long frame.
+	discarding         bool
+	bytesToDiscard     uint64
+	tooLongFrameLength uint64
 }
 
 // NewPacketLengthDecoder return new LengthFieldBasedFrameDecoder
@@ -57,11 +78,12 @@ func NewPacketLengthDecoder(sizeOfLengthField uint) *PacketLengthDecoder {
 	pld := &PacketLengthDecoder{
 		sizeOfLengthField: sizeOfLengthField,
 	}
-	pld.DefaultInboundHandler = core.NewDefaultInboundHandler()
+	pld.ByteToMessageDecoder = NewByteToMessageDecoder(pld)
 	pld.maxFrameLength = calcMaxFrameLen(sizeOfLengthField) // default length
 	pld.recalcLengthFieldEndOffset()
 	pld.byteorder = binary.BigEndian
 	pld.initialBytesToStrip = -1
+	pld.failFast = true
 
 	return pld
 }
@@ -101,54 +123,99 @@ func (pld *PacketLengthDecoder) SetInitialBytesToStrip(initialBytesToStrip uint)
 	return pld
 }
 
-// OnRead implements OutboundHandler.
-func (pld *PacketLengthDecoder) OnRead(ctx *core.ChannelContext, msg interface{}) {
-	if data, ok := msg.(bytes.ReadOnlyBuffer); ok {
-		output, err := pld.decode(ctx, data)
-		log.Debugf("PacketLengthDecoder.OnRead: %+v, err: %+v", output, err)
-		if err == nil {
-			ctx.FireRead(output)
-		} else {
-			ctx.FireError(fmt.Errorf("PacketLengthDecoder failed: %+v", err))
-		}
-	} else {
-		ctx.FireError(fmt.Errorf("PacketLengthDecoder.OnRead msg not bytes.ReadOnlyBuffer"))
-	}
+// SetLengthMode sets what the value of the length field counts, default is LengthInclusive.
+func (pld *PacketLengthDecoder) SetLengthMode(mode LengthMode) *PacketLengthDecoder {
+	pld.lengthMode = mode
+	return pld
+}
+
+// SetLengthAdjustment sets the value added to the length field to get the frame length,
+// e.g. -2 if the length counts a 2 bytes checksum not sent.
+func (pld *PacketLengthDecoder) SetLengthAdjustment(lengthAdjustment int) *PacketLengthDecoder {
+	pld.lengthAdjustment = int64(lengthAdjustment)
+	return pld
+}
+
+// SetFailFast sets when ErrFrameTooLong fired for a frame exceeds maxFrameLength.
+// The too long frame is always discarded and the following frames are decoded normally,
+// the error is fired as soon as the length field read if failFast(default),
+// otherwise after the whole frame discarded.
+func (pld *PacketLengthDecoder) SetFailFast(failFast bool) *PacketLengthDecoder {
+	pld.failFast = failFast
+	return pld
 }
 
-func (pld *PacketLengthDecoder) decode(ctx *core.ChannelContext, buff bytes.ReadOnlyBuffer) (interface{}, error) {
-	if buff.Len() < pld.lengthFieldEndOffset {
-		return nil, errors.New("return for buff len less then lengthFieldEndOffset")
+// Decode implements Decoder, decodes one packet if the bytes are enough.
+func (pld *PacketLengthDecoder) Decode(ctx *core.ChannelContext, in bytes.ReadOnlyBuffer, out *[]interface{}) error {
+	if pld.discarding {
+		pld.discardTooLongFrame(ctx, in)
+		return nil
 	}
 
-	frameLength := pld.getUnadjustedFrameLength(buff.Bytes())
+	if in.Len() < pld.lengthFieldEndOffset {
+		return nil
+	}
+
+	frameLength := int64(pld.getUnadjustedFrameLength(in.Bytes())) + pld.lengthAdjustment
+	if pld.lengthMode == LengthExclusive {
+		frameLength += int64(pld.lengthFieldEndOffset)
+	}
 	log.Debugf("PacketLengthDecoder.decode frameLength: %+v", frameLength)
 
-	if buff.Len() < int(frameLength) {
-		errInfo := fmt.Sprintf("return for buff len: %v less then int(frameLength): %v ",
-			buff.Len(), int(frameLength))
-		log.Errorf(errInfo)
-		return nil, errors.New(errInfo)
+	skip := pld.getInitialBytesToStrip()
+	if frameLength < int64(pld.lengthFieldEndOffset) || uint64(frameLength) < skip {
+		return fmt.Errorf("PacketLengthDecoder corrupted frame, frameLength: %v", frameLength)
 	}
 
-	if frameLength > pld.maxFrameLength {
-		return nil, errors.New("return for ErrFrameTooLong")
+	if uint64(frameLength) > pld.maxFrameLength {
+		pld.discarding = true
+		pld.bytesToDiscard = uint64(frameLength)
+		pld.tooLongFrameLength = uint64(frameLength)
+		if pld.failFast {
+			ctx.FireError(fmt.Errorf("%w: %v exceeds %v", ErrFrameTooLong, frameLength, pld.maxFrameLength))
+		}
+		pld.discardTooLongFrame(ctx, in)
+		return nil
 	}
 
-	skip := pld.getInitialBytesToStrip()
-	actualFrameLen := frameLength - skip
+	// wait for more bytes.
+	if int64(in.Len()) < frameLength {
+		return nil
+	}
 
-	// TODO: check buffer safe again.
-	// Notice: buff must used in the same goroutine.
-	msg, err := buff.Read(int(skip), int(actualFrameLen))
+	msg, err := in.Read(int(skip), int(uint64(frameLength)-skip))
 	if err != nil {
-		errInfo := fmt.Sprintf("PacketLengthDecoder.decode buff read error: %+v", err)
-		log.Errorf(errInfo)
-		return nil, errors.New(errInfo)
+		return fmt.Errorf("PacketLengthDecoder.decode buff read error: %+v", err)
 	}
 
 	log.Debugf("PacketLengthDecoder.decode: %+v", msg)
-	return bytes.NewReadOnlyBufferWithBytes(msg), nil
+	*out = append(*out, bytes.NewReadOnlyBufferWithBytes(msg))
+	return nil
+}
+
+// Reset implements Resetter, stops discarding the too long frame.
+func (pld *PacketLengthDecoder) Reset() {
+	pld.discarding = false
+	pld.bytesToDiscard = 0
+	pld.tooLongFrameLength = 0
+}
+
+// discardTooLongFrame discards the bytes of the too long frame received.
+func (pld *PacketLengthDecoder) discardTooLongFrame(ctx *core.ChannelContext, in bytes.ReadOnlyBuffer) {
+	n := pld.bytesToDiscard
+	if uint64(in.Len()) < n {
+		n = uint64(in.Len())
+	}
+	in.Discard(int(n))
+	pld.bytesToDiscard -= n
+	if pld.bytesToDiscard > 0 {
+		return
+	}
+
+	pld.discarding = false
+	if !pld.failFast {
+		ctx.FireError(fmt.Errorf("%w: %v exceeds %v", ErrFrameTooLong, pld.tooLongFrameLength, pld.maxFrameLength))
+	}
 }
 
 func (pld *PacketLengthDecoder) getUnadjustedFrameLength(buf []byte) uint64 {
@@ -3,6 +3,7 @@ package handler
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 
 	"github.com/amsalt/log"
 	"github.com/amsalt/nginet/bytes"
@@ -27,6 +28,9 @@ type PacketLengthPrepender struct {
 	*core.DefaultOutboundHandler
 	lengthFieldLength uint
 	byteorder         binary.ByteOrder // default binary.BigEndian
+	lengthMode        LengthMode
+	lengthAdjustment  int64
+	prefix            []byte
 }
 
 func NewPacketLengthPrepender(lengthFieldLength uint) *PacketLengthPrepender {
@@ -37,6 +41,9 @@ func NewPacketLengthPrepender(lengthFieldLength uint) *PacketLengthPrepender {
 	return plp
 }
 
+// Sharable implements core.Sharable, the length is prepended per packet.
+func (plp *PacketLengthPrepender) Sharable() {}
+
 // SetByteOrder Set byte order, default is binary.BigEndian
 // 	byteorder:
 // 		binary.BigEndian
@@ -46,6 +53,27 @@ func (plp *PacketLengthPrepender) SetByteOrder(byteorder binary.ByteOrder) *Pack
 	return plp
 }
 
+// SetLengthMode sets what the value of the length field counts, default is LengthInclusive.
+// Should be the same as the PacketLengthDecoder of the opposite side.
+func (plp *PacketLengthPrepender) SetLengthMode(mode LengthMode) *PacketLengthPrepender {
+	plp.lengthMode = mode
+	return plp
+}
+
+// SetLengthAdjustment sets the value subtracted from the frame length to get the length field,
+// the same as the lengthAdjustment of PacketLengthDecoder.
+func (plp *PacketLengthPrepender) SetLengthAdjustment(lengthAdjustment int) *PacketLengthPrepender {
+	plp.lengthAdjustment = int64(lengthAdjustment)
+	return plp
+}
+
+// SetPrefix sets the bytes written before the length field, such as a magic number, the length
+// counts them in LengthInclusive mode. The lengthFieldOffset of PacketLengthDecoder should be len(prefix).
+func (plp *PacketLengthPrepender) SetPrefix(prefix []byte) *PacketLengthPrepender {
+	plp.prefix = prefix
+	return plp
+}
+
 // OnOutboundEvent process outbound event.
 func (plp *PacketLengthPrepender) OnWrite(ctx *core.ChannelContext, msg interface{}) {
 	if rawBytes, ok := msg.([]byte); ok {
@@ -57,7 +85,7 @@ func (plp *PacketLengthPrepender) OnWrite(ctx *core.ChannelContext, msg interfac
 			ctx.FireWrite(data)
 		} else {
 			log.Errorf("PacketLengthPrepender.OnWrite failed: %+v", err)
-			ctx.FireError(err)
+			ctx.FailWrite(err)
 		}
 	}
 }
@@ -66,10 +94,17 @@ func (plp *PacketLengthPrepender) OnWrite(ctx *core.ChannelContext, msg interfac
 func (plp *PacketLengthPrepender) encode(ctx *core.ChannelContext, msg interface{}) (output interface{}, err error) {
 	maxFrameLen := calcMaxFrameLen(plp.lengthFieldLength)
 	if buff, ok := msg.(bytes.WriteOnlyBuffer); ok {
-		actualLen := uint(buff.Len()) + plp.lengthFieldLength
-		if uint64(actualLen) > maxFrameLen {
+		length := int64(buff.Len()) - plp.lengthAdjustment
+		if plp.lengthMode == LengthInclusive {
+			length += int64(plp.lengthFieldLength) + int64(len(plp.prefix))
+		}
+		if length < 0 {
+			return nil, fmt.Errorf("PacketLengthPrepender negative length: %v", length)
+		}
+		if uint64(length) > maxFrameLen {
 			return nil, ErrFrameTooLong
 		}
+		actualLen := uint64(length)
 
 		var head []byte
 		switch plp.lengthFieldLength {
@@ -89,6 +124,9 @@ func (plp *PacketLengthPrepender) encode(ctx *core.ChannelContext, msg interface
 			plp.byteorder.PutUint64(head, uint64(actualLen))
 			_, err = buff.WriteHeader(head)
 		}
+		if err == nil && len(plp.prefix) > 0 {
+			_, err = buff.WriteHeader(plp.prefix)
+		}
 
 		output = buff
 	} else {
@@ -0,0 +1,296 @@
+package handler
+
+import (
+	stdbytes "bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+)
+
+const (
+	// DefaultRESPMaxBulkLength is the default max length of a bulk string.
+	DefaultRESPMaxBulkLength = 16 * 1024 * 1024
+
+	// DefaultRESPMaxInlineLength is the default max length of an inline command or
+	// a line of the simple types.
+	DefaultRESPMaxInlineLength = 64 * 1024
+
+	// DefaultRESPMaxDepth is the default max nesting depth of the aggregate types.
+	DefaultRESPMaxDepth = 32
+
+	// maxRESPElements is the max number of elements of an aggregate type.
+	maxRESPElements = 1024 * 1024
+)
+
+var (
+	ErrRESPProtocol = errors.New("RESP: protocol error")
+	ErrRESPTooLarge = errors.New("RESP: value too large")
+
+	errRESPIncomplete = errors.New("RESP: incomplete")
+)
+
+// RESPDecoder decodes the RESP2 and RESP3 values to *RESPValue. The inline commands
+// sent by telnet, such as "PING\r\n", are decoded to the arrays of bulk strings, same
+// as the commands sent by clients. The value split across reads is decoded when
+// all bytes of it received. A protocol error fires ErrRESPProtocol, and the server
+// should reply an error and close the connection as Redis does.
+type RESPDecoder struct {
+	*ByteToMessageDecoder
+
+	maxBulkLength   int
+	maxInlineLength int
+	maxDepth        int
+	inline          bool
+
+	// the state of the value being decoded, kept between Decode calls.
+	stack      []*respAggregate // the aggregates waiting for the elements, the innermost last.
+	bulk       *RESPValue       // the bulk string waiting for the body.
+	bulkLength int
+}
+
+// respAggregate is an aggregate value waiting for count elements.
+type respAggregate struct {
+	value *RESPValue
+	count int
+}
+
+// NewRESPDecoder creates a RESPDecoder.
+func NewRESPDecoder() *RESPDecoder {
+	rd := &RESPDecoder{
+		maxBulkLength:   DefaultRESPMaxBulkLength,
+		maxInlineLength: DefaultRESPMaxInlineLength,
+		maxDepth:        DefaultRESPMaxDepth,
+		inline:          true,
+	}
+	rd.ByteToMessageDecoder = NewByteToMessageDecoder(rd)
+	return rd
+}
+
+// SetMaxBulkLength sets the max length of a bulk string.
+func (rd *RESPDecoder) SetMaxBulkLength(maxBulkLength int) *RESPDecoder {
+	rd.maxBulkLength = maxBulkLength
+	return rd
+}
+
+// SetMaxInlineLength sets the max length of an inline command or a line of the simple types.
+func (rd *RESPDecoder) SetMaxInlineLength(maxInlineLength int) *RESPDecoder {
+	rd.maxInlineLength = maxInlineLength
+	return rd
+}
+
+// SetMaxDepth sets the max nesting depth of the arrays, maps, sets and pushes.
+func (rd *RESPDecoder) SetMaxDepth(maxDepth int) *RESPDecoder {
+	rd.maxDepth = maxDepth
+	return rd
+}
+
+// SetInline sets whether the inline commands are accepted, default true. The clients
+// should disable it, since the replies from servers are never inline.
+func (rd *RESPDecoder) SetInline(inline bool) *RESPDecoder {
+	rd.inline = inline
+	return rd
+}
+
+// Reset implements Resetter, discards the value being decoded.
+func (rd *RESPDecoder) Reset() {
+	rd.stack = nil
+	rd.bulk = nil
+	rd.bulkLength = 0
+}
+
+// Decode implements Decoder, decodes a line or a bulk string body per call. The elements
+// decoded are kept until the aggregate completes, so the bytes are parsed once however split.
+func (rd *RESPDecoder) Decode(ctx *core.ChannelContext, in bytes.ReadOnlyBuffer, out *[]interface{}) error {
+	buf := in.Bytes()
+	if len(buf) == 0 {
+		return nil
+	}
+
+	var value *RESPValue
+	var n int
+	var err error
+	if rd.bulk != nil {
+		value, n, err = rd.parseBulk(buf)
+	} else if isRESPType(buf[0]) {
+		value, n, err = rd.parse(buf)
+	} else if rd.inline && len(rd.stack) == 0 {
+		value, n, err = rd.parseInline(buf)
+	} else {
+		err = fmt.Errorf("%w: unknown type %q", ErrRESPProtocol, buf[0])
+	}
+
+	if err == errRESPIncomplete {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	in.Discard(n)
+	if value != nil {
+		if value = rd.complete(value); value != nil {
+			*out = append(*out, value)
+		}
+	}
+	return nil
+}
+
+// complete adds the value to the innermost aggregate, returns the top-level value if completed.
+func (rd *RESPDecoder) complete(value *RESPValue) *RESPValue {
+	for len(rd.stack) > 0 {
+		top := rd.stack[len(rd.stack)-1]
+		top.value.Array = append(top.value.Array, value)
+		if len(top.value.Array) < top.count {
+			return nil
+		}
+		rd.stack = rd.stack[:len(rd.stack)-1]
+		value = top.value
+	}
+	return value
+}
+
+// parse parses a line from the start of buf, returns the value and the number of bytes parsed.
+// The value is nil if it waits for the bulk string body or the aggregate elements.
+func (rd *RESPDecoder) parse(buf []byte) (*RESPValue, int, error) {
+	line, n, err := rd.readLine(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	typ, body := RESPType(line[0]), string(line[1:])
+	value := &RESPValue{Type: typ}
+	switch typ {
+	case RESPSimpleString, RESPError, RESPDouble, RESPBigNumber:
+		value.Str = body
+
+	case RESPInteger:
+		if value.Int, err = strconv.ParseInt(body, 10, 64); err != nil {
+			return nil, 0, fmt.Errorf("%w: invalid integer %q", ErrRESPProtocol, body)
+		}
+
+	case RESPNull:
+		if body != "" {
+			return nil, 0, fmt.Errorf("%w: invalid null %q", ErrRESPProtocol, body)
+		}
+		value.Null = true
+
+	case RESPBoolean:
+		if body != "t" && body != "f" {
+			return nil, 0, fmt.Errorf("%w: invalid boolean %q", ErrRESPProtocol, body)
+		}
+		value.Bool = body == "t"
+
+	case RESPBulkString, RESPBulkError, RESPVerbatimString:
+		length, err := rd.parseLength(body, rd.maxBulkLength)
+		if err != nil {
+			return nil, 0, err
+		}
+		if length < 0 {
+			value.Null = true
+			break
+		}
+		rd.bulk, rd.bulkLength = value, length
+		return nil, n, nil
+
+	case RESPArray, RESPMap, RESPSet, RESPPush:
+		if len(rd.stack) >= rd.maxDepth {
+			return nil, 0, fmt.Errorf("%w: nesting depth exceeds %v", ErrRESPTooLarge, rd.maxDepth)
+		}
+		count, err := rd.parseLength(body, maxRESPElements)
+		if err != nil {
+			return nil, 0, err
+		}
+		if count < 0 {
+			value.Null = true
+			break
+		}
+		if typ == RESPMap {
+			count *= 2
+		}
+		// each element takes 3 bytes at least, don't trust the count before the bytes received.
+		capacity := count
+		if capacity > (len(buf)-n)/3 {
+			capacity = (len(buf) - n) / 3
+		}
+		value.Array = make([]*RESPValue, 0, capacity)
+		if count > 0 {
+			rd.stack = append(rd.stack, &respAggregate{value: value, count: count})
+			return nil, n, nil
+		}
+
+	default:
+		return nil, 0, fmt.Errorf("%w: unsupported type %q", ErrRESPProtocol, line[0])
+	}
+	return value, n, nil
+}
+
+// parseBulk parses the body of the bulk string waiting, which ends with CRLF.
+func (rd *RESPDecoder) parseBulk(buf []byte) (*RESPValue, int, error) {
+	n := rd.bulkLength + len(crlf)
+	if len(buf) < n {
+		return nil, 0, errRESPIncomplete
+	}
+	if !stdbytes.Equal(buf[rd.bulkLength:n], crlf) {
+		return nil, 0, fmt.Errorf("%w: bulk string not end with CRLF", ErrRESPProtocol)
+	}
+
+	value := rd.bulk
+	value.Str = string(buf[:rd.bulkLength])
+	rd.bulk, rd.bulkLength = nil, 0
+	return value, n, nil
+}
+
+// parseLength parses the length of the bulk strings and aggregate types, -1 means null.
+func (rd *RESPDecoder) parseLength(body string, max int) (int, error) {
+	length, err := strconv.Atoi(body)
+	if err != nil || length < -1 {
+		return 0, fmt.Errorf("%w: invalid length %q", ErrRESPProtocol, body)
+	}
+	if max > 0 && length > max {
+		return 0, fmt.Errorf("%w: %v exceeds %v", ErrRESPTooLarge, length, max)
+	}
+	return length, nil
+}
+
+// readLine reads a line end with CRLF, returns the line without CRLF and the number of bytes read.
+func (rd *RESPDecoder) readLine(buf []byte) ([]byte, int, error) {
+	end := stdbytes.Index(buf, crlf)
+	if end < 0 {
+		if rd.maxInlineLength > 0 && len(buf) > rd.maxInlineLength {
+			return nil, 0, fmt.Errorf("%w: line exceeds %v", ErrRESPTooLarge, rd.maxInlineLength)
+		}
+		return nil, 0, errRESPIncomplete
+	}
+	return buf[:end], end + len(crlf), nil
+}
+
+// parseInline parses an inline command, the arguments are separated by spaces and
+// the line ends with LF or CRLF. Returns nil value for an empty line.
+func (rd *RESPDecoder) parseInline(buf []byte) (*RESPValue, int, error) {
+	end := stdbytes.IndexByte(buf, '\n')
+	if end < 0 {
+		if rd.maxInlineLength > 0 && len(buf) > rd.maxInlineLength {
+			return nil, 0, fmt.Errorf("%w: inline command exceeds %v", ErrRESPTooLarge, rd.maxInlineLength)
+		}
+		return nil, 0, errRESPIncomplete
+	}
+
+	args := strings.Fields(string(buf[:end]))
+	if len(args) == 0 {
+		return nil, end + 1, nil
+	}
+	return NewRESPCommand(args...), end + 1, nil
+}
+
+func isRESPType(b byte) bool {
+	switch RESPType(b) {
+	case RESPSimpleString, RESPError, RESPInteger, RESPBulkString, RESPArray,
+		RESPNull, RESPBoolean, RESPDouble, RESPBigNumber, RESPBulkError,
+		RESPVerbatimString, RESPMap, RESPSet, RESPPush:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/amsalt/nginet/core"
+)
+
+// RESPEncoder encodes *RESPValue to the RESP bytes, other messages are passed on.
+// Clients write the commands created by NewRESPCommand, and servers write the replies:
+//
+//	processMgr.RegisterProcessorByID("PING", func(ctx *core.ChannelContext, msg interface{}, args ...interface{}) {
+//		ctx.Write(handler.NewRESPSimpleString("PONG"))
+//	})
+type RESPEncoder struct {
+	*core.DefaultOutboundHandler
+}
+
+// NewRESPEncoder creates a RESPEncoder.
+func NewRESPEncoder() *RESPEncoder {
+	return &RESPEncoder{core.NewDefaultOutboundHandler()}
+}
+
+// Sharable implements core.Sharable, the values are encoded without any state.
+func (re *RESPEncoder) Sharable() {}
+
+// OnWrite implements OutboundHandler.
+func (re *RESPEncoder) OnWrite(ctx *core.ChannelContext, msg interface{}) {
+	value, ok := msg.(*RESPValue)
+	if !ok {
+		ctx.FireWrite(msg)
+		return
+	}
+	ctx.FireWrite(value.Bytes())
+}
+
+// Bytes returns the RESP bytes of the value.
+func (v *RESPValue) Bytes() []byte {
+	return appendRESP(nil, v)
+}
+
+func appendRESP(buf []byte, v *RESPValue) []byte {
+	if v.Null {
+		switch v.Type {
+		case RESPBulkString, RESPBulkError, RESPVerbatimString:
+			return append(buf, "$-1\r\n"...)
+		case RESPArray, RESPMap, RESPSet, RESPPush:
+			return append(buf, "*-1\r\n"...)
+		default:
+			return append(buf, "_\r\n"...)
+		}
+	}
+
+	buf = append(buf, byte(v.Type))
+	switch v.Type {
+	case RESPInteger:
+		buf = strconv.AppendInt(buf, v.Int, 10)
+
+	case RESPBoolean:
+		if v.Bool {
+			buf = append(buf, 't')
+		} else {
+			buf = append(buf, 'f')
+		}
+
+	case RESPBulkString, RESPBulkError, RESPVerbatimString:
+		buf = strconv.AppendInt(buf, int64(len(v.Str)), 10)
+		buf = append(buf, crlf...)
+		buf = append(buf, v.Str...)
+
+	case RESPArray, RESPMap, RESPSet, RESPPush:
+		count := len(v.Array)
+		if v.Type == RESPMap {
+			count /= 2
+		}
+		buf = strconv.AppendInt(buf, int64(count), 10)
+		buf = append(buf, crlf...)
+		for _, elem := range v.Array {
+			buf = appendRESP(buf, elem)
+		}
+		return buf
+
+	case RESPNull:
+
+	default:
+		// the simple types can't contain CR or LF.
+		for i := 0; i < len(v.Str); i++ {
+			if c := v.Str[i]; c == '\r' || c == '\n' {
+				buf = append(buf, ' ')
+			} else {
+				buf = append(buf, c)
+			}
+		}
+	}
+	return append(buf, crlf...)
+}
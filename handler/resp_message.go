@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RESPType represents the type of RESP(REdis Serialization Protocol) value.
+type RESPType byte
+
+// RESP2 and RESP3 types, the value is the first byte of the type on the wire.
+const (
+	RESPSimpleString RESPType = '+'
+	RESPError        RESPType = '-'
+	RESPInteger      RESPType = ':'
+	RESPBulkString   RESPType = '$'
+	RESPArray        RESPType = '*'
+
+	// RESP3 only.
+	RESPNull           RESPType = '_'
+	RESPBoolean        RESPType = '#'
+	RESPDouble         RESPType = ','
+	RESPBigNumber      RESPType = '('
+	RESPBulkError      RESPType = '!'
+	RESPVerbatimString RESPType = '='
+	RESPMap            RESPType = '%'
+	RESPSet            RESPType = '~'
+	RESPPush           RESPType = '>'
+)
+
+// RESPValue represents a RESP value decoded by RESPDecoder or encoded by RESPEncoder.
+//
+//	RESPSimpleString, RESPError, RESPBulkString, RESPBulkError, RESPVerbatimString,
+//	RESPDouble, RESPBigNumber: Str
+//	RESPInteger: Int
+//	RESPBoolean: Bool
+//	RESPArray, RESPSet, RESPPush: Array
+//	RESPMap: Array holds the keys and values in turn, k1, v1, k2, v2...
+//
+// The null bulk string and null array of RESP2 are RESPBulkString and RESPArray with Null set.
+type RESPValue struct {
+	Type  RESPType
+	Str   string
+	Int   int64
+	Bool  bool
+	Array []*RESPValue
+	Null  bool
+}
+
+// NewRESPSimpleString creates a simple string, such as "OK".
+func NewRESPSimpleString(s string) *RESPValue {
+	return &RESPValue{Type: RESPSimpleString, Str: s}
+}
+
+// NewRESPError creates an error, such as "ERR unknown command".
+func NewRESPError(s string) *RESPValue {
+	return &RESPValue{Type: RESPError, Str: s}
+}
+
+// NewRESPInteger creates an integer.
+func NewRESPInteger(n int64) *RESPValue {
+	return &RESPValue{Type: RESPInteger, Int: n}
+}
+
+// NewRESPBulkString creates a bulk string.
+func NewRESPBulkString(s string) *RESPValue {
+	return &RESPValue{Type: RESPBulkString, Str: s}
+}
+
+// NewRESPNullBulkString creates the null bulk string of RESP2, "$-1\r\n".
+func NewRESPNullBulkString() *RESPValue {
+	return &RESPValue{Type: RESPBulkString, Null: true}
+}
+
+// NewRESPArray creates an array.
+func NewRESPArray(values ...*RESPValue) *RESPValue {
+	return &RESPValue{Type: RESPArray, Array: values}
+}
+
+// NewRESPMap creates a RESP3 map with the keys and values in turn.
+func NewRESPMap(keyValues ...*RESPValue) *RESPValue {
+	if len(keyValues)%2 != 0 {
+		panic("RESP map needs the keys and values in pairs")
+	}
+	return &RESPValue{Type: RESPMap, Array: keyValues}
+}
+
+// NewRESPCommand creates a command sent by clients, an array of bulk strings.
+func NewRESPCommand(args ...string) *RESPValue {
+	values := make([]*RESPValue, len(args))
+	for i, arg := range args {
+		values[i] = NewRESPBulkString(arg)
+	}
+	return NewRESPArray(values...)
+}
+
+// Command returns the upper case command name and the arguments
+// if the value is a command, ok is false otherwise.
+func (v *RESPValue) Command() (name string, args []string, ok bool) {
+	if v.Type != RESPArray || v.Null || len(v.Array) == 0 {
+		return "", nil, false
+	}
+	for _, arg := range v.Array {
+		if arg.Type != RESPBulkString && arg.Type != RESPSimpleString || arg.Null {
+			return "", nil, false
+		}
+		args = append(args, arg.Str)
+	}
+	return strings.ToUpper(args[0]), args[1:], true
+}
+
+// ID implements message.Packet, returns the command name, so that DefaultMessageHandler
+// dispatches the commands to the processors registered by command name, such as "GET".
+func (v *RESPValue) ID() interface{} {
+	name, _, _ := v.Command()
+	return name
+}
+
+// Payload implements message.Packet, returns the value itself.
+func (v *RESPValue) Payload() interface{} {
+	return v
+}
+
+func (v *RESPValue) String() string {
+	switch {
+	case v.Null:
+		return "(nil)"
+	case v.Type == RESPInteger:
+		return fmt.Sprintf("(integer) %d", v.Int)
+	case v.Type == RESPBoolean:
+		return fmt.Sprintf("(boolean) %v", v.Bool)
+	case v.Type == RESPError || v.Type == RESPBulkError:
+		return "(error) " + v.Str
+	case v.Array != nil || v.Type == RESPArray || v.Type == RESPMap || v.Type == RESPSet || v.Type == RESPPush:
+		parts := make([]string, len(v.Array))
+		for i, e := range v.Array {
+			parts[i] = e.String()
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	default:
+		return fmt.Sprintf("%q", v.Str)
+	}
+}
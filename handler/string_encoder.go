@@ -21,6 +21,9 @@ func NewStringEncoder() *StringEncoder {
 	return sh
 }
 
+// Sharable implements core.Sharable.
+func (sc *StringEncoder) Sharable() {}
+
 func (sc *StringEncoder) OnRead(ctx *core.ChannelContext, msg interface{}) {
 	if buff, ok := msg.(bytes.ReadOnlyBuffer); ok {
 		bytes, err := buff.Read(0, buff.Len())
@@ -40,5 +43,7 @@ func (sc *StringEncoder) OnWrite(ctx *core.ChannelContext, msg interface{}) {
 		ctx.FireWrite(msg)
 	} else if str, ok := msg.(string); ok {
 		ctx.FireWrite([]byte(str))
+	} else {
+		ctx.FireWrite(msg)
 	}
 }
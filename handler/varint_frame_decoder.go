@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+)
+
+var (
+	ErrVarintOverflow = errors.New("varint length overflows 32-bit")
+)
+
+// VarintFrameDecoder splits the inbound bytes by the base 128 varint length prefix,
+// which is compatible with the length-delimited streams of protobuf(writeDelimitedTo).
+//
+//	+--------+---------------+         +---------------+
+//	| 0xAC02 |  300 bytes    |  ---->  |  300 bytes    |
+//	+--------+---------------+         +---------------+
+//
+// The varint split across reads is decoded when all bytes received. The frame exceeds
+// maxFrameLength is discarded with ErrFrameTooLong fired, the following frames are decoded normally.
+// netty-like ProtobufVarint32FrameDecoder
+type VarintFrameDecoder struct {
+	*ByteToMessageDecoder
+
+	maxFrameLength int
+	bytesToDiscard int
+}
+
+// NewVarintFrameDecoder creates a VarintFrameDecoder.
+func NewVarintFrameDecoder(maxFrameLength int) *VarintFrameDecoder {
+	if maxFrameLength <= 0 {
+		panic(fmt.Sprintf("VarintFrameDecoder invalid maxFrameLength: %d", maxFrameLength))
+	}
+
+	vd := &VarintFrameDecoder{maxFrameLength: maxFrameLength}
+	vd.ByteToMessageDecoder = NewByteToMessageDecoder(vd)
+	return vd
+}
+
+// Reset implements Resetter, stops discarding the too long frame.
+func (vd *VarintFrameDecoder) Reset() {
+	vd.bytesToDiscard = 0
+}
+
+// Decode implements Decoder, decodes one frame if the bytes are enough.
+func (vd *VarintFrameDecoder) Decode(ctx *core.ChannelContext, in bytes.ReadOnlyBuffer, out *[]interface{}) error {
+	if vd.bytesToDiscard > 0 {
+		n := vd.bytesToDiscard
+		if in.Len() < n {
+			n = in.Len()
+		}
+		in.Discard(n)
+		vd.bytesToDiscard -= n
+		return nil
+	}
+
+	length, size, err := readVarint32(in.Bytes())
+	if err != nil || size == 0 {
+		return err
+	}
+
+	if uint64(length) > uint64(vd.maxFrameLength) {
+		in.Discard(size)
+		vd.bytesToDiscard = int(length)
+		ctx.FireError(fmt.Errorf("%w: %v exceeds %v", ErrFrameTooLong, length, vd.maxFrameLength))
+		return nil
+	}
+
+	// wait for more bytes.
+	if in.Len() < size+int(length) {
+		return nil
+	}
+
+	frame, err := in.Read(size, int(length))
+	if err != nil {
+		return err
+	}
+	*out = append(*out, bytes.NewReadOnlyBufferWithBytes(frame))
+	return nil
+}
+
+// readVarint32 reads a varint up to 32-bit from buf, returns the size 0 if more bytes needed.
+func readVarint32(buf []byte) (uint32, int, error) {
+	var x uint32
+	for i := 0; i < binary.MaxVarintLen32; i++ {
+		if i >= len(buf) {
+			return 0, 0, nil
+		}
+		b := buf[i]
+		// the 5th byte holds the highest 4 bits only.
+		if i == binary.MaxVarintLen32-1 && b > 0x0f {
+			return 0, 0, ErrVarintOverflow
+		}
+		x |= uint32(b&0x7f) << (7 * uint(i))
+		if b < 0x80 {
+			return x, i + 1, nil
+		}
+	}
+	return 0, 0, ErrVarintOverflow
+}
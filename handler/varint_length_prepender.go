@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/amsalt/log"
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+)
+
+// VarintLengthPrepender prepends the base 128 varint length to the outbound message,
+// the opposite of VarintFrameDecoder.
+//
+//	+---------------+         +--------+---------------+
+//	|  300 bytes    |  ---->  | 0xAC02 |  300 bytes    |
+//	+---------------+         +--------+---------------+
+//
+// netty-like ProtobufVarint32LengthFieldPrepender
+type VarintLengthPrepender struct {
+	*core.DefaultOutboundHandler
+}
+
+// NewVarintLengthPrepender creates a VarintLengthPrepender.
+func NewVarintLengthPrepender() *VarintLengthPrepender {
+	return &VarintLengthPrepender{core.NewDefaultOutboundHandler()}
+}
+
+// Sharable implements core.Sharable, the length is prepended per message.
+func (vp *VarintLengthPrepender) Sharable() {}
+
+// OnWrite implements OutboundHandler, msg should be []byte or bytes.WriteOnlyBuffer.
+func (vp *VarintLengthPrepender) OnWrite(ctx *core.ChannelContext, msg interface{}) {
+	var buff bytes.WriteOnlyBuffer
+	switch data := msg.(type) {
+	case []byte:
+		buff = bytes.NewWriteOnlyBufferWithBytes(binary.MaxVarintLen32, data)
+	case bytes.WriteOnlyBuffer:
+		buff = data
+	default:
+		ctx.FailWrite(errors.New("VarintLengthPrepender msg should be []byte or bytes.WriteOnlyBuffer type"))
+		return
+	}
+
+	if uint64(buff.Len()) > math.MaxUint32 {
+		log.Errorf("VarintLengthPrepender.OnWrite failed: %+v", ErrFrameTooLong)
+		ctx.FailWrite(ErrFrameTooLong)
+		return
+	}
+
+	head := make([]byte, binary.MaxVarintLen32)
+	head = head[:binary.PutUvarint(head, uint64(buff.Len()))]
+	if _, err := buff.WriteHeader(head); err == bytes.ErrNoEnoughHeader {
+		// copy to a new buffer with the header space.
+		buff = bytes.NewWriteOnlyBufferWithBytes(uint(len(head)), buff.Bytes())
+		buff.WriteHeader(head)
+	}
+	ctx.FireWrite(buff)
+}
@@ -0,0 +1,127 @@
+package pool
+
+import (
+	"sync"
+
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/safe"
+)
+
+// KeyedExecutor executes the tasks with the same key(such as channel ID or player ID)
+// one by one in FIFO order, while the tasks with different keys run in parallel
+// on the workers of the Pool.
+//
+// KeyedExecutor implements core.ExecutorGroup, the handlers added with it run in the
+// order of the events per channel, keyed by the channel ID:
+//
+//	executor := pool.NewKeyedExecutor(nil)
+//	channel.Pipeline().AddLast(executor, "processor", handler.NewDefaultMessageHandler(processMgr))
+type KeyedExecutor struct {
+	pool *Pool
+
+	mutex  sync.Mutex
+	queues map[interface{}]*keyedQueue
+}
+
+// keyedQueue holds the pending tasks of a key, at most one worker drains it at a time.
+type keyedQueue struct {
+	tasks   []func()
+	running bool
+}
+
+// NewKeyedExecutor creates a KeyedExecutor runs tasks on p, nil means the default pool.
+func NewKeyedExecutor(p *Pool) *KeyedExecutor {
+	if p == nil {
+		p = defaultPool
+	}
+	return &KeyedExecutor{pool: p, queues: make(map[interface{}]*keyedQueue)}
+}
+
+// ExecuteKey executes the task after all tasks submitted before with the same key.
+func (ke *KeyedExecutor) ExecuteKey(key interface{}, task func()) {
+	if task == nil {
+		return
+	}
+
+	ke.mutex.Lock()
+	q, ok := ke.queues[key]
+	if !ok {
+		q = &keyedQueue{}
+		ke.queues[key] = q
+	}
+	q.tasks = append(q.tasks, task)
+	if q.running {
+		ke.mutex.Unlock()
+		return
+	}
+	q.running = true
+	ke.mutex.Unlock()
+
+	ke.pool.Execute(func() { ke.drain(key, q) })
+}
+
+// drain runs the tasks of the key until its queue is empty.
+func (ke *KeyedExecutor) drain(key interface{}, q *keyedQueue) {
+	for {
+		ke.mutex.Lock()
+		if len(q.tasks) == 0 {
+			q.running = false
+			delete(ke.queues, key)
+			ke.mutex.Unlock()
+			return
+		}
+		task := q.tasks[0]
+		q.tasks[0] = nil
+		q.tasks = q.tasks[1:]
+		ke.mutex.Unlock()
+
+		safe.Call(task)
+	}
+}
+
+// QueueDepth returns the number of tasks of the key waiting to be executed.
+func (ke *KeyedExecutor) QueueDepth(key interface{}) int {
+	ke.mutex.Lock()
+	defer ke.mutex.Unlock()
+
+	if q, ok := ke.queues[key]; ok {
+		return len(q.tasks)
+	}
+	return 0
+}
+
+// Bind returns an Executor which executes all tasks with key.
+func (ke *KeyedExecutor) Bind(key interface{}) *KeyBinding {
+	return &KeyBinding{executor: ke, key: key}
+}
+
+// Execute executes the task not bound to any key, without ordering.
+func (ke *KeyedExecutor) Execute(task func()) {
+	ke.pool.Execute(func() { safe.Call(task) })
+}
+
+// Next binds the channel by its ID, implements core.ExecutorGroup.
+func (ke *KeyedExecutor) Next(channel core.Channel) core.Executor {
+	return ke.Bind(channel.ID())
+}
+
+// KeyBinding is a core.Executor executes the tasks in order with the bound key.
+type KeyBinding struct {
+	executor *KeyedExecutor
+	key      interface{}
+}
+
+// Execute implements core.Executor.
+func (kb *KeyBinding) Execute(task func()) {
+	kb.executor.ExecuteKey(kb.key, task)
+}
+
+// Key returns the bound key.
+func (kb *KeyBinding) Key() interface{} {
+	return kb.key
+}
+
+// QueueDepth returns the number of tasks of the bound key waiting to be executed.
+func (kb *KeyBinding) QueueDepth() int {
+	return kb.executor.QueueDepth(kb.key)
+}
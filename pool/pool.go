@@ -153,7 +153,7 @@ func (p *Pool) applyW() *worker {
 		w = p.workers[freeWNum-1]
 		p.workers[freeWNum-1] = nil
 		p.workers = p.workers[:freeWNum-1]
-	} else if p.capacity > p.running { // pool not full.
+	} else if atomic.LoadInt32(&p.capacity) > atomic.LoadInt32(&p.running) { // pool not full.
 		w = p.startNewW()
 	} else { // waitting for free worker.
 		w = p.waitFreeW()
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/amsalt/nginet/bytes"
@@ -40,3 +41,20 @@ func TestReadOnlyBuffer(t *testing.T) {
 	buf, _ := rb.Seek(3)
 	log.Infof("ReadOnlyBuffer seek bytes: %+v", buf)
 }
+
+func TestReadOnlyBufferSpace(t *testing.T) {
+	rb := bytes.NewReadOnlyBuffer(4)
+	rb.ReadFrom(strings.NewReader("abcd"))
+	rb.Discard(4)
+	// the empty buffer is rewound instead of returning no space.
+	if n := len(rb.FreeBytes()); n != 4 {
+		t.Fatalf("free bytes of empty buffer got %d, want 4", n)
+	}
+
+	rb.ReadFrom(strings.NewReader("efgh"))
+	rb.Discard(2)
+	// the consumed region is compacted before grow.
+	if n := len(rb.FreeBytes()); n != 2 || string(rb.Bytes()) != "gh" {
+		t.Fatalf("free bytes after compacted got %d, bytes %q", n, rb.Bytes())
+	}
+}
@@ -0,0 +1,111 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/handler"
+)
+
+// frameCollector collects the frames decoded and the errors.
+type frameCollector struct {
+	*core.DefaultInboundHandler
+	frames []string
+	errs   []error
+}
+
+func (fc *frameCollector) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	buf := msg.(bytes.ReadOnlyBuffer)
+	fc.frames = append(fc.frames, string(buf.Bytes()))
+}
+
+func (fc *frameCollector) OnError(ctx *core.ChannelContext, err error) {
+	fc.errs = append(fc.errs, err)
+}
+
+func newFrameCollector(channel core.SubChannel, decoder interface{}) *frameCollector {
+	fc := &frameCollector{DefaultInboundHandler: core.NewDefaultInboundHandler()}
+	channel.Pipeline().AddLast(nil, "decoder", decoder)
+	channel.Pipeline().AddLast(nil, "collector", fc)
+	return fc
+}
+
+// fireBytes fires each chunk as the bytes of a read.
+func fireBytes(channel core.SubChannel, chunks ...string) {
+	for _, chunk := range chunks {
+		channel.Pipeline().FireRead(bytes.NewReadOnlyBufferWithBytes([]byte(chunk)))
+	}
+}
+
+func TestPacketLengthDecoderCumulation(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+	fc := newFrameCollector(channel, handler.NewPacketLengthDecoder(2))
+
+	// "\x00\x05abc" + "\x00\x04de" split across reads, then two frames in one read.
+	fireBytes(channel, "\x00", "\x05a", "bc\x00\x04d", "e\x00\x03f\x00\x03g")
+	want := []string{"abc", "de", "f", "g"}
+	if len(fc.errs) != 0 || len(fc.frames) != len(want) {
+		t.Fatalf("frames got %q, errs %v", fc.frames, fc.errs)
+	}
+	for i, frame := range want {
+		if fc.frames[i] != frame {
+			t.Fatalf("frame %d got %q, want %q", i, fc.frames[i], frame)
+		}
+	}
+}
+
+func TestByteToMessageDecoderMaxCumulation(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+	decoder := handler.NewPacketLengthDecoder(2)
+	decoder.SetMaxCumulation(8)
+	fc := newFrameCollector(channel, decoder)
+
+	fireBytes(channel, "\x00\x20abcd", "efgh")
+	if len(fc.errs) != 1 || decoder.Cumulated() != 0 {
+		t.Fatalf("cumulation exceeded should fire an error and discard, errs %v, cumulated %d", fc.errs, decoder.Cumulated())
+	}
+
+	// decodes the following frames normally.
+	fireBytes(channel, "\x00\x03a")
+	if len(fc.frames) != 1 || fc.frames[0] != "a" {
+		t.Fatalf("frames got %q", fc.frames)
+	}
+}
+
+func TestByteToMessageDecoderReconnect(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+	decoder := handler.NewPacketLengthDecoder(2).SetMaxFrameLength(8)
+	fc := newFrameCollector(channel, decoder)
+
+	// the frame split across the reconnect is dropped.
+	fireBytes(channel, "\x00\x05ab")
+	channel.Pipeline().FireEvent(core.ReconnectedEvent{Attempts: 1})
+	fireBytes(channel, "\x00\x03c")
+	if decoder.Cumulated() != 0 || len(fc.frames) != 1 || fc.frames[0] != "c" {
+		t.Fatalf("frames got %q, cumulated %d", fc.frames, decoder.Cumulated())
+	}
+
+	// so is the too long frame being discarded.
+	fireBytes(channel, "\x00\x20abcd")
+	channel.Pipeline().FireEvent(core.ReconnectedEvent{Attempts: 1})
+	fireBytes(channel, "\x00\x03d")
+	if len(fc.errs) != 1 || len(fc.frames) != 2 || fc.frames[1] != "d" {
+		t.Fatalf("frames got %q, errs %v", fc.frames, fc.errs)
+	}
+}
+
+func TestByteToMessageDecoderWrapError(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+	fc := newFrameCollector(channel, handler.NewVarintFrameDecoder(1024))
+
+	fireBytes(channel, "\xff\xff\xff\xff\xff\x01")
+	if len(fc.errs) != 1 || !errors.Is(fc.errs[0], handler.ErrVarintOverflow) {
+		t.Fatalf("errs got %v, want %v", fc.errs, handler.ErrVarintOverflow)
+	}
+}
@@ -0,0 +1,77 @@
+package test
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/handler"
+)
+
+// compressFrames writes the frames through a CompressionHandler, returns the bytes written.
+func compressFrames(compression *handler.CompressionHandler, frames ...interface{}) []string {
+	conn := &batchConn{
+		gateConn: gateConn{release: make(chan byte), closed: make(chan byte)},
+		calls:    make(chan string, len(frames)),
+	}
+	close(conn.release)
+	channel := core.NewSubChannel(conn, &core.SubChannelOpts{})
+	defer channel.Close()
+	channel.Pipeline().AddLast(nil, "compression", compression)
+
+	var written []string
+	for _, frame := range frames {
+		channel.Pipeline().FireWrite(frame)
+		written = append(written, <-conn.calls)
+	}
+	return written
+}
+
+func TestCompressionHandler(t *testing.T) {
+	for _, compressor := range []handler.Compressor{
+		handler.NewDeflateCompressor(flate.BestSpeed),
+		handler.NewGzipCompressor(gzip.DefaultCompression),
+	} {
+		compression := handler.NewCompressionHandler(compressor).SetThreshold(64)
+		large := strings.Repeat("state snapshot ", 100)
+		written := compressFrames(compression, []byte("small"), []byte(large),
+			bytes.NewWriteOnlyBufferWithBytes(0, []byte(large)), []byte("x"))
+
+		if written[0] != "\x00small" || written[3] != "\x00x" {
+			t.Fatalf("small frames should be uncompressed, got %q %q", written[0], written[3])
+		}
+		for _, w := range written[1:3] {
+			if w[0] != handler.FlagCompressed || len(w) >= len(large) {
+				t.Fatalf("large frame should be compressed, got %d bytes", len(w))
+			}
+		}
+
+		channel := newTestChannel()
+		fc := newFrameCollector(channel, compression)
+		fireBytes(channel, written...)
+		channel.Close()
+		want := []string{"small", large, large, "x"}
+		if len(fc.errs) != 0 || !reflect.DeepEqual(fc.frames, want) {
+			t.Fatalf("decompressed got %d frames, errs %v", len(fc.frames), fc.errs)
+		}
+	}
+}
+
+func TestCompressionHandlerMaxInflatedSize(t *testing.T) {
+	compression := handler.NewCompressionHandler(handler.NewDeflateCompressor(flate.BestCompression))
+	// 1MB of zeros deflates to about 1KB.
+	bomb := compressFrames(compression, make([]byte, 1024*1024))[0]
+
+	channel := newTestChannel()
+	defer channel.Close()
+	compression.SetMaxInflatedSize(64 * 1024)
+	fc := newFrameCollector(channel, compression)
+	fireBytes(channel, bomb, "\x02abc", "\x00ok")
+	if len(fc.errs) != 2 || !reflect.DeepEqual(fc.frames, []string{"ok"}) {
+		t.Fatalf("frames got %d, errs %v", len(fc.frames), fc.errs)
+	}
+}
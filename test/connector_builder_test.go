@@ -0,0 +1,83 @@
+package test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/core/local"
+	_ "github.com/amsalt/nginet/core/rudp"
+	"github.com/amsalt/nginet/core/tcp"
+	_ "github.com/amsalt/nginet/core/udp"
+	_ "github.com/amsalt/nginet/core/unix"
+	_ "github.com/amsalt/nginet/core/ws"
+)
+
+func TestConnectorBuilder(t *testing.T) {
+	for _, name := range []string{core.TCPCliBuilder, core.WebsocketCliBuilder, core.UDPCliBuilder,
+		core.RUDPCliBuilder, core.UnixCliBuilder, core.LocalCliBuilder} {
+		if core.GetConnectorBuilder(name) == nil {
+			t.Fatalf("connector builder %+v not registered", name)
+		}
+	}
+
+	s := core.GetAcceptorBuilder(core.TCPServBuilder).Build(tcp.WithReadBufSize(4096))
+	s.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "streamHandler", newStreamHandler(true))
+	})
+	addr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:7883")
+	if err != nil {
+		panic("bad net addr")
+	}
+	s.Listen(addr)
+	go s.Accept()
+	defer s.Close()
+
+	laddr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:0")
+	clientHandler := newStreamHandler(false)
+	c := core.GetConnectorBuilder(core.TCPCliBuilder).Build(
+		tcp.WithReadBufSize(4096),
+		tcp.WithWriteBufSize(16),
+		tcp.WithDialTimeout(time.Second),
+		tcp.WithLocalAddr(laddr),
+		tcp.WithNodelay(true),
+		tcp.WithKeepalive(true),
+	)
+	c.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "streamHandler", clientHandler)
+	})
+	channel, err := c.Connect(addr)
+	if err != nil {
+		t.Fatalf("tcp connect failed: %+v", err)
+	}
+	defer c.Close()
+
+	if bound, ok := channel.LocalAddr().(*net.TCPAddr); !ok || !bound.IP.Equal(laddr.IP) {
+		t.Fatalf("tcp client should bind %+v, got %+v", laddr, channel.LocalAddr())
+	}
+
+	channel.Write([]byte("hello"))
+	select {
+	case got := <-clientHandler.received:
+		if string(got) != "hello" {
+			t.Fatalf("tcp echo got %q", got)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("tcp echo timeout")
+	}
+
+	// the transport can be chosen by name for both sides.
+	ls := core.GetAcceptorBuilder(core.LocalServBuilder).Build(local.WithReadBufSize(128))
+	ls.InitSubChannel(func(channel core.SubChannel) {})
+	ls.Listen(local.Addr("connector_builder"))
+	go ls.Accept()
+	defer ls.Close()
+
+	lc := core.GetConnectorBuilder(core.LocalCliBuilder).Build(local.WithReadBufSize(128))
+	lc.InitSubChannel(func(channel core.SubChannel) {})
+	if _, err := lc.Connect(local.Addr("connector_builder")); err != nil {
+		t.Fatalf("local connect failed: %+v", err)
+	}
+	lc.Close()
+}
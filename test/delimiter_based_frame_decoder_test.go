@@ -0,0 +1,52 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/amsalt/nginet/handler"
+)
+
+func TestLineBasedFrameDecoder(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+	fc := newFrameCollector(channel, handler.NewLineBasedFrameDecoder(8))
+
+	// lines split across reads, several lines in one read, and "\r\n" split.
+	fireBytes(channel, "he", "llo\nwor", "ld\r", "\n\nbye\n")
+	want := []string{"hello", "world", "", "bye"}
+	if len(fc.errs) != 0 || !reflect.DeepEqual(fc.frames, want) {
+		t.Fatalf("lines got %q, errs %v", fc.frames, fc.errs)
+	}
+}
+
+func TestLineBasedFrameDecoderTooLong(t *testing.T) {
+	for _, failFast := range []bool{true, false} {
+		channel := newTestChannel()
+		fc := newFrameCollector(channel, handler.NewLineBasedFrameDecoder(4).SetFailFast(failFast).SetStripDelimiter(false))
+
+		fireBytes(channel, "abcdef")
+		if failFast != (len(fc.errs) == 1) {
+			t.Fatalf("failFast %v, errors fired before discarded: %v", failFast, fc.errs)
+		}
+		fireBytes(channel, "gh\r\nok\r\n")
+		if len(fc.errs) != 1 || !reflect.DeepEqual(fc.frames, []string{"ok\r\n"}) {
+			t.Fatalf("failFast %v, lines got %q, errs %v", failFast, fc.frames, fc.errs)
+		}
+		channel.Close()
+	}
+}
+
+func TestDelimiterBasedFrameDecoder(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+	decoder := handler.NewDelimiterBasedFrameDecoder(16, []byte("||"), []byte("|"))
+	fc := newFrameCollector(channel, decoder)
+
+	// the delimiter produces the shortest frame is used.
+	fireBytes(channel, "a||b|", "c|", "|toolongtoolongtoolong||d||")
+	want := []string{"a", "b", "c", "", "d"}
+	if len(fc.errs) != 1 || !reflect.DeepEqual(fc.frames, want) {
+		t.Fatalf("frames got %q, errs %v", fc.frames, fc.errs)
+	}
+}
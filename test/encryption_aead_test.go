@@ -0,0 +1,292 @@
+package test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/aio"
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/core/tcp"
+	"github.com/amsalt/nginet/handler"
+)
+
+// aeadPeer is a channel with an AEADCipher, the frames written are recorded by the conn.
+type aeadPeer struct {
+	channel core.SubChannel
+	conn    *batchConn
+	events  []interface{}
+	frames  []string
+	errs    []error
+}
+
+func (ap *aeadPeer) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	ap.frames = append(ap.frames, string(msg.(bytes.ReadOnlyBuffer).Bytes()))
+}
+
+func (ap *aeadPeer) OnEvent(ctx *core.ChannelContext, event interface{}) {
+	ap.events = append(ap.events, event)
+}
+
+func (ap *aeadPeer) OnError(ctx *core.ChannelContext, err error) {
+	ap.errs = append(ap.errs, err)
+}
+
+func (ap *aeadPeer) OnConnect(ctx *core.ChannelContext, channel core.Channel) {}
+func (ap *aeadPeer) OnDisconnect(ctx *core.ChannelContext)                    {}
+
+func newAEADPeer(cipher *handler.AEADCipher) *aeadPeer {
+	conn := &batchConn{
+		gateConn: gateConn{release: make(chan byte), closed: make(chan byte)},
+		calls:    make(chan string, 16),
+	}
+	close(conn.release)
+	ap := &aeadPeer{channel: core.NewSubChannel(conn, &core.SubChannelOpts{WriteBufSize: 16}), conn: conn}
+	ap.channel.Pipeline().AddLast(nil, "cipher", cipher)
+	ap.channel.Pipeline().AddLast(nil, "peer", ap)
+	return ap
+}
+
+// closed reports whether the channel closed.
+func (ap *aeadPeer) closed() bool {
+	select {
+	case <-ap.conn.closed:
+		return true
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
+func (ap *aeadPeer) written(t *testing.T) string {
+	t.Helper()
+	select {
+	case frame := <-ap.conn.calls:
+		return frame
+	case <-time.After(time.Second):
+		t.Fatalf("no frame written")
+		return ""
+	}
+}
+
+// handshakeAEAD runs the handshake between a and b.
+func handshakeAEAD(t *testing.T, a, b *aeadPeer) {
+	t.Helper()
+	a.channel.Pipeline().FireConnect(a.channel)
+	b.channel.Pipeline().FireConnect(b.channel)
+	fireBytes(b.channel, a.written(t))
+	fireBytes(a.channel, b.written(t))
+	for _, p := range []*aeadPeer{a, b} {
+		if len(p.errs) != 0 || len(p.events) != 1 {
+			t.Fatalf("handshake got events %v, errs %v", p.events, p.errs)
+		}
+	}
+}
+
+func TestAEADCipher(t *testing.T) {
+	for _, suite := range []handler.AEADSuite{handler.AES256GCM, handler.ChaCha20Poly1305} {
+		a := newAEADPeer(handler.NewAEADCipher(suite).SetRekeyInterval(2))
+		b := newAEADPeer(handler.NewAEADCipher(suite).SetRekeyInterval(2))
+		handshakeAEAD(t, a, b)
+		if ev, ok := a.events[0].(handler.HandshakeCompleteEvent); !ok || ev.Suite != suite {
+			t.Fatalf("handshake event got %v", a.events[0])
+		}
+
+		// the key rotated every 2 frames.
+		want := []string{"hello", "", "state", "snapshot", "bye"}
+		var sent []string
+		for _, msg := range want {
+			a.channel.Write([]byte(msg))
+			frame := a.written(t)
+			if len(frame) == 0 || frame[1:] == msg {
+				t.Fatalf("frame not encrypted: %q", frame)
+			}
+			sent = append(sent, frame)
+		}
+		if sent[1][1:5] != sent[0][1:5] || sent[2][1:5] == sent[0][1:5] {
+			t.Fatalf("key should be rotated after 2 frames")
+		}
+		fireBytes(b.channel, sent...)
+		if len(b.errs) != 0 || len(b.frames) != len(want) {
+			t.Fatalf("frames got %q, errs %v", b.frames, b.errs)
+		}
+		for i := range want {
+			if b.frames[i] != want[i] {
+				t.Fatalf("frame %d got %q, want %q", i, b.frames[i], want[i])
+			}
+		}
+
+		// the frame replayed is rejected and the channel closed.
+		fireBytes(b.channel, sent[4])
+		if len(b.errs) != 1 || !b.closed() {
+			t.Fatalf("replayed frame errs %v, closed %v", b.errs, b.closed())
+		}
+		a.channel.Close()
+	}
+}
+
+func TestAEADCipherTampered(t *testing.T) {
+	a := newAEADPeer(handler.NewAEADCipher(handler.AES256GCM))
+	b := newAEADPeer(handler.NewAEADCipher(handler.AES256GCM))
+	defer a.channel.Close()
+	handshakeAEAD(t, a, b)
+
+	a.channel.Write([]byte("transfer 100"))
+	frame := []byte(a.written(t))
+	frame[len(frame)-1] ^= 1
+	fireBytes(b.channel, string(frame))
+	if len(b.errs) != 1 || len(b.frames) != 0 || !b.closed() {
+		t.Fatalf("tampered frame got %q, errs %v", b.frames, b.errs)
+	}
+}
+
+func TestAEADCipherPreSharedKey(t *testing.T) {
+	a := newAEADPeer(handler.NewAEADCipher(handler.ChaCha20Poly1305).SetPreSharedKey([]byte("secret")))
+	b := newAEADPeer(handler.NewAEADCipher(handler.ChaCha20Poly1305).SetPreSharedKey([]byte("guess")))
+	defer a.channel.Close()
+
+	handshakeAEAD(t, a, b)
+
+	// the frames can't be authenticated with the different keys.
+	a.channel.Write([]byte("hello"))
+	fireBytes(b.channel, a.written(t))
+	if len(b.errs) != 1 || len(b.frames) != 0 || !b.closed() {
+		t.Fatalf("different pre-shared keys got %q, errs %v", b.frames, b.errs)
+	}
+}
+
+func TestAEADCipherTCP(t *testing.T) {
+	s := core.GetAcceptorBuilder(core.TCPServBuilder).Build()
+	s.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "PacketLengthDecoder", handler.NewPacketLengthDecoder(2))
+		channel.Pipeline().AddLast(nil, "PacketLengthPrepender", handler.NewPacketLengthPrepender(2))
+		channel.Pipeline().AddLast(nil, "AEADCipher", handler.NewAEADCipher(handler.AES256GCM))
+		channel.Pipeline().AddLast(nil, "echo", &echoHandler{core.NewDefaultInboundHandler()})
+	})
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:7894")
+	s.Listen(addr)
+	go s.Accept()
+	defer s.Close()
+
+	received := make(chan interface{}, 4)
+	c := core.GetConnectorBuilder(core.TCPCliBuilder).Build()
+	c.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "PacketLengthDecoder", handler.NewPacketLengthDecoder(2))
+		channel.Pipeline().AddLast(nil, "PacketLengthPrepender", handler.NewPacketLengthPrepender(2))
+		channel.Pipeline().AddLast(nil, "AEADCipher", handler.NewAEADCipher(handler.AES256GCM))
+		channel.Pipeline().AddLast(nil, "collector", &chanCollector{core.NewDefaultInboundHandler(), received})
+	})
+	if _, err := c.Connect(addr); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	defer c.Close()
+
+	// written before the handshake completed, sent after.
+	c.Write([]byte("ping"))
+	for _, want := range []interface{}{handler.HandshakeCompleteEvent{Suite: handler.AES256GCM}, "ping"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("received %v, want %v", got, want)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("%v not received", want)
+		}
+	}
+}
+
+func TestAEADCipherReconnect(t *testing.T) {
+	serverChannels := make(chan core.SubChannel, 4)
+	s := core.GetAcceptorBuilder(core.TCPServBuilder).Build()
+	s.InitSubChannel(func(channel core.SubChannel) {
+		serverChannels <- channel
+		channel.Pipeline().AddLast(nil, "PacketLengthDecoder", handler.NewPacketLengthDecoder(2))
+		channel.Pipeline().AddLast(nil, "PacketLengthPrepender", handler.NewPacketLengthPrepender(2))
+		channel.Pipeline().AddLast(nil, "AEADCipher", handler.NewAEADCipher(handler.AES256GCM))
+		channel.Pipeline().AddLast(nil, "echo", &echoHandler{core.NewDefaultInboundHandler()})
+	})
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:7895")
+	s.Listen(addr)
+	go s.Accept()
+	defer s.Close()
+
+	// the cipher runs in an event loop, the writes before the handshake must not block it.
+	evtloop := aio.NewEventLoop()
+	evtloop.Start()
+	received := make(chan interface{}, 16)
+	c := core.GetConnectorBuilder(core.TCPCliBuilder).Build(
+		tcp.WithAutoReconnect(true),
+		tcp.WithReconnectPolicy(newTestBackOff()),
+		tcp.WithDisconnectedWrite(&core.DisconnectedWriteOpts{Policy: core.BufferWrites, ManualReplay: true}),
+	)
+	c.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "PacketLengthDecoder", handler.NewPacketLengthDecoder(2))
+		channel.Pipeline().AddLast(nil, "PacketLengthPrepender", handler.NewPacketLengthPrepender(2))
+		channel.Pipeline().AddLast(evtloop, "AEADCipher", handler.NewAEADCipher(handler.AES256GCM))
+		channel.Pipeline().AddLast(evtloop, "collector", &chanCollector{core.NewDefaultInboundHandler(), received})
+	})
+	channel, err := c.Connect(addr)
+	if err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	defer c.Close()
+
+	waitAEAD := func(want interface{}) {
+		t.Helper()
+		for {
+			select {
+			case got := <-received:
+				if _, ok := got.(handler.HandshakeCompleteEvent); ok {
+					// replay the messages buffered while reconnecting with the new keys.
+					channel.(core.Replayer).Replay()
+				}
+				if got == want {
+					return
+				}
+				if _, ok := got.(string); ok {
+					t.Fatalf("received %v, want %v", got, want)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatalf("%v not received", want)
+			}
+		}
+	}
+
+	for _, msg := range []string{"a", "b", "c"} {
+		c.Write([]byte(msg))
+	}
+	waitAEAD(handler.HandshakeCompleteEvent{Suite: handler.AES256GCM})
+	for _, msg := range []string{"a", "b", "c"} {
+		waitAEAD(msg)
+	}
+
+	// the server drops the connection, the client handshakes again after reconnected.
+	(<-serverChannels).Close()
+	waitAEAD(core.ReconnectedEvent{Attempts: 1})
+	c.Write([]byte("again"))
+	waitAEAD(handler.HandshakeCompleteEvent{Suite: handler.AES256GCM})
+	waitAEAD("again")
+}
+
+// echoHandler writes back the bytes read.
+type echoHandler struct {
+	*core.DefaultInboundHandler
+}
+
+func (eh *echoHandler) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	ctx.Write(append([]byte(nil), msg.(bytes.ReadOnlyBuffer).Bytes()...))
+}
+
+// chanCollector sends the strings read and the events to a chan.
+type chanCollector struct {
+	*core.DefaultInboundHandler
+	received chan interface{}
+}
+
+func (cc *chanCollector) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	cc.received <- string(msg.(bytes.ReadOnlyBuffer).Bytes())
+}
+
+func (cc *chanCollector) OnEvent(ctx *core.ChannelContext, event interface{}) {
+	cc.received <- event
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/aio"
+	"github.com/amsalt/nginet/core"
+)
+
+// orderHandler forwards the messages read to out.
+type orderHandler struct {
+	*core.DefaultInboundHandler
+	out chan interface{}
+}
+
+func (oh *orderHandler) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	oh.out <- msg
+}
+
+func TestEventLoopGroup(t *testing.T) {
+	group := aio.NewEventLoopGroup(3)
+	group.Start()
+	defer group.Stop()
+
+	var channels []core.SubChannel
+	var outs []chan interface{}
+	for i := 0; i < 6; i++ {
+		channel := newTestChannel()
+		defer channel.Close()
+		out := make(chan interface{}, 100)
+		channel.Pipeline().AddLast(group, "decoder", core.NewDefaultInboundHandler())
+		channel.Pipeline().AddLast(group, "order", &orderHandler{core.NewDefaultInboundHandler(), out})
+
+		cp := channel.Pipeline()
+		if cp.Context("decoder").Executor() != cp.Context("order").Executor() {
+			t.Fatalf("handlers of channel %d run on different loops", i)
+		}
+		if cp.Context("order").Executor() != group.Loop(i%3) {
+			t.Fatalf("channel %d should be assigned to loop %d by round robin", i, i%3)
+		}
+		channels = append(channels, channel)
+		outs = append(outs, out)
+	}
+
+	for i := 0; i < 100; i++ {
+		for _, channel := range channels {
+			channel.Pipeline().FireRead(i)
+		}
+	}
+	for n, out := range outs {
+		for i := 0; i < 100; i++ {
+			select {
+			case msg := <-out:
+				if msg != i {
+					t.Fatalf("channel %d read %v, want %d", n, msg, i)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatalf("channel %d wait message %d timeout", n, i)
+			}
+		}
+	}
+}
+
+func TestEventLoopGroupHashChooser(t *testing.T) {
+	group := aio.NewEventLoopGroup(4).SetChooser(aio.HashChooser)
+	channel := newTestChannel()
+	defer channel.Close()
+
+	loop := group.Next(channel)
+	for i := 0; i < 10; i++ {
+		if group.Next(channel) != loop {
+			t.Fatalf("HashChooser should always choose the same loop for a channel")
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package test
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/handler"
+	"github.com/amsalt/nginet/message"
+)
+
+// requestCollector collects the requests decoded.
+type requestCollector struct {
+	*core.DefaultInboundHandler
+	requests []*handler.HTTPRequest
+}
+
+func (rc *requestCollector) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	rc.requests = append(rc.requests, msg.(*handler.HTTPRequest))
+}
+
+func TestHTTPRequestDecoder(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+	rc := &requestCollector{DefaultInboundHandler: core.NewDefaultInboundHandler()}
+	channel.Pipeline().AddLast(nil, "decoder", handler.NewHTTPRequestDecoder())
+	channel.Pipeline().AddLast(nil, "collector", rc)
+
+	stream := "GET /health?full=1 HTTP/1.1\r\nHost: a\r\n\r\n" +
+		"POST /echo HTTP/1.1\r\nContent-Length: 5\r\n\r\nhello" +
+		"POST /echo HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n3;ext=1\r\nabc\r\n2\r\nde\r\n0\r\nX-Sum: 5\r\n\r\n" +
+		"GET / HTTP/1.0\r\n\r\n"
+	// split into small pieces.
+	var chunks []string
+	for i := 0; i < len(stream); i += 7 {
+		end := i + 7
+		if end > len(stream) {
+			end = len(stream)
+		}
+		chunks = append(chunks, stream[i:end])
+	}
+	fireBytes(channel, chunks...)
+
+	if len(rc.requests) != 4 {
+		t.Fatalf("requests got %d, want 4", len(rc.requests))
+	}
+	get, post, chunked, old := rc.requests[0], rc.requests[1], rc.requests[2], rc.requests[3]
+	if get.Method != "GET" || get.ID() != "/health" || get.URL.Query().Get("full") != "1" || !get.KeepAlive() {
+		t.Fatalf("bad GET request: %+v", get)
+	}
+	if string(post.Body) != "hello" {
+		t.Fatalf("content-length body got %q", post.Body)
+	}
+	if string(chunked.Body) != "abcde" || chunked.Trailer.Get("X-Sum") != "5" {
+		t.Fatalf("chunked body got %q, trailer %v", chunked.Body, chunked.Trailer)
+	}
+	if old.Proto != "HTTP/1.0" || old.KeepAlive() {
+		t.Fatalf("HTTP/1.0 request should not keep alive: %+v", old)
+	}
+}
+
+func TestHTTPRequestDecoderContentLength(t *testing.T) {
+	requests := []string{
+		"POST / HTTP/1.1\r\nContent-Length: 1\r\nContent-Length: 2\r\n\r\nab",
+		"POST / HTTP/1.1\r\nContent-Length: 1, 2\r\n\r\nab",
+		"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\nContent-Length: 3\r\n\r\n0\r\n\r\n",
+		"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\nTransfer-Encoding: identity\r\n\r\n0\r\n\r\n",
+		"POST / HTTP/1.1\r\nContent-Length: +2\r\n\r\nab",
+		"POST / HTTP/1.1\r\nContent-Length: -0\r\n\r\n",
+		// the same values repeated are accepted.
+		"POST / HTTP/1.1\r\nContent-Length: 2\r\nContent-Length: 2, 2\r\n\r\nab",
+	}
+	for i, request := range requests {
+		channel := newTestChannel()
+		rc := &requestCollector{DefaultInboundHandler: core.NewDefaultInboundHandler()}
+		eh := &errorHandler{core.NewDefaultInboundHandler(), make(chan error, 1)}
+		channel.Pipeline().AddLast(nil, "decoder", handler.NewHTTPRequestDecoder())
+		channel.Pipeline().AddLast(nil, "collector", rc)
+		channel.Pipeline().AddLast(nil, "errors", eh)
+		fireBytes(channel, request)
+		channel.Close()
+
+		if accepted := i == len(requests)-1; accepted != (len(rc.requests) == 1) || accepted != (len(eh.errs) == 0) {
+			t.Fatalf("request %q accepted %v, got requests %v, errors %v", request, accepted, len(rc.requests), len(eh.errs))
+		}
+	}
+}
+
+func TestHTTPServer(t *testing.T) {
+	processMgr := message.NewProcessorMgr(message.NewRegister())
+	processMgr.RegisterProcessorByID("/health", func(ctx *core.ChannelContext, msg interface{}, args ...interface{}) {
+		ctx.Write(msg.(*handler.HTTPRequest).NewResponse(http.StatusOK, []byte("ok")))
+	})
+	processMgr.RegisterProcessorByID("/echo", func(ctx *core.ChannelContext, msg interface{}, args ...interface{}) {
+		req := msg.(*handler.HTTPRequest)
+		resp := req.NewResponse(http.StatusOK, req.Body)
+		resp.Chunked = true
+		ctx.Write(resp)
+	})
+
+	s := core.GetAcceptorBuilder(core.TCPServBuilder).Build()
+	s.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "HTTPResponseEncoder", handler.NewHTTPResponseEncoder())
+		channel.Pipeline().AddLast(nil, "HTTPRequestDecoder", handler.NewHTTPRequestDecoder())
+		channel.Pipeline().AddLast(nil, "processor", handler.NewDefaultMessageHandler(processMgr))
+	})
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:7893")
+	s.Listen(addr)
+	go s.Accept()
+	defer s.Close()
+
+	client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 1}}
+	defer client.Transport.(*http.Transport).CloseIdleConnections()
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get("http://127.0.0.1:7893/health")
+		if err != nil {
+			t.Fatalf("GET failed: %+v", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+			t.Fatalf("GET got %d %q", resp.StatusCode, body)
+		}
+	}
+
+	// the body of unknown length is sent chunked.
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("hello "))
+		pw.Write([]byte("nginet"))
+		pw.Close()
+	}()
+	resp, err := client.Post("http://127.0.0.1:7893/echo", "text/plain", pr)
+	if err != nil {
+		t.Fatalf("POST failed: %+v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello nginet" || len(resp.TransferEncoding) == 0 {
+		t.Fatalf("POST got %q, transfer encoding %v", body, resp.TransferEncoding)
+	}
+
+	// the bad request is responded and closed.
+	conn, err := net.Dial("tcp", "127.0.0.1:7893")
+	if err != nil {
+		t.Fatalf("dial failed: %+v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("BAD\r\n\r\n"))
+	resp, err = http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil || resp.StatusCode != http.StatusBadRequest || !resp.Close {
+		t.Fatalf("bad request got %+v, err %+v", resp, err)
+	}
+	// the connection is closed after the response.
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := ioutil.ReadAll(conn); err != nil {
+		t.Fatalf("connection should be closed, err: %+v", err)
+	}
+}
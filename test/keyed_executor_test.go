@@ -0,0 +1,54 @@
+package test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/pool"
+)
+
+func TestKeyedExecutor(t *testing.T) {
+	executor := pool.NewKeyedExecutor(nil)
+
+	// the blocked key doesn't block the others.
+	block := make(chan byte)
+	blocked := executor.Bind("blocked")
+	blocked.Execute(func() { <-block })
+	for i := 0; i < 3; i++ {
+		blocked.Execute(func() {})
+	}
+
+	var wg sync.WaitGroup
+	results := make([][]int, 4)
+	for i := 0; i < 200; i++ {
+		for key := range results {
+			key, i := key, i
+			wg.Add(1)
+			executor.ExecuteKey(key, func() {
+				results[key] = append(results[key], i)
+				wg.Done()
+			})
+		}
+	}
+	wg.Wait()
+	for key, result := range results {
+		for i, v := range result {
+			if v != i {
+				t.Fatalf("key %d executed task %d at %d", key, v, i)
+			}
+		}
+	}
+
+	if depth := blocked.QueueDepth(); depth != 3 {
+		t.Fatalf("QueueDepth got %d, want 3", depth)
+	}
+	close(block)
+	deadline := time.Now().Add(3 * time.Second)
+	for executor.QueueDepth("blocked") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("wait blocked key drained timeout")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/handler"
+)
+
+type lifecycleHandler struct {
+	*core.DefaultInboundHandler
+	calls *[]string
+}
+
+func (lh *lifecycleHandler) HandlerAdded(ctx *core.ChannelContext) {
+	*lh.calls = append(*lh.calls, "added:"+ctx.Name())
+}
+
+func (lh *lifecycleHandler) HandlerRemoved(ctx *core.ChannelContext) {
+	*lh.calls = append(*lh.calls, "removed:"+ctx.Name())
+}
+
+func TestHandlerLifecycle(t *testing.T) {
+	channel := core.NewSubChannel(&gateConn{release: make(chan byte), closed: make(chan byte)}, &core.SubChannelOpts{})
+	cp := channel.Pipeline()
+
+	var calls []string
+	newHandler := func() *lifecycleHandler {
+		return &lifecycleHandler{core.NewDefaultInboundHandler(), &calls}
+	}
+	cp.AddLast(nil, "a", newHandler())
+	cp.AddLast(nil, "b", newHandler())
+	cp.AddAfter("none", nil, "ignored", newHandler())
+	cp.AddBefore("b", nil, "c", newHandler())
+	cp.Replace("a", nil, "d", newHandler())
+	cp.Remove("c")
+	cp.AddLast(nil, "idle", handler.NewIdleStateHandler(60, 60, false))
+
+	channel.Close()
+	want := []string{
+		"added:a", "added:b", "added:c",
+		"added:d", "removed:a",
+		"removed:c",
+		// removed from tail to head when closed.
+		"removed:b", "removed:d",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("lifecycle calls got %v, want %v", calls, want)
+	}
+	if names := cp.Names(); len(names) != 0 {
+		t.Fatalf("all handlers should be removed after closed, got %v", names)
+	}
+}
+
+func TestIdleStateHandlerReAdded(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+
+	events := make(chan interface{}, 16)
+	idle := handler.NewIdleStateHandler(1, 60, false)
+	cp := channel.Pipeline()
+	cp.AddLast(nil, "idle", idle)
+	cp.AddLast(nil, "collector", &chanCollector{core.NewDefaultInboundHandler(), events})
+
+	// the removed handler fires again after added back.
+	cp.Remove("idle")
+	cp.AddFirst(nil, "idle", idle)
+	select {
+	case event := <-events:
+		if e, ok := event.(*handler.IdleEvent); !ok || e.TimeoutType != handler.ReadTimeout {
+			t.Fatalf("expect the read IdleEvent, got %+v", event)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("the re-added IdleStateHandler should fire IdleEvent")
+	}
+}
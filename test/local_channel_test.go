@@ -0,0 +1,64 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/core/local"
+)
+
+func TestLocalChannel(t *testing.T) {
+	addr := local.Addr("echo")
+
+	s := core.GetAcceptorBuilder(core.LocalServBuilder).Build(local.WithMaxConnNum(10))
+	serverChannels := make(chan core.SubChannel, 1)
+	s.InitSubChannel(func(channel core.SubChannel) {
+		serverChannels <- channel
+		channel.Pipeline().AddLast(nil, "streamHandler", newStreamHandler(true))
+	})
+	s.Listen(addr)
+	go s.Accept()
+
+	clientHandler := newStreamHandler(false)
+	c := local.NewClientChannel()
+	c.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "streamHandler", clientHandler)
+	})
+	channel, err := c.Connect(addr)
+	if err != nil {
+		t.Fatalf("local connect failed: %+v", err)
+	}
+	defer c.Close()
+
+	sc := <-serverChannels
+	if sc.RemoteAddr().String() != channel.LocalAddr().String() || channel.RemoteAddr() != addr {
+		t.Fatalf("local addr mismatch: %+v <-> %+v", sc.RemoteAddr(), channel.RemoteAddr())
+	}
+
+	channel.Write([]byte("hello"))
+	select {
+	case got := <-clientHandler.received:
+		if string(got) != "hello" {
+			t.Fatalf("local echo got %q", got)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("local echo timeout")
+	}
+
+	// the address can be listened by only one acceptor.
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("listen on address in use should panic")
+			}
+		}()
+		core.GetAcceptorBuilder(core.LocalServBuilder).Build().Listen(addr)
+	}()
+
+	// the address is released after closed.
+	s.Close()
+	if _, err := local.NewClientChannel().Connect(addr); err != local.ErrConnRefused {
+		t.Fatalf("connect to closed address got %+v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/handler"
+)
+
+func TestPacketLengthDecoderExclusive(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+	// "MG" magic prefix, 2 bytes length excludes the header and a 1 byte checksum at tail.
+	decoder := handler.NewPacketLengthDecoder(2).
+		SetLengthFieldOffset(2).
+		SetLengthMode(handler.LengthExclusive).
+		SetLengthAdjustment(1).
+		SetInitialBytesToStrip(0)
+	fc := newFrameCollector(channel, decoder)
+
+	fireBytes(channel, "MG\x00\x03ab", "c!MG\x00", "\x01d!")
+	want := []string{"MG\x00\x03abc!", "MG\x00\x01d!"}
+	if len(fc.errs) != 0 || len(fc.frames) != len(want) {
+		t.Fatalf("frames got %q, errs %v", fc.frames, fc.errs)
+	}
+	for i, frame := range want {
+		if fc.frames[i] != frame {
+			t.Fatalf("frame %d got %q, want %q", i, fc.frames[i], frame)
+		}
+	}
+}
+
+func TestPacketLengthDecoderDiscardTooLongFrame(t *testing.T) {
+	for _, failFast := range []bool{true, false} {
+		channel := newTestChannel()
+		decoder := handler.NewPacketLengthDecoder(2).SetMaxFrameLength(5).SetFailFast(failFast)
+		fc := newFrameCollector(channel, decoder)
+
+		fireBytes(channel, "\x00\x08abc")
+		if failFast != (len(fc.errs) == 1) {
+			t.Fatalf("failFast %v, errors fired before discarded: %v", failFast, fc.errs)
+		}
+		fireBytes(channel, "def\x00\x03x\x00\x04yz")
+		if len(fc.errs) != 1 || len(fc.frames) != 2 || fc.frames[0] != "x" || fc.frames[1] != "yz" {
+			t.Fatalf("failFast %v, frames got %q, errs %v", failFast, fc.frames, fc.errs)
+		}
+		channel.Close()
+	}
+}
+
+func TestPacketLengthDecoderCorruptedFrame(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+	fc := newFrameCollector(channel, handler.NewPacketLengthDecoder(2))
+
+	// the inclusive length is less than the length field.
+	fireBytes(channel, "\x00\x01a")
+	if len(fc.errs) != 1 || len(fc.frames) != 0 {
+		t.Fatalf("frames got %q, errs %v", fc.frames, fc.errs)
+	}
+}
+
+func TestPacketLengthPrepender(t *testing.T) {
+	cases := []struct {
+		prepender *handler.PacketLengthPrepender
+		want      string
+	}{
+		{handler.NewPacketLengthPrepender(2), "\x00\x05abc"},
+		{handler.NewPacketLengthPrepender(2).SetLengthMode(handler.LengthExclusive), "\x00\x03abc"},
+		{handler.NewPacketLengthPrepender(1).SetLengthMode(handler.LengthExclusive).SetLengthAdjustment(1), "\x02abc"},
+		// the length includes the prefix written before it.
+		{handler.NewPacketLengthPrepender(2).SetPrefix([]byte("MG")), "MG\x00\x07abc"},
+		{handler.NewPacketLengthPrepender(2).SetPrefix([]byte("MG")).SetLengthMode(handler.LengthExclusive), "MG\x00\x03abc"},
+	}
+	for _, c := range cases {
+		conn := &batchConn{
+			gateConn: gateConn{release: make(chan byte), closed: make(chan byte)},
+			calls:    make(chan string, 1),
+		}
+		close(conn.release)
+		channel := core.NewSubChannel(conn, &core.SubChannelOpts{})
+		channel.Pipeline().AddLast(nil, "prepender", c.prepender)
+
+		channel.Pipeline().FireWrite(bytes.NewWriteOnlyBufferWithBytes(8, []byte("abc")))
+		if got := <-conn.calls; got != c.want {
+			t.Fatalf("prepended got %q, want %q", got, c.want)
+		}
+		channel.Close()
+	}
+}
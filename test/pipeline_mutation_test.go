@@ -0,0 +1,111 @@
+package test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/amsalt/nginet/core"
+)
+
+// recordHandler records the messages read and passes them on.
+type recordHandler struct {
+	*core.DefaultInboundHandler
+	name string
+	out  chan string
+}
+
+func (rh *recordHandler) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	rh.out <- rh.name
+	ctx.FireRead(msg)
+}
+
+// handshakeHandler replaces itself by the processor after the first message.
+type handshakeHandler struct {
+	*core.DefaultInboundHandler
+	processor *recordHandler
+}
+
+func (hh *handshakeHandler) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	ctx.Channel().Pipeline().Replace("handshake", nil, "processor", hh.processor)
+	ctx.Channel().Pipeline().AddFirst(nil, "encryption", &recordHandler{core.NewDefaultInboundHandler(), "encryption", hh.processor.out})
+}
+
+func newRecordHandler(name string, out chan string) *recordHandler {
+	return &recordHandler{core.NewDefaultInboundHandler(), name, out}
+}
+
+func TestPipelineMutation(t *testing.T) {
+	out := make(chan string, 16)
+	channel := core.NewSubChannel(&gateConn{release: make(chan byte), closed: make(chan byte)}, &core.SubChannelOpts{})
+	defer channel.Close()
+	cp := channel.Pipeline()
+
+	a, b := newRecordHandler("a", out), newRecordHandler("b", out)
+	cp.AddLast(nil, "a", a)
+	cp.AddLast(nil, "b", b)
+	cp.AddLast(nil, "handshake", &handshakeHandler{core.NewDefaultInboundHandler(), newRecordHandler("processor", out)})
+
+	if got := cp.Names(); !reflect.DeepEqual(got, []string{"a", "b", "handshake"}) {
+		t.Fatalf("Names got %v", got)
+	}
+	if cp.First() != a || cp.Get("b") != b || cp.Context("b").Handler() != b {
+		t.Fatalf("First/Get/Context got the wrong handler")
+	}
+	if cp.Get("none") != nil || cp.Remove("none") != nil {
+		t.Fatalf("Get/Remove a missing handler should return nil")
+	}
+
+	if cp.Remove("a") != a {
+		t.Fatalf("Remove should return the removed handler")
+	}
+
+	// the handshake handler swaps itself out and inserts the encryption handler.
+	cp.FireRead("login")
+	cp.FireRead("message")
+	want := []string{"b", "encryption", "b", "processor"}
+	for _, name := range want {
+		if got := <-out; got != name {
+			t.Fatalf("read through %q, want %q", got, name)
+		}
+	}
+	if got := cp.Names(); !reflect.DeepEqual(got, []string{"encryption", "b", "processor"}) {
+		t.Fatalf("Names after mutation got %v", got)
+	}
+	if _, ok := cp.Last().(*recordHandler); !ok {
+		t.Fatalf("Last should be the processor, got %T", cp.Last())
+	}
+}
+
+func TestPipelineConcurrentMutation(t *testing.T) {
+	channel := core.NewSubChannel(&gateConn{release: make(chan byte), closed: make(chan byte)}, &core.SubChannelOpts{})
+	defer channel.Close()
+	cp := channel.Pipeline()
+	out := make(chan string, 1024)
+	cp.AddLast(nil, "first", newRecordHandler("first", out))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			cp.FireRead(i)
+			for len(out) > 0 {
+				<-out
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			cp.AddLast(nil, "temp", core.NewDefaultInboundHandler())
+			cp.Replace("temp", nil, "temp", core.NewDefaultInboundHandler())
+			cp.Remove("temp")
+		}
+	}()
+	wg.Wait()
+
+	if got := cp.Names(); !reflect.DeepEqual(got, []string{"first"}) {
+		t.Fatalf("Names got %v", got)
+	}
+}
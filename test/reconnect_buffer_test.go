@@ -0,0 +1,145 @@
+package test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/core/tcp"
+)
+
+// reconnectTCPPair starts a tcp server whose SubChannels share serverHandler,
+// and connects a client with disconnected write options, waits it reconnected once.
+func reconnectTCPPair(t *testing.T, port string, opts *core.DisconnectedWriteOpts) (core.AcceptorChannel, core.ConnectorChannel, core.SubChannel, *streamHandler) {
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:"+port)
+	serverChannels := make(chan core.SubChannel, 4)
+	serverHandler := newStreamHandler(false)
+	s := core.GetAcceptorBuilder(core.TCPServBuilder).Build()
+	s.InitSubChannel(func(channel core.SubChannel) {
+		serverChannels <- channel
+		channel.Pipeline().AddLast(nil, "streamHandler", serverHandler)
+	})
+	s.Listen(addr)
+	go s.Accept()
+
+	eventHandler := &reconnectEventHandler{core.NewDefaultInboundHandler(), make(chan interface{}, 64)}
+	c := core.GetConnectorBuilder(core.TCPCliBuilder).Build(
+		tcp.WithAutoReconnect(true),
+		tcp.WithReconnectPolicy(newTestBackOff()),
+		tcp.WithDisconnectedWrite(opts),
+	)
+	c.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "eventHandler", eventHandler)
+	})
+	channel, err := c.Connect(addr)
+	if err != nil {
+		t.Fatalf("connect failed: %+v", err)
+	}
+
+	(<-serverChannels).Close()
+	waitReconnectEvent(t, eventHandler.events, core.ReconnectedEvent{})
+	<-serverChannels
+	return s, c, channel, serverHandler
+}
+
+func waitReceived(t *testing.T, sh *streamHandler, want string) {
+	var got strings.Builder
+	for got.Len() < len(want) {
+		select {
+		case data := <-sh.received:
+			got.Write(data)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("wait %q timeout, got %q", want, got.String())
+		}
+	}
+	if got.String() != want {
+		t.Fatalf("received %q, want %q", got.String(), want)
+	}
+}
+
+func TestReconnectBufferReplay(t *testing.T) {
+	s, c, channel, serverHandler := reconnectTCPPair(t, "7888", &core.DisconnectedWriteOpts{
+		Policy:      core.BufferWrites,
+		MaxMessages: 3,
+		ReplayFilter: func(msg interface{}, bufferedAt time.Time) bool {
+			return string(msg.([]byte)) != "b"
+		},
+		ManualReplay: true,
+	})
+	defer s.Close()
+	defer c.Close()
+
+	// not replayed until the handshake finished.
+	for _, msg := range []string{"a", "b", "c", "d"} {
+		if err := channel.Write([]byte(msg)); err != nil {
+			t.Fatalf("write while handshaking failed: %+v", err)
+		}
+	}
+	select {
+	case data := <-serverHandler.received:
+		t.Fatalf("received %q before replay", data)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// "b" is filtered out as stale, "d" is dropped for the buffer is full.
+	channel.(core.Replayer).Replay()
+	channel.Write([]byte("e"))
+	waitReceived(t, serverHandler, "ace")
+}
+
+func TestReconnectFailFast(t *testing.T) {
+	s, c, channel, serverHandler := reconnectTCPPair(t, "7889", &core.DisconnectedWriteOpts{
+		Policy:       core.FailFastWrites,
+		ManualReplay: true,
+	})
+	defer s.Close()
+	defer c.Close()
+
+	if err := channel.Write([]byte("a")); err != core.ErrReconnecting {
+		t.Fatalf("write while reconnecting should fail fast, got %+v", err)
+	}
+
+	channel.(core.Replayer).Replay()
+	if err := channel.Write([]byte("b")); err != nil {
+		t.Fatalf("write after replay failed: %+v", err)
+	}
+	waitReceived(t, serverHandler, "b")
+}
+
+func TestReconnectBufferAfterWriteFailed(t *testing.T) {
+	redialed := &batchConn{
+		gateConn: gateConn{release: make(chan byte), closed: make(chan byte)},
+		calls:    make(chan string, 8),
+	}
+	close(redialed.release)
+	dial := make(chan byte)
+	channel := core.NewSubChannel(&brokenConn{closed: make(chan byte)}, &core.SubChannelOpts{
+		WriteBufSize:   8,
+		WriteBatchSize: 1,
+		Reconnect: &core.ReconnectOpts{
+			AutoReconnect: true,
+			Policy:        newTestBackOff(),
+			Dial: func() (core.RawConn, error) {
+				<-dial
+				return redialed, nil
+			},
+		},
+	})
+	defer channel.Close()
+	core.NewConnector().FireConnect(channel)
+
+	// the message written after the write failed is buffered instead of lost.
+	failed, buffered := channel.WriteAsync([]byte("a")), channel.WriteAsync([]byte("b"))
+	if err := waitFuture(t, failed); err != errBrokenPipe {
+		t.Fatalf("write to the broken conn got %+v", err)
+	}
+	close(dial)
+	if err := waitFuture(t, buffered); err != nil {
+		t.Fatalf("write buffered while reconnecting got %+v", err)
+	}
+	if got := <-redialed.calls; got != "b" {
+		t.Fatalf("replayed %q", got)
+	}
+}
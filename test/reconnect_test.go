@@ -0,0 +1,186 @@
+package test
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/core/tcp"
+	"github.com/amsalt/nginet/core/ws"
+)
+
+type reconnectEventHandler struct {
+	*core.DefaultInboundHandler
+	events chan interface{}
+}
+
+func (rh *reconnectEventHandler) OnEvent(ctx *core.ChannelContext, event interface{}) {
+	switch event.(type) {
+	case core.ReconnectingEvent, core.ReconnectedEvent, core.ReconnectFailedEvent:
+		rh.events <- event
+	}
+	ctx.FireEvent(event)
+}
+
+func waitReconnectEvent(t *testing.T, events chan interface{}, want interface{}) interface{} {
+	for {
+		select {
+		case event := <-events:
+			switch event.(type) {
+			case core.ReconnectedEvent:
+				if _, ok := want.(core.ReconnectedEvent); ok {
+					return event
+				}
+			case core.ReconnectFailedEvent:
+				if _, ok := want.(core.ReconnectFailedEvent); ok {
+					return event
+				}
+				t.Fatalf("unexpected reconnect failure: %+v", event)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("wait %T timeout", want)
+		}
+	}
+}
+
+func testReconnect(t *testing.T, s core.AcceptorChannel, listen net.Addr, c core.ConnectorChannel, connectAddr interface{}) {
+	serverChannels := make(chan core.SubChannel, 4)
+	s.InitSubChannel(func(channel core.SubChannel) {
+		serverChannels <- channel
+		channel.Pipeline().AddLast(nil, "streamHandler", newStreamHandler(true))
+	})
+	s.Listen(listen)
+	go s.Accept()
+
+	clientHandler := newStreamHandler(false)
+	eventHandler := &reconnectEventHandler{core.NewDefaultInboundHandler(), make(chan interface{}, 64)}
+	c.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "streamHandler", clientHandler)
+		channel.Pipeline().AddLast(nil, "eventHandler", eventHandler)
+	})
+	channel, err := c.Connect(connectAddr)
+	if err != nil {
+		t.Fatalf("connect failed: %+v", err)
+	}
+	defer c.Close()
+
+	// the server drops the connection, the client should reconnect.
+	(<-serverChannels).Close()
+	waitReconnectEvent(t, eventHandler.events, core.ReconnectedEvent{})
+	<-serverChannels
+
+	channel.Write([]byte("hello"))
+	select {
+	case got := <-clientHandler.received:
+		if string(got) != "hello" {
+			t.Fatalf("echo after reconnected got %q", got)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("echo after reconnected timeout")
+	}
+
+	// the server is gone, the policy should give up.
+	s.Close()
+	for _, sc := range s.SubChannels() {
+		sc.Close()
+	}
+	event := waitReconnectEvent(t, eventHandler.events, core.ReconnectFailedEvent{}).(core.ReconnectFailedEvent)
+	if event.Attempts != 3 {
+		t.Fatalf("reconnect should give up after 3 attempts, got %d", event.Attempts)
+	}
+}
+
+func newTestBackOff() core.ReconnectPolicy {
+	return &core.ExponentialBackOff{
+		InitialInterval:     20 * time.Millisecond,
+		MaxInterval:         100 * time.Millisecond,
+		Multiplier:          2,
+		RandomizationFactor: 0.2,
+		MaxRetries:          3,
+	}
+}
+
+func TestTCPReconnect(t *testing.T) {
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:7886")
+	s := core.GetAcceptorBuilder(core.TCPServBuilder).Build()
+	c := core.GetConnectorBuilder(core.TCPCliBuilder).Build(
+		tcp.WithAutoReconnect(true),
+		tcp.WithReconnectPolicy(newTestBackOff()),
+	)
+	testReconnect(t, s, addr, c, addr)
+}
+
+func TestWSReconnect(t *testing.T) {
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:7887")
+	s := core.GetAcceptorBuilder(core.WebsocketServBuilder).Build()
+	c := core.GetConnectorBuilder(core.WebsocketCliBuilder).Build(
+		ws.WithAutoReconnect(true),
+		ws.WithReconnectPolicy(newTestBackOff()),
+	)
+	testReconnect(t, s, addr, c, "ws://127.0.0.1:7887/")
+}
+
+func TestExponentialBackOff(t *testing.T) {
+	b := &core.ExponentialBackOff{
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     time.Second,
+		Multiplier:      2,
+		MaxElapsedTime:  time.Minute,
+	}
+
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		got, ok := b.NextBackOff(attempt+1, 0)
+		if !ok || got != want*time.Millisecond {
+			t.Fatalf("attempt %d back off got %+v, want %+v", attempt+1, got, want*time.Millisecond)
+		}
+	}
+
+	if _, ok := b.NextBackOff(1, time.Minute); ok {
+		t.Fatalf("back off should stop after max elapsed time")
+	}
+
+	b.RandomizationFactor = 0.5
+	for i := 0; i < 100; i++ {
+		got, _ := b.NextBackOff(1, 0)
+		if got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Fatalf("jitter back off out of range: %+v", got)
+		}
+	}
+}
+
+// eofConn reads EOF as the peer closed, but is not closed until Close called.
+type eofConn struct {
+	gateConn
+}
+
+func (ec *eofConn) Read(buf bytes.ReadOnlyBuffer) error {
+	return io.EOF
+}
+
+func TestReconnectCloseLostConn(t *testing.T) {
+	lost := &eofConn{gateConn{release: make(chan byte), closed: make(chan byte)}}
+	redialed := &gateConn{release: make(chan byte), closed: make(chan byte)}
+	channel := core.NewSubChannel(lost, &core.SubChannelOpts{
+		Reconnect: &core.ReconnectOpts{
+			AutoReconnect: true,
+			Policy:        newTestBackOff(),
+			Dial: func() (core.RawConn, error) {
+				return redialed, nil
+			},
+		},
+	})
+	defer channel.Close()
+	events := make(chan interface{}, 8)
+	channel.Pipeline().AddLast(nil, "events", &reconnectEventHandler{core.NewDefaultInboundHandler(), events})
+	core.NewConnector().FireConnect(channel)
+
+	waitReconnectEvent(t, events, core.ReconnectedEvent{})
+	select {
+	case <-lost.closed:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("the lost conn should be closed after reconnected")
+	}
+}
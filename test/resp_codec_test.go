@@ -0,0 +1,152 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/handler"
+)
+
+// respCollector collects the RESP values decoded and the errors.
+type respCollector struct {
+	*core.DefaultInboundHandler
+	values []*handler.RESPValue
+	errs   []error
+}
+
+func (rc *respCollector) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	rc.values = append(rc.values, msg.(*handler.RESPValue))
+}
+
+func (rc *respCollector) OnError(ctx *core.ChannelContext, err error) {
+	rc.errs = append(rc.errs, err)
+}
+
+func newRESPCollector(channel core.SubChannel, decoder *handler.RESPDecoder) *respCollector {
+	rc := &respCollector{DefaultInboundHandler: core.NewDefaultInboundHandler()}
+	channel.Pipeline().AddLast(nil, "decoder", decoder)
+	channel.Pipeline().AddLast(nil, "collector", rc)
+	return rc
+}
+
+func TestRESPDecoder(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+	rc := newRESPCollector(channel, handler.NewRESPDecoder())
+
+	stream := "+OK\r\n-ERR wrong\r\n:-42\r\n$5\r\nhello\r\n$-1\r\n*2\r\n$3\r\nGET\r\n$1\r\nk\r\n" +
+		"%1\r\n+key\r\n*2\r\n:1\r\n#t\r\n_\r\n,3.14\r\n"
+	// split into the reads of 3 bytes, the values are decoded when complete.
+	var chunks []string
+	for i := 0; i < len(stream); i += 3 {
+		end := i + 3
+		if end > len(stream) {
+			end = len(stream)
+		}
+		chunks = append(chunks, stream[i:end])
+	}
+	fireBytes(channel, chunks...)
+
+	want := []*handler.RESPValue{
+		handler.NewRESPSimpleString("OK"),
+		handler.NewRESPError("ERR wrong"),
+		handler.NewRESPInteger(-42),
+		handler.NewRESPBulkString("hello"),
+		handler.NewRESPNullBulkString(),
+		handler.NewRESPCommand("GET", "k"),
+		handler.NewRESPMap(handler.NewRESPSimpleString("key"), handler.NewRESPArray(
+			handler.NewRESPInteger(1), &handler.RESPValue{Type: handler.RESPBoolean, Bool: true})),
+		{Type: handler.RESPNull, Null: true},
+		{Type: handler.RESPDouble, Str: "3.14"},
+	}
+	if len(rc.errs) != 0 || !reflect.DeepEqual(rc.values, want) {
+		t.Fatalf("values got %v, errs %v", rc.values, rc.errs)
+	}
+
+	name, args, ok := rc.values[5].Command()
+	if !ok || name != "GET" || !reflect.DeepEqual(args, []string{"k"}) || rc.values[5].ID() != "GET" {
+		t.Fatalf("command got %v %v %v", name, args, ok)
+	}
+}
+
+func TestRESPDecoderInline(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+	rc := newRESPCollector(channel, handler.NewRESPDecoder())
+
+	fireBytes(channel, "PING\r\n\r\nset  k ", "v\n")
+	want := []*handler.RESPValue{handler.NewRESPCommand("PING"), handler.NewRESPCommand("set", "k", "v")}
+	if len(rc.errs) != 0 || !reflect.DeepEqual(rc.values, want) {
+		t.Fatalf("values got %v, errs %v", rc.values, rc.errs)
+	}
+	if name, _, _ := rc.values[1].Command(); name != "SET" {
+		t.Fatalf("command name got %q", name)
+	}
+}
+
+func TestRESPDecoderError(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+	decoder := handler.NewRESPDecoder().SetInline(false).SetMaxBulkLength(8).SetMaxDepth(2)
+	rc := newRESPCollector(channel, decoder)
+
+	fireBytes(channel, "PING\r\n", ":abc\r\n", "$9\r\n", "$3\r\nabcd\r\n", "*1\r\n*1\r\n*1\r\n:1\r\n", ":7\r\n")
+	if len(rc.errs) != 5 || len(rc.values) != 1 || rc.values[0].Int != 7 || decoder.Cumulated() != 0 {
+		t.Fatalf("values got %v, errs %v", rc.values, rc.errs)
+	}
+}
+
+func TestRESPDecoderIncremental(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+	decoder := handler.NewRESPDecoder()
+	rc := newRESPCollector(channel, decoder)
+
+	// the elements decoded are not cumulated again.
+	fireBytes(channel, "*2\r\n$5\r\nhel", "lo\r\n")
+	if len(rc.values) != 0 || decoder.Cumulated() != 0 {
+		t.Fatalf("the incomplete array should be kept decoded, values %v, cumulated %v", rc.values, decoder.Cumulated())
+	}
+	fireBytes(channel, "$-1\r\n")
+	want := []*handler.RESPValue{handler.NewRESPArray(handler.NewRESPBulkString("hello"), handler.NewRESPNullBulkString())}
+	if len(rc.errs) != 0 || !reflect.DeepEqual(rc.values, want) {
+		t.Fatalf("values got %v, errs %v", rc.values, rc.errs)
+	}
+
+	// the incomplete value is discarded when disconnected.
+	fireBytes(channel, "*2\r\n:1\r\n")
+	channel.Pipeline().FireDisconnect()
+	fireBytes(channel, "+OK\r\n")
+	if len(rc.values) != 2 || !reflect.DeepEqual(rc.values[1], handler.NewRESPSimpleString("OK")) {
+		t.Fatalf("values got %v, errs %v", rc.values, rc.errs)
+	}
+}
+
+func TestRESPEncoder(t *testing.T) {
+	values := []*handler.RESPValue{
+		handler.NewRESPSimpleString("OK"),
+		handler.NewRESPInteger(7),
+		handler.NewRESPNullBulkString(),
+		&handler.RESPValue{Type: handler.RESPArray, Null: true},
+		handler.NewRESPCommand("SET", "k", "a\r\nb"),
+		handler.NewRESPMap(handler.NewRESPBulkString("k"), &handler.RESPValue{Type: handler.RESPBoolean}),
+	}
+	want := "+OK\r\n:7\r\n$-1\r\n*-1\r\n*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$4\r\na\r\nb\r\n%1\r\n$1\r\nk\r\n#f\r\n"
+	var got []byte
+	for _, v := range values {
+		got = append(got, v.Bytes()...)
+	}
+	if string(got) != want {
+		t.Fatalf("encoded got %q", got)
+	}
+
+	// decodes what encoded.
+	channel := newTestChannel()
+	defer channel.Close()
+	rc := newRESPCollector(channel, handler.NewRESPDecoder())
+	fireBytes(channel, want)
+	if len(rc.errs) != 0 || !reflect.DeepEqual(rc.values, values) {
+		t.Fatalf("values got %v, errs %v", rc.values, rc.errs)
+	}
+}
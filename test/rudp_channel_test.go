@@ -0,0 +1,241 @@
+package test
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/core/rudp"
+)
+
+type streamHandler struct {
+	*core.DefaultInboundHandler
+	received chan []byte
+	echo     bool
+}
+
+func newStreamHandler(echo bool) *streamHandler {
+	return &streamHandler{
+		DefaultInboundHandler: core.NewDefaultInboundHandler(),
+		received:              make(chan []byte, 1024),
+		echo:                  echo,
+	}
+}
+
+func (sh *streamHandler) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	buf := msg.(bytes.ReadOnlyBuffer)
+	data, _ := buf.Read(0, buf.Len())
+	data = append([]byte{}, data...)
+	sh.received <- data
+	if sh.echo {
+		ctx.Write(data)
+	}
+}
+
+// lossyProxy forwards datagrams between client and server, drops every nth datagram.
+func lossyProxy(t *testing.T, listen, target string, nth int) *net.UDPConn {
+	laddr, _ := net.ResolveUDPAddr("udp", listen)
+	taddr, _ := net.ResolveUDPAddr("udp", target)
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatalf("proxy listen failed: %+v", err)
+	}
+
+	var client *net.UDPAddr
+	go func() {
+		buf := make([]byte, 65535)
+		count := 0
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			count++
+			if count%nth == 0 {
+				continue
+			}
+			if addr.String() == taddr.String() {
+				if client != nil {
+					conn.WriteToUDP(buf[:n], client)
+				}
+			} else {
+				client = addr
+				conn.WriteToUDP(buf[:n], taddr)
+			}
+		}
+	}()
+	return conn
+}
+
+func TestRUDPChannel(t *testing.T) {
+	s := core.GetAcceptorBuilder(core.RUDPServBuilder).Build(
+		rudp.WithFastMode(),
+	)
+	serverHandler := newStreamHandler(true)
+	s.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "streamHandler", serverHandler)
+	})
+
+	addr, err := net.ResolveUDPAddr("udp", "127.0.0.1:7881")
+	if err != nil {
+		panic("bad net addr")
+	}
+	s.Listen(addr)
+	go s.Accept()
+	defer s.Close()
+
+	proxy := lossyProxy(t, "127.0.0.1:7882", "127.0.0.1:7881", 3)
+	defer proxy.Close()
+
+	opts := &rudp.Options{WriteBufSize: 1024, ReadBufSize: 1024, NoDelay: true, Interval: 10 * time.Millisecond, FastResend: 2, NoCongestionWindow: true, AckNoDelay: true}
+	clientHandler := newStreamHandler(false)
+	c := rudp.NewClientChannel(opts)
+	c.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "streamHandler", clientHandler)
+	})
+	paddr, _ := net.ResolveUDPAddr("udp", "127.0.0.1:7882")
+	if _, err := c.Connect(paddr); err != nil {
+		t.Fatalf("rudp connect failed: %+v", err)
+	}
+	defer c.Close()
+
+	// large messages are fragmented, lost segments are resent, data arrives in order.
+	var expected []byte
+	for i := 0; i < 20; i++ {
+		msg := []byte(fmt.Sprintf("message-%02d:", i))
+		for len(msg) < 3000 {
+			msg = append(msg, byte('a'+i))
+		}
+		expected = append(expected, msg...)
+		c.Write(msg)
+	}
+
+	var got []byte
+	timeout := time.After(10 * time.Second)
+	for len(got) < len(expected) {
+		select {
+		case data := <-clientHandler.received:
+			got = append(got, data...)
+		case <-timeout:
+			t.Fatalf("rudp echo timeout, got %d of %d bytes", len(got), len(expected))
+		}
+	}
+
+	if string(got) != string(expected) {
+		t.Fatalf("rudp echo data mismatch")
+	}
+}
+
+func TestRUDPSendWindow(t *testing.T) {
+	// the remote side never acks.
+	blackhole, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 7896})
+	if err != nil {
+		t.Fatalf("listen failed: %+v", err)
+	}
+	defer blackhole.Close()
+
+	c := rudp.NewClientChannel(&rudp.Options{WriteBufSize: 1024, ReadBufSize: 1024, Interval: 10 * time.Millisecond, SndWnd: 4})
+	if _, err := c.Connect(blackhole.LocalAddr()); err != nil {
+		t.Fatalf("rudp connect failed: %+v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := waitFuture(t, c.WriteAsync([]byte("hello"))); err != nil {
+			t.Fatalf("write %d failed: %+v", i, err)
+		}
+	}
+
+	// blocks while the send window is full.
+	future := c.WriteAsync([]byte("hello"))
+	select {
+	case <-future.Done():
+		t.Fatalf("the write should block when the send window is full, err: %+v", future.Err())
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	c.Close()
+	if err := waitFuture(t, future); err == nil {
+		t.Fatalf("the blocked write should fail after closed")
+	}
+}
+
+func TestRUDPServerClose(t *testing.T) {
+	s := core.GetAcceptorBuilder(core.RUDPServBuilder).Build(rudp.WithFastMode())
+	serverHandler := newStreamHandler(false)
+	dh := &disconnectHandler{core.NewDefaultInboundHandler(), make(chan byte)}
+	s.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "streamHandler", serverHandler)
+		channel.Pipeline().AddLast(nil, "disconnectHandler", dh)
+	})
+
+	addr, _ := net.ResolveUDPAddr("udp", "127.0.0.1:7897")
+	s.Listen(addr)
+	go s.Accept()
+
+	c := rudp.NewClientChannel()
+	if _, err := c.Connect(addr); err != nil {
+		t.Fatalf("rudp connect failed: %+v", err)
+	}
+	defer c.Close()
+
+	c.Write([]byte("hello"))
+	select {
+	case <-serverHandler.received:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("rudp server receive timeout")
+	}
+
+	// the sessions are closed with the server.
+	s.Close()
+	select {
+	case <-dh.disconnected:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("the session should be closed when the server closed")
+	}
+}
+
+func TestRUDPServerConvMismatch(t *testing.T) {
+	s := core.GetAcceptorBuilder(core.RUDPServBuilder).Build(rudp.WithFastMode())
+	channels := make(chan core.SubChannel, 4)
+	s.InitSubChannel(func(channel core.SubChannel) {
+		channels <- channel
+	})
+	addr, _ := net.ResolveUDPAddr("udp", "127.0.0.1:7899")
+	s.Listen(addr)
+	go s.Accept()
+	defer s.Close()
+
+	peer, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		t.Fatalf("dial failed: %+v", err)
+	}
+	defer peer.Close()
+
+	// a segment header of conv.
+	segment := func(conv byte) []byte {
+		data := make([]byte, 24)
+		data[0] = conv
+		return data
+	}
+
+	peer.Write(segment(1))
+	var channel core.SubChannel
+	select {
+	case channel = <-channels:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("rudp session not created")
+	}
+
+	// the datagram of another conv does not replace the live session.
+	peer.Write(segment(2))
+	select {
+	case <-channel.CloseNotify():
+		t.Fatalf("the live session should not be closed by a conv mismatch")
+	case <-channels:
+		t.Fatalf("the live session should not be replaced by a conv mismatch")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
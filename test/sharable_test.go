@@ -0,0 +1,90 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/handler"
+)
+
+func newTestChannel() core.SubChannel {
+	return core.NewSubChannel(&gateConn{release: make(chan byte), closed: make(chan byte)}, &core.SubChannelOpts{})
+}
+
+func TestSharable(t *testing.T) {
+	c1, c2, c3 := newTestChannel(), newTestChannel(), newTestChannel()
+	defer c1.Close()
+	defer c2.Close()
+	defer c3.Close()
+
+	// the stateless handlers can be shared.
+	encoder := handler.NewPacketLengthPrepender(2)
+	c1.Pipeline().AddLast(nil, "prepender", encoder)
+	c2.Pipeline().AddLast(nil, "prepender", encoder)
+
+	// the non-sharable handler attached is refused, the channel fails instead of
+	// running without it.
+	rc4 := handler.NewRc4Cipher("example")
+	c1.Pipeline().AddLast(nil, "rc4", rc4)
+	eh := &errorHandler{core.NewDefaultInboundHandler(), make(chan error, 1)}
+	c2.Pipeline().AddLast(nil, "errors", eh)
+	c2.Pipeline().AddLast(nil, "rc4", rc4)
+	if len(eh.errs) != 1 {
+		t.Fatalf("the rejected handler should fire an error")
+	}
+	select {
+	case <-c2.CloseNotify():
+	default:
+		t.Fatalf("the channel should be closed when a handler rejected")
+	}
+	if old := c3.Pipeline().Replace("none", nil, "rc4", rc4); old != nil {
+		t.Fatalf("the rejected handler should not replace %T", old)
+	}
+	if names := c3.Pipeline().Names(); len(names) != 0 {
+		t.Fatalf("the closed channel should have no handlers, got %v", names)
+	}
+	if names := c1.Pipeline().Names(); len(names) != 2 {
+		t.Fatalf("the channel attached should be kept, got %v", names)
+	}
+
+	// the handler added after closed is not attached.
+	idle := handler.NewIdleStateHandler(60, 60, false)
+	c2.Pipeline().AddLast(nil, "idle", idle)
+	if names := c2.Pipeline().Names(); len(names) != 0 {
+		t.Fatalf("the closed channel should have no handlers, got %v", names)
+	}
+
+	// detached after removed, so it can be added again.
+	c1.Pipeline().Remove("rc4")
+	c4 := newTestChannel()
+	defer c4.Close()
+	c4.Pipeline().AddLast(nil, "rc4", rc4)
+
+	// released when the channel closed.
+	c5 := newTestChannel()
+	c5.Pipeline().AddLast(nil, "idle", idle)
+	c5.Close()
+	c1.Pipeline().AddLast(nil, "idle", idle)
+	if names := c1.Pipeline().Names(); len(names) != 2 || names[1] != "idle" {
+		t.Fatalf("the released handler should be added, got %v", names)
+	}
+}
+
+func TestAddLastFactory(t *testing.T) {
+	s := core.GetAcceptorBuilder(core.TCPServBuilder).Build()
+	var built []interface{}
+	s.AddLastFactory("idle", func() interface{} {
+		idle := handler.NewIdleStateHandler(60, 60, false)
+		built = append(built, idle)
+		return idle
+	})
+
+	c1, c2 := newTestChannel(), newTestChannel()
+	defer c1.Close()
+	defer c2.Close()
+	s.FireConnect(c1)
+	s.FireConnect(c2)
+	if len(built) != 2 || c1.Pipeline().Get("idle") != built[0] || c2.Pipeline().Get("idle") != built[1] {
+		t.Fatalf("AddLastFactory should build a fresh handler per channel")
+	}
+}
@@ -0,0 +1,102 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/core/tcp"
+)
+
+type shutdownHandler struct {
+	*core.DefaultInboundHandler
+}
+
+func (sh *shutdownHandler) OnEvent(ctx *core.ChannelContext, event interface{}) {
+	if _, ok := event.(core.ShutdownEvent); ok {
+		ctx.Write([]byte("bye"))
+	}
+	ctx.FireEvent(event)
+}
+
+type disconnectHandler struct {
+	*core.DefaultInboundHandler
+	disconnected chan byte
+}
+
+func (dh *disconnectHandler) OnDisconnect(ctx *core.ChannelContext) {
+	close(dh.disconnected)
+	ctx.FireDisconnect()
+}
+
+func TestAcceptorShutdown(t *testing.T) {
+	s := core.GetAcceptorBuilder(core.TCPServBuilder).Build(tcp.WithWriteBufSize(128))
+	serverChannels := make(chan core.SubChannel, 1)
+	s.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "shutdownHandler", &shutdownHandler{core.NewDefaultInboundHandler()})
+		serverChannels <- channel
+	})
+	addr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:7885")
+	if err != nil {
+		panic("bad net addr")
+	}
+	s.Listen(addr)
+	go s.Accept()
+
+	clientHandler := newStreamHandler(false)
+	dh := &disconnectHandler{core.NewDefaultInboundHandler(), make(chan byte)}
+	c := tcp.NewClientChannel()
+	c.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "streamHandler", clientHandler)
+		channel.Pipeline().AddLast(nil, "disconnectHandler", dh)
+	})
+	if _, err := c.Connect(addr); err != nil {
+		t.Fatalf("tcp connect failed: %+v", err)
+	}
+	defer c.Close()
+
+	sc := <-serverChannels
+	var want []byte
+	for i := 0; i < 60; i++ {
+		msg := []byte(fmt.Sprintf("message-%d;", i))
+		want = append(want, msg...)
+		if err := sc.Write(msg); err != nil {
+			t.Fatalf("server write failed: %+v", err)
+		}
+	}
+	want = append(want, "bye"...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed: %+v", err)
+	}
+
+	// all pending messages should be sent before closed.
+	var got []byte
+	for !bytes.Equal(got, want) {
+		select {
+		case data := <-clientHandler.received:
+			got = append(got, data...)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("pending messages lost, got %q", got)
+		}
+	}
+
+	select {
+	case <-dh.disconnected:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("connection not closed after shutdown")
+	}
+
+	if err := sc.Write([]byte("late")); err == nil {
+		t.Fatalf("write after shutdown should fail")
+	}
+	if _, err := tcp.NewClientChannel().Connect(addr); err == nil {
+		t.Fatalf("connect after shutdown should fail")
+	}
+}
@@ -0,0 +1,179 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/core/tcp"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert, isCA bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key failed: %+v", err)
+	}
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("create certificate failed: %+v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key}
+}
+
+func (tc *testCert) write(t *testing.T, certFile, keyFile string) {
+	keyDer, _ := x509.MarshalECPrivateKey(tc.key)
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tc.cert.Raw}), 0600); err != nil {
+		t.Fatalf("write cert failed: %+v", err)
+	}
+	if keyFile == "" {
+		return
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatalf("write key failed: %+v", err)
+	}
+}
+
+func (tc *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{tc.cert.Raw}, PrivateKey: tc.key}
+}
+
+func TestTCPTLSChannel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nginet")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, "nginet-ca", nil, true)
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "server.pem")
+	keyFile := filepath.Join(dir, "server.key")
+	ca.write(t, caFile, "")
+	newTestCert(t, "server-v1", ca, false).write(t, certFile, keyFile)
+
+	reloader, err := tcp.NewKeyPairReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("load key pair failed: %+v", err)
+	}
+
+	s := core.GetAcceptorBuilder(core.TCPServBuilder).Build(
+		tcp.WithTLSConfig(&tls.Config{NextProtos: []string{"nginet"}}),
+		tcp.WithKeyPairReloader(reloader),
+		tcp.WithClientCAFile(caFile),
+	)
+	serverChannels := make(chan core.SubChannel, 4)
+	s.InitSubChannel(func(channel core.SubChannel) {
+		serverChannels <- channel
+		channel.Pipeline().AddLast(nil, "streamHandler", newStreamHandler(true))
+	})
+
+	addr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:7882")
+	if err != nil {
+		panic("bad net addr")
+	}
+	s.Listen(addr)
+	go s.Accept()
+	defer s.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientCert := newTestCert(t, "client", ca, false)
+
+	connect := func(certs ...tls.Certificate) (core.SubChannel, *streamHandler, error) {
+		h := newStreamHandler(false)
+		c := tcp.NewClientChannel(&tcp.Options{
+			WriteBufSize: 1024,
+			ReadBufSize:  1024,
+			TLSConfig:    &tls.Config{RootCAs: roots, Certificates: certs, NextProtos: []string{"nginet"}},
+		})
+		c.InitSubChannel(func(channel core.SubChannel) {
+			channel.Pipeline().AddLast(nil, "streamHandler", h)
+		})
+		channel, err := c.Connect(addr)
+		return channel, h, err
+	}
+
+	// the client without certificate should be rejected.
+	if channel, _, err := connect(); err == nil {
+		channel.Write([]byte("hello"))
+		select {
+		case <-serverChannels:
+			t.Fatalf("tls client without certificate should be rejected")
+		case <-time.After(500 * time.Millisecond):
+		}
+		channel.Close()
+	}
+
+	for _, cn := range []string{"server-v1", "server-v2"} {
+		if cn == "server-v2" {
+			newTestCert(t, cn, ca, false).write(t, certFile, keyFile)
+			if err := reloader.Reload(); err != nil {
+				t.Fatalf("reload key pair failed: %+v", err)
+			}
+		}
+
+		channel, h, err := connect(clientCert.tlsCertificate())
+		if err != nil {
+			t.Fatalf("tls connect failed: %+v", err)
+		}
+
+		if certs := tcp.PeerCertificatesOf(channel); len(certs) == 0 || certs[0].Subject.CommonName != cn {
+			t.Fatalf("tls server certificate mismatch, want %+v", cn)
+		}
+		if proto := tcp.NegotiatedProtocolOf(channel); proto != "nginet" {
+			t.Fatalf("tls negotiated protocol got %q", proto)
+		}
+
+		channel.Write([]byte("hello"))
+		select {
+		case got := <-h.received:
+			if string(got) != "hello" {
+				t.Fatalf("tls echo got %q", got)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("tls echo timeout")
+		}
+
+		sc := <-serverChannels
+		if certs := tcp.PeerCertificatesOf(sc); len(certs) == 0 || certs[0].Subject.CommonName != "client" {
+			t.Fatalf("tls client certificate not exposed")
+		}
+		channel.Close()
+	}
+}
@@ -54,6 +54,7 @@ func TestTelnet(t *testing.T) {
 	)
 
 	s.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "linedecoder", handler.NewLineBasedFrameDecoder(1024))
 		channel.Pipeline().AddLast(nil, "stringencoder", handler.NewStringEncoder())
 		channel.Pipeline().AddLast(nil, "texthandler", &texthandler{})
 	})
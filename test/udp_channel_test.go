@@ -0,0 +1,144 @@
+package test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/amsalt/log"
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/core/udp"
+)
+
+type datagramHandler struct {
+	*core.DefaultInboundHandler
+	received chan string
+	echo     bool
+}
+
+func newDatagramHandler(echo bool) *datagramHandler {
+	return &datagramHandler{
+		DefaultInboundHandler: core.NewDefaultInboundHandler(),
+		received:              make(chan string, 16),
+		echo:                  echo,
+	}
+}
+
+func (dh *datagramHandler) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	buf := msg.(bytes.ReadOnlyBuffer)
+	data, _ := buf.Read(0, buf.Len())
+	log.Infof("datagramHandler read: %+v", string(data))
+	dh.received <- string(data)
+	if dh.echo {
+		ctx.Write(append([]byte{}, data...))
+	}
+}
+
+func TestUDPChannel(t *testing.T) {
+	s := core.GetAcceptorBuilder(core.UDPServBuilder).Build(
+		udp.WithMaxConnNum(10),
+		udp.WithIdleTimeout(time.Second),
+	)
+
+	serverHandler := newDatagramHandler(true)
+	s.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "datagramHandler", serverHandler)
+	})
+
+	addr, err := net.ResolveUDPAddr("udp", "127.0.0.1:7880")
+	if err != nil {
+		panic("bad net addr")
+	}
+	s.Listen(addr)
+	go s.Accept()
+	defer s.Close()
+
+	clientHandler := newDatagramHandler(false)
+	c := udp.NewClientChannel()
+	c.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "datagramHandler", clientHandler)
+	})
+	if _, err := c.Connect(addr); err != nil {
+		t.Fatalf("udp connect failed: %+v", err)
+	}
+	defer c.Close()
+
+	// each datagram should be delivered as a whole.
+	for _, m := range []string{"hello", "world"} {
+		c.Write([]byte(m))
+		select {
+		case got := <-clientHandler.received:
+			if got != m {
+				t.Fatalf("udp echo got %q, want %q", got, m)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("udp echo %q timeout", m)
+		}
+	}
+
+	if n := len(s.SubChannels()); n != 1 {
+		t.Fatalf("udp server should have 1 peer, got %d", n)
+	}
+
+	// the idle peer should be expired.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.SubChannels()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if n := len(s.SubChannels()); n != 0 {
+		t.Fatalf("idle udp peer not expired, %d peers left", n)
+	}
+}
+
+func TestUDPTruncatedAndClose(t *testing.T) {
+	s := core.GetAcceptorBuilder(core.UDPServBuilder).Build(udp.WithReadBufSize(8))
+	serverHandler := newDatagramHandler(true)
+	eh := &errorHandler{core.NewDefaultInboundHandler(), make(chan error, 4)}
+	dh := &disconnectHandler{core.NewDefaultInboundHandler(), make(chan byte)}
+	s.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "datagramHandler", serverHandler)
+		channel.Pipeline().AddLast(nil, "errorHandler", eh)
+		channel.Pipeline().AddLast(nil, "disconnectHandler", dh)
+	})
+
+	addr, _ := net.ResolveUDPAddr("udp", "127.0.0.1:7898")
+	s.Listen(addr)
+	go s.Accept()
+
+	c := core.GetConnectorBuilder(core.UDPCliBuilder).Build(udp.WithReadBufSize(4))
+	clientErrors := &errorHandler{core.NewDefaultInboundHandler(), make(chan error, 4)}
+	c.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "errorHandler", clientErrors)
+	})
+	if _, err := c.Connect(addr); err != nil {
+		t.Fatalf("udp connect failed: %+v", err)
+	}
+	defer c.Close()
+
+	// the datagram larger than ReadBufSize is dropped with the error fired, the channel goes on.
+	c.Write([]byte("0123456789"))
+	c.Write([]byte("hello"))
+	for _, errs := range []chan error{eh.errs, clientErrors.errs} {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, core.ErrMessageTruncated) {
+				t.Fatalf("expect ErrMessageTruncated, got %+v", err)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("the truncated datagram should fire an error")
+		}
+	}
+	if got := <-serverHandler.received; got != "hello" {
+		t.Fatalf("udp server got %q, want hello", got)
+	}
+
+	// the peers are closed with the server.
+	s.Close()
+	select {
+	case <-dh.disconnected:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("the peer should be closed when the server closed")
+	}
+}
@@ -0,0 +1,203 @@
+package test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/core/unix"
+)
+
+func TestUnixChannel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nginet")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "echo.sock")
+	addr := &net.UnixAddr{Name: path, Net: "unixpacket"}
+
+	// leave a stale socket file behind, which should be removed by Listen.
+	stale, err := net.ListenUnix("unixpacket", addr)
+	if err != nil {
+		t.Fatalf("listen stale socket failed: %+v", err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	s := core.GetAcceptorBuilder(core.UnixServBuilder).Build(unix.WithMaxConnNum(10))
+
+	serverHandler := newDatagramHandler(true)
+	creds := make(chan *unix.PeerCred, 1)
+	s.InitSubChannel(func(channel core.SubChannel) {
+		creds <- unix.PeerCredOf(channel)
+		channel.Pipeline().AddLast(nil, "datagramHandler", serverHandler)
+	})
+	s.Listen(addr)
+	go s.Accept()
+	defer s.Close()
+
+	clientHandler := newDatagramHandler(false)
+	c := unix.NewClientChannel()
+	c.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "datagramHandler", clientHandler)
+	})
+	if _, err := c.Connect(addr); err != nil {
+		t.Fatalf("unix connect failed: %+v", err)
+	}
+	defer c.Close()
+
+	// packet boundaries should be kept by unixpacket.
+	for _, m := range []string{"hello", "world"} {
+		c.Write([]byte(m))
+		select {
+		case got := <-clientHandler.received:
+			if got != m {
+				t.Fatalf("unix echo got %q, want %q", got, m)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("unix echo %q timeout", m)
+		}
+	}
+
+	cred := <-creds
+	if runtime.GOOS != "linux" {
+		return
+	}
+	if cred == nil {
+		t.Fatalf("unix peer credentials not retrieved")
+	}
+	if cred.UID != uint32(os.Getuid()) || cred.PID != int32(os.Getpid()) {
+		t.Fatalf("unix peer credentials mismatch: %+v", cred)
+	}
+}
+
+func TestUnixChannelReadAfterInit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nginet")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	addr := &net.UnixAddr{Name: filepath.Join(dir, "init.sock"), Net: "unix"}
+
+	s := core.GetAcceptorBuilder(core.UnixServBuilder).Build()
+	serverHandler := newDatagramHandler(false)
+	s.InitSubChannel(func(channel core.SubChannel) {
+		// the data sent before the handlers added should not be lost.
+		time.Sleep(100 * time.Millisecond)
+		channel.Pipeline().AddLast(nil, "datagramHandler", serverHandler)
+	})
+	s.Listen(addr)
+	go s.Accept()
+	defer s.Close()
+
+	conn, err := net.Dial("unix", addr.Name)
+	if err != nil {
+		t.Fatalf("unix dial failed: %+v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("hello"))
+
+	select {
+	case got := <-serverHandler.received:
+		if got != "hello" {
+			t.Fatalf("unix server got %q, want hello", got)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("the data sent once connected should be read after initialized")
+	}
+}
+
+func TestUnixPacketTruncated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nginet")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	addr := &net.UnixAddr{Name: filepath.Join(dir, "packet.sock"), Net: "unixpacket"}
+
+	s := core.GetAcceptorBuilder(core.UnixServBuilder).Build(unix.WithReadBufSize(8))
+	serverHandler := newDatagramHandler(false)
+	eh := &errorHandler{core.NewDefaultInboundHandler(), make(chan error, 4)}
+	s.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "datagramHandler", serverHandler)
+		channel.Pipeline().AddLast(nil, "errorHandler", eh)
+	})
+	s.Listen(addr)
+	go s.Accept()
+	defer s.Close()
+
+	conn, err := net.Dial("unixpacket", addr.Name)
+	if err != nil {
+		t.Fatalf("unix dial failed: %+v", err)
+	}
+	defer conn.Close()
+
+	// the packet larger than ReadBufSize is dropped with the error fired, the channel goes on.
+	conn.Write([]byte("0123456789"))
+	conn.Write([]byte("hello"))
+	select {
+	case err := <-eh.errs:
+		if !errors.Is(err, core.ErrMessageTruncated) {
+			t.Fatalf("expect ErrMessageTruncated, got %+v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("the truncated packet should fire an error")
+	}
+	select {
+	case got := <-serverHandler.received:
+		if got != "hello" {
+			t.Fatalf("unix server got %q, want hello", got)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("unix server receive timeout")
+	}
+}
+
+func TestUnixMaxConnNum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nginet")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	addr := &net.UnixAddr{Name: filepath.Join(dir, "max.sock"), Net: "unix"}
+
+	s := core.GetAcceptorBuilder(core.UnixServBuilder).Build(unix.WithMaxConnNum(1))
+	serverHandler := newDatagramHandler(false)
+	s.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "datagramHandler", serverHandler)
+	})
+	s.Listen(addr)
+	go s.Accept()
+	defer s.Close()
+
+	// a new connection is accepted after the previous one disconnected.
+	for _, m := range []string{"first", "second"} {
+		conn, err := net.Dial("unix", addr.Name)
+		if err != nil {
+			t.Fatalf("unix dial failed: %+v", err)
+		}
+		conn.Write([]byte(m))
+		select {
+		case got := <-serverHandler.received:
+			if got != m {
+				t.Fatalf("unix server got %q, want %q", got, m)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("the connection %q should be accepted", m)
+		}
+		conn.Close()
+
+		deadline := time.Now().Add(3 * time.Second)
+		for len(s.SubChannels()) > 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package test
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/handler"
+)
+
+func varintFrame(payload string) string {
+	head := make([]byte, binary.MaxVarintLen64)
+	return string(head[:binary.PutUvarint(head, uint64(len(payload)))]) + payload
+}
+
+func TestVarintFrameDecoder(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+	fc := newFrameCollector(channel, handler.NewVarintFrameDecoder(512))
+
+	large := strings.Repeat("x", 300) // the varint length is 0xAC 0x02.
+	stream := varintFrame(large) + varintFrame("") + varintFrame("abc") + varintFrame(strings.Repeat("y", 600)) + varintFrame("ok")
+	// the varint split across reads.
+	fireBytes(channel, stream[:1], stream[1:100], stream[100:310], stream[310:])
+
+	want := []string{large, "", "abc", "ok"}
+	if len(fc.errs) != 1 || !reflect.DeepEqual(fc.frames, want) {
+		t.Fatalf("frames got %d %v, errs %v", len(fc.frames), fc.frames, fc.errs)
+	}
+}
+
+func TestVarintFrameDecoderOverflow(t *testing.T) {
+	channel := newTestChannel()
+	defer channel.Close()
+	fc := newFrameCollector(channel, handler.NewVarintFrameDecoder(512))
+
+	fireBytes(channel, "\xff\xff\xff\xff\xff\x01")
+	if len(fc.errs) != 1 || len(fc.frames) != 0 {
+		t.Fatalf("frames got %q, errs %v", fc.frames, fc.errs)
+	}
+}
+
+func TestVarintLengthPrepender(t *testing.T) {
+	conn := &batchConn{
+		gateConn: gateConn{release: make(chan byte), closed: make(chan byte)},
+		calls:    make(chan string, 2),
+	}
+	close(conn.release)
+	channel := core.NewSubChannel(conn, &core.SubChannelOpts{})
+	defer channel.Close()
+	channel.Pipeline().AddLast(nil, "prepender", handler.NewVarintLengthPrepender())
+
+	large := strings.Repeat("x", 300)
+	channel.Pipeline().FireWrite([]byte(large))
+	// no header space reserved.
+	channel.Pipeline().FireWrite(bytes.NewWriteOnlyBufferWithBytes(0, []byte("abc")))
+	for _, want := range []string{"\xac\x02" + large, "\x03abc"} {
+		if got := <-conn.calls; got != want {
+			t.Fatalf("prepended got %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+)
+
+// gateConn blocks all writes until released, reading blocks until closed.
+type gateConn struct {
+	release chan byte
+	closed  chan byte
+}
+
+func (gc *gateConn) Read(buf bytes.ReadOnlyBuffer) error {
+	<-gc.closed
+	return errors.New("closed")
+}
+
+func (gc *gateConn) Write(data []byte) error {
+	<-gc.release
+	return nil
+}
+
+func (gc *gateConn) Close() error {
+	select {
+	case <-gc.closed:
+	default:
+		close(gc.closed)
+	}
+	return nil
+}
+func (gc *gateConn) LocalAddr() net.Addr  { return nil }
+func (gc *gateConn) RemoteAddr() net.Addr { return nil }
+
+type writabilityHandler struct {
+	*core.DefaultInboundHandler
+	events chan bool
+}
+
+func (wh *writabilityHandler) OnEvent(ctx *core.ChannelContext, event interface{}) {
+	if e, ok := event.(core.WritabilityChangedEvent); ok {
+		wh.events <- e.Writable
+	}
+	ctx.FireEvent(event)
+}
+
+func waitWritability(t *testing.T, events chan bool, want bool) {
+	select {
+	case writable := <-events:
+		if writable != want {
+			t.Fatalf("writability changed to %v, want %v", writable, want)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("wait writability %v timeout", want)
+	}
+}
+
+func TestWritability(t *testing.T) {
+	conn := &gateConn{release: make(chan byte), closed: make(chan byte)}
+	channel := core.NewSubChannel(conn, &core.SubChannelOpts{
+		ReadBufSize:  1024,
+		WriteBufSize: 4,
+		WaterMark:    &core.WriteBufferWaterMark{Low: 4, High: 10},
+	})
+	defer channel.Close()
+	wh := &writabilityHandler{core.NewDefaultInboundHandler(), make(chan bool, 8)}
+	channel.Pipeline().AddLast(nil, "writabilityHandler", wh)
+
+	// the first one is blocked in writing, 4 queued.
+	for i := 0; i < 5; i++ {
+		if err := channel.WriteContext(context.Background(), []byte("12345")); err != nil {
+			t.Fatalf("write failed: %+v", err)
+		}
+	}
+	waitWritability(t, wh.events, false)
+	if channel.IsWritable() {
+		t.Fatalf("channel should be unwritable above the high watermark")
+	}
+
+	if err := channel.Write([]byte("12345")); err != core.ErrWriteMsgQueueFull {
+		t.Fatalf("write to a full queue should fail, got %+v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := channel.WriteContext(ctx, []byte("12345")); err != context.DeadlineExceeded {
+		t.Fatalf("write to a full queue should wait until the ctx done, got %+v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- channel.WriteContext(context.Background(), []byte("12345")) }()
+	close(conn.release)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("blocked write failed: %+v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("blocked write should succeed after released")
+	}
+
+	waitWritability(t, wh.events, true)
+	if err := channel.WriteAsync([]byte("12345")).Wait(); err != nil {
+		t.Fatalf("write after drained failed: %+v", err)
+	}
+	if !channel.IsWritable() {
+		t.Fatalf("channel should be writable after drained")
+	}
+}
+
+func TestWritabilityReconnectBuffer(t *testing.T) {
+	lost := &gateConn{release: make(chan byte), closed: make(chan byte)}
+	close(lost.closed)
+	redialed := &batchConn{
+		gateConn: gateConn{release: make(chan byte), closed: make(chan byte)},
+		calls:    make(chan string, 8),
+	}
+	close(redialed.release)
+	dial := make(chan byte)
+	channel := core.NewSubChannel(lost, &core.SubChannelOpts{
+		WriteBufSize:   8,
+		WriteBatchSize: 1,
+		WaterMark:      &core.WriteBufferWaterMark{Low: 4, High: 10},
+		Reconnect: &core.ReconnectOpts{
+			AutoReconnect: true,
+			Policy:        newTestBackOff(),
+			Dial: func() (core.RawConn, error) {
+				<-dial
+				return redialed, nil
+			},
+		},
+	})
+	defer channel.Close()
+	wh := &writabilityHandler{core.NewDefaultInboundHandler(), make(chan bool, 8)}
+	eh := &reconnectEventHandler{core.NewDefaultInboundHandler(), make(chan interface{}, 8)}
+	channel.Pipeline().AddLast(nil, "writabilityHandler", wh)
+	channel.Pipeline().AddLast(nil, "eventHandler", eh)
+	core.NewConnector().FireConnect(channel)
+	if _, ok := (<-eh.events).(core.ReconnectingEvent); !ok {
+		t.Fatalf("channel should be reconnecting")
+	}
+
+	// the messages buffered while reconnecting are pending.
+	for i := 0; i < 3; i++ {
+		if err := channel.Write([]byte("12345")); err != nil {
+			t.Fatalf("write while reconnecting failed: %+v", err)
+		}
+	}
+	waitWritability(t, wh.events, false)
+	select {
+	case <-wh.events:
+		t.Fatalf("channel should be unwritable until the buffered messages written")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(dial)
+	waitWritability(t, wh.events, true)
+	for i := 0; i < 3; i++ {
+		if got := <-redialed.calls; got != "12345" {
+			t.Fatalf("replayed %q", got)
+		}
+	}
+}
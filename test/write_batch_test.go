@@ -0,0 +1,130 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/core/tcp"
+)
+
+// batchConn records each write call, all writes are blocked until released.
+type batchConn struct {
+	gateConn
+	calls chan string
+}
+
+func (bc *batchConn) Write(data []byte) error {
+	bc.calls <- string(data)
+	return bc.gateConn.Write(data)
+}
+
+func (bc *batchConn) WriteBuffers(bufs net.Buffers) error {
+	var parts []string
+	for _, b := range bufs {
+		parts = append(parts, string(b))
+	}
+	bc.calls <- strings.Join(parts, ",")
+	return nil
+}
+
+func TestWriteBatch(t *testing.T) {
+	conn := &batchConn{
+		gateConn: gateConn{release: make(chan byte), closed: make(chan byte)},
+		calls:    make(chan string, 8),
+	}
+	channel := core.NewSubChannel(conn, &core.SubChannelOpts{ReadBufSize: 1024, WriteBufSize: 64})
+	defer channel.Close()
+
+	// queue the messages while the writeloop is blocked in the first write.
+	channel.Write([]byte("a"))
+	if call := <-conn.calls; call != "a" {
+		t.Fatalf("first write got %q", call)
+	}
+	channel.Write([]byte("b"))
+	channel.Write([]byte("c"))
+	channel.WriteAndFlush([]byte("d"))
+	channel.Write([]byte("e"))
+	future := channel.WriteAsync([]byte("f"))
+	close(conn.release)
+
+	for _, want := range []string{"b,c,d", "e,f"} {
+		select {
+		case call := <-conn.calls:
+			if call != want {
+				t.Fatalf("batch write got %q, want %q", call, want)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("wait batch write %q timeout", want)
+		}
+	}
+	if err := future.Wait(); err != nil {
+		t.Fatalf("write future of batch failed: %+v", err)
+	}
+}
+
+// discardHandler counts the received bytes.
+type discardHandler struct {
+	*core.DefaultInboundHandler
+	received int64
+	target   int64
+	done     chan byte
+}
+
+func (dh *discardHandler) OnRead(ctx *core.ChannelContext, msg interface{}) {
+	buf := msg.(bytes.ReadOnlyBuffer)
+	n := buf.Len()
+	buf.Discard(n)
+	if atomic.AddInt64(&dh.received, int64(n)) == atomic.LoadInt64(&dh.target) {
+		dh.done <- 1
+	}
+}
+
+func benchmarkTCPWrite(b *testing.B, port string, batchSize int) {
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:"+port)
+	dh := &discardHandler{DefaultInboundHandler: core.NewDefaultInboundHandler(), done: make(chan byte, 1)}
+	s := core.GetAcceptorBuilder(core.TCPServBuilder).Build()
+	s.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "discardHandler", dh)
+	})
+	s.Listen(addr)
+	go s.Accept()
+	defer s.Close()
+
+	c := core.GetConnectorBuilder(core.TCPCliBuilder).Build(tcp.WithWriteBatchSize(batchSize))
+	c.InitSubChannel(func(channel core.SubChannel) {})
+	if _, err := c.Connect(addr); err != nil {
+		b.Fatalf("connect failed: %+v", err)
+	}
+	defer c.Close()
+
+	packet := make([]byte, 32)
+	atomic.StoreInt64(&dh.target, int64(b.N*len(packet)))
+	b.SetBytes(int64(len(packet)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.WriteContext(context.Background(), packet); err != nil {
+			b.Fatalf("write failed: %+v", err)
+		}
+	}
+
+	select {
+	case <-dh.done:
+	case <-time.After(30 * time.Second):
+		b.Fatal(errors.New("wait received timeout"))
+	}
+}
+
+func BenchmarkTCPWriteOneByOne(b *testing.B) {
+	benchmarkTCPWrite(b, "7891", 1)
+}
+
+func BenchmarkTCPWriteBatch(b *testing.B) {
+	benchmarkTCPWrite(b, "7892", 64)
+}
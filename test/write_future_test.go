@@ -0,0 +1,153 @@
+package test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/amsalt/nginet/bytes"
+	"github.com/amsalt/nginet/core"
+	"github.com/amsalt/nginet/handler"
+)
+
+var errBrokenPipe = errors.New("broken pipe")
+
+// brokenConn fails all writes, reading blocks until closed.
+type brokenConn struct {
+	closed chan byte
+}
+
+func (bc *brokenConn) Read(buf bytes.ReadOnlyBuffer) error {
+	<-bc.closed
+	return errors.New("closed")
+}
+
+func (bc *brokenConn) Write(data []byte) error { return errBrokenPipe }
+func (bc *brokenConn) Close() error {
+	select {
+	case <-bc.closed:
+	default:
+		close(bc.closed)
+	}
+	return nil
+}
+func (bc *brokenConn) LocalAddr() net.Addr  { return nil }
+func (bc *brokenConn) RemoteAddr() net.Addr { return nil }
+
+type errorHandler struct {
+	*core.DefaultInboundHandler
+	errs chan error
+}
+
+func (eh *errorHandler) OnError(ctx *core.ChannelContext, err error) {
+	eh.errs <- err
+}
+
+func waitFuture(t *testing.T, f core.WriteFuture) error {
+	select {
+	case <-f.Done():
+		return f.Err()
+	case <-time.After(3 * time.Second):
+		t.Fatalf("wait write future timeout")
+	}
+	return nil
+}
+
+func TestWriteFuture(t *testing.T) {
+	s := core.GetAcceptorBuilder(core.TCPServBuilder).Build()
+	serverHandler := newStreamHandler(false)
+	s.InitSubChannel(func(channel core.SubChannel) {
+		channel.Pipeline().AddLast(nil, "streamHandler", serverHandler)
+	})
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:7890")
+	s.Listen(addr)
+	go s.Accept()
+	defer s.Close()
+
+	c := core.GetConnectorBuilder(core.TCPCliBuilder).Build()
+	c.InitSubChannel(func(channel core.SubChannel) {})
+	if _, err := c.Connect(addr); err != nil {
+		t.Fatalf("connect failed: %+v", err)
+	}
+	defer c.Close()
+
+	if err := waitFuture(t, c.WriteAsync([]byte("hello"))); err != nil {
+		t.Fatalf("write future failed: %+v", err)
+	}
+	waitReceived(t, serverHandler, "hello")
+
+	// no encoder for int.
+	if err := waitFuture(t, c.WriteAsync(1)); err != core.ErrUnsupportedMessage {
+		t.Fatalf("write unsupported message should fail, got %+v", err)
+	}
+}
+
+func TestWriteFutureConnError(t *testing.T) {
+	channel := core.NewDefaultSubChannel(&brokenConn{closed: make(chan byte)}, 1024, 16)
+	eh := &errorHandler{core.NewDefaultInboundHandler(), make(chan error, 4)}
+	channel.Pipeline().AddLast(nil, "errorHandler", eh)
+
+	if err := waitFuture(t, channel.WriteAsync([]byte("hello"))); err != errBrokenPipe {
+		t.Fatalf("write future should fail with the conn error, got %+v", err)
+	}
+
+	select {
+	case err := <-eh.errs:
+		if err != errBrokenPipe {
+			t.Fatalf("OnError got %+v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("wait OnError timeout")
+	}
+
+	select {
+	case <-channel.CloseNotify():
+	case <-time.After(3 * time.Second):
+		t.Fatalf("channel should be closed after write failed")
+	}
+
+	if err := waitFuture(t, channel.WriteAsync([]byte("again"))); err != core.ErrConnLost {
+		t.Fatalf("write after closed should fail, got %+v", err)
+	}
+}
+
+// asyncForwarder forwards the messages from another goroutine after OnWrite returned.
+type asyncForwarder struct {
+	*core.DefaultOutboundHandler
+}
+
+func (af *asyncForwarder) OnWrite(ctx *core.ChannelContext, msg interface{}) {
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		ctx.FireWrite(msg)
+	}()
+}
+
+func TestWriteFuturePromisePassing(t *testing.T) {
+	conn := &batchConn{
+		gateConn: gateConn{release: make(chan byte), closed: make(chan byte)},
+		calls:    make(chan string, 64),
+	}
+	close(conn.release)
+	channel := core.NewSubChannel(conn, &core.SubChannelOpts{WriteBufSize: 64})
+	defer channel.Close()
+	channel.Pipeline().AddLast(nil, "prepender", handler.NewPacketLengthPrepender(1))
+	channel.Pipeline().AddLast(nil, "forwarder", &asyncForwarder{core.NewDefaultOutboundHandler()})
+
+	// each message forwarded later completes its own promise.
+	var futures []core.WriteFuture
+	for i := 0; i < 8; i++ {
+		futures = append(futures, channel.WriteAsync(bytes.NewWriteOnlyBufferWithBytes(1, []byte("hello"))))
+	}
+	for _, f := range futures {
+		if err := waitFuture(t, f); err != nil {
+			t.Fatalf("write future failed: %+v", err)
+		}
+	}
+
+	// the promise fails if the encoder drops the message.
+	if err := waitFuture(t, channel.WriteAsync(bytes.NewWriteOnlyBufferWithBytes(1, make([]byte, 300)))); err == nil {
+		t.Fatalf("write too long frame should fail")
+	}
+}